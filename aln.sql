@@ -4,16 +4,28 @@ CREATE TABLE users (
     username VARCHAR(255) NOT NULL,
     password VARCHAR(255) NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
+    pending_email VARCHAR(255) NULL,
     degree VARCHAR(255),
     grad_year VARCHAR(4),
     current_job VARCHAR(255),
     phone VARCHAR(20),
-    session_key VARCHAR(255) UNIQUE,
     profile_picture VARCHAR(255),
     linkedin_profile VARCHAR(255),
     twitter_profile VARCHAR(255),
+    email_verified_at DATETIME NULL,
+    verification_sent_at DATETIME NULL,
+    mfa_required TINYINT(1) NOT NULL DEFAULT 0,
+    role VARCHAR(32) NOT NULL DEFAULT 'member',
+    alumni_verified_at DATETIME NULL,
+    deletion_scheduled_at DATETIME NULL,
+    recommendations_at DATETIME NULL,
+    follow_approval TINYINT(1) NOT NULL DEFAULT 0,
+    deleted_at DATETIME NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_users_username (username),
+    INDEX idx_users_grad_year (grad_year),
+    INDEX idx_users_recommendations_at (recommendations_at)
 );
 
 --table: portfolio order table
@@ -29,7 +41,7 @@ CREATE TABLE portfolio_orders (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id),
-    FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE
+    FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE
 );
 
 
@@ -46,9 +58,9 @@ CREATE TABLE transactions (
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (from_user_id) REFERENCES users(id),
-    FOREIGN KEY (from_user_email) REFERENCES users(email),
+    FOREIGN KEY (from_user_email) REFERENCES users(email) ON UPDATE CASCADE,
     FOREIGN KEY (to_user_id) REFERENCES users(id),
-    FOREIGN KEY (to_user_email) REFERENCES users(email)
+    FOREIGN KEY (to_user_email) REFERENCES users(email) ON UPDATE CASCADE
 );
 
 --table: forums
@@ -60,7 +72,7 @@ CREATE TABLE forums (
     slug VARCHAR(255) UNIQUE NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    FOREIGN KEY (author) REFERENCES users(email) ON DELETE SET NULL
+    FOREIGN KEY (author) REFERENCES users(email) ON DELETE SET NULL ON UPDATE CASCADE
 );
 
 --table: comments
@@ -120,4 +132,339 @@ CREATE TABLE group_messages (
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+--table for storing connection requests between users
+CREATE TABLE connection_requests (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    requester_id INT NOT NULL,
+    recipient_id INT NOT NULL,
+    status ENUM('pending', 'accepted', 'rejected', 'withdrawn') NOT NULL DEFAULT 'pending',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_connection_requester (requester_id, status),
+    INDEX idx_connection_recipient (recipient_id, status),
+    FOREIGN KEY (requester_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for signed in devices, the id is carried in the sid claim of access tokens
+CREATE TABLE sessions (
+    id CHAR(32) PRIMARY KEY,
+    user_id INT NOT NULL,
+    device VARCHAR(255),
+    ip VARCHAR(45),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NULL,
+    INDEX idx_sessions_user (user_id, revoked_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for storing hashed refresh tokens, each session rotates its own chain
+CREATE TABLE refresh_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    session_id CHAR(32) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+--table for revoked access tokens (jti denylist), kept until the token expires
+CREATE TABLE revoked_tokens (
+    jti CHAR(32) PRIMARY KEY,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+--table for single use password reset tokens, only the hash is stored
+CREATE TABLE password_resets (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for totp two factor authentication, enabled_at is NULL while enrolling
+CREATE TABLE two_factor (
+    user_id INT PRIMARY KEY,
+    secret VARCHAR(64) NOT NULL,
+    enabled_at DATETIME NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for one time two factor recovery codes, only the hash is stored
+CREATE TABLE recovery_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_recovery_code (user_id, code_hash),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for roles, require_2fa forces two factor enrollment for every holder
+CREATE TABLE roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description VARCHAR(255),
+    require_2fa TINYINT(1) NOT NULL DEFAULT 0
+);
+
+--table for permissions checked by the RequirePermission middleware
+CREATE TABLE permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description VARCHAR(255)
+);
+
+--table for the permissions granted to each role
+CREATE TABLE role_permissions (
+    role VARCHAR(32) NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    PRIMARY KEY (role, permission),
+    FOREIGN KEY (role) REFERENCES roles(name) ON DELETE CASCADE,
+    FOREIGN KEY (permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+
+INSERT INTO roles (name, description, require_2fa) VALUES
+    ('member', 'regular alumni account', 0),
+    ('moderator', 'moderates forums and groups', 0),
+    ('admin', 'manages the platform', 1);
+
+INSERT INTO permissions (name, description) VALUES
+    ('forums.moderate', 'edit or remove any forum post or comment'),
+    ('groups.manage', 'manage members of any group'),
+    ('users.manage', 'manage user accounts'),
+    ('roles.manage', 'assign roles to users');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'forums.moderate'),
+    ('moderator', 'groups.manage'),
+    ('admin', 'forums.moderate'),
+    ('admin', 'groups.manage'),
+    ('admin', 'users.manage'),
+    ('admin', 'roles.manage');
+
+ALTER TABLE users ADD FOREIGN KEY (role) REFERENCES roles(name);
+
+--table for failed sign in attempts per email, drives backoff and lockout
+CREATE TABLE login_attempts (
+    email VARCHAR(255) PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at DATETIME NOT NULL,
+    locked_until DATETIME NULL
+);
+
+--table for personal access tokens used by scripts and bots, only the hash is stored
+CREATE TABLE personal_access_tokens (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    token_prefix VARCHAR(16) NOT NULL,
+    scopes VARCHAR(512) NOT NULL,
+    last_used_at DATETIME NULL,
+    last_used_ip VARCHAR(45),
+    expires_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_pat_user (user_id, revoked_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for the graduate registry imported from the university, see the registry import command
+CREATE TABLE graduate_registry (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    matric_number VARCHAR(32) UNIQUE NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    faculty VARCHAR(255) NOT NULL,
+    degree VARCHAR(255) NOT NULL,
+    grad_year VARCHAR(4) NOT NULL,
+    claimed_by INT NULL UNIQUE,
+    claimed_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (claimed_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+--table for invite codes admins hand out while registration is invite only, only the hash is stored
+CREATE TABLE invite_codes (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    code_hash CHAR(64) UNIQUE NOT NULL,
+    code_prefix VARCHAR(8) NOT NULL,
+    note VARCHAR(255),
+    max_uses INT NOT NULL DEFAULT 1,
+    uses INT NOT NULL DEFAULT 0,
+    expires_at DATETIME NULL,
+    created_by INT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME NULL,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+--table for who can see each profile field, fields without a row use model.PrivacyDefaults
+CREATE TABLE profile_privacy (
+    user_id INT NOT NULL,
+    field VARCHAR(32) NOT NULL,
+    visibility ENUM('public', 'connections', 'private') NOT NULL,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, field),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for work history, months are YYYY-MM and a NULL end_month is the current job
+CREATE TABLE experiences (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    company VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    location VARCHAR(255),
+    start_month CHAR(7) NOT NULL,
+    end_month CHAR(7) NULL,
+    description TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_experiences_user (user_id),
+    INDEX idx_experiences_company (company),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for education, at LASU or other institutions
+CREATE TABLE educations (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    institution VARCHAR(255) NOT NULL,
+    degree VARCHAR(255),
+    field_of_study VARCHAR(255),
+    start_year VARCHAR(4) NULL,
+    end_year VARCHAR(4) NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_educations_user (user_id),
+    INDEX idx_educations_institution (institution),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for the skills catalogue, skill_key is the lowercased name that makes "Go" and "go" one skill
+CREATE TABLE skills (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    skill_key VARCHAR(50) UNIQUE NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+--table for the skills users list on their profiles
+CREATE TABLE user_skills (
+    user_id INT NOT NULL,
+    skill_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, skill_id),
+    INDEX idx_user_skills_skill (skill_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (skill_id) REFERENCES skills(id) ON DELETE CASCADE
+);
+
+--table for endorsements, one per endorser per listed skill, removed with the listing
+CREATE TABLE skill_endorsements (
+    user_id INT NOT NULL,
+    skill_id INT NOT NULL,
+    endorser_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, skill_id, endorser_id),
+    INDEX idx_skill_endorsements_endorser (endorser_id),
+    FOREIGN KEY (user_id, skill_id) REFERENCES user_skills(user_id, skill_id) ON DELETE CASCADE,
+    FOREIGN KEY (endorser_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for cached "people you may know", recomputed once recommendations_at on users gets old
+CREATE TABLE recommendations (
+    user_id INT NOT NULL,
+    candidate_id INT NOT NULL,
+    score DOUBLE NOT NULL,
+    mutual_connections INT NOT NULL DEFAULT 0,
+    shared_groups INT NOT NULL DEFAULT 0,
+    shared_forums INT NOT NULL DEFAULT 0,
+    same_degree TINYINT(1) NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, candidate_id),
+    INDEX idx_recommendations_score (user_id, score),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (candidate_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for blocks, blocked users cannot message, connect with or add the blocker to groups
+CREATE TABLE user_blocks (
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    INDEX idx_user_blocks_blocked (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for mutes, muted users' comments are hidden from the muter and nothing else changes
+CREATE TABLE user_mutes (
+    muter_id INT NOT NULL,
+    muted_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    INDEX idx_user_mutes_muted (muted_id),
+    FOREIGN KEY (muter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--table for follows, pending until approved when the followee has follow_approval set
+CREATE TABLE follows (
+    follower_id INT NOT NULL,
+    followee_id INT NOT NULL,
+    status ENUM('pending', 'accepted') NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    INDEX idx_follows_followee (followee_id, status, updated_at),
+    INDEX idx_follows_follower (follower_id, status, updated_at),
+    FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+--soft delete for forum posts, so their comments stay put
+ALTER TABLE forums ADD COLUMN deleted_at DATETIME NULL;
+
+--table for forum post revisions, the post as it was before each edit or delete
+CREATE TABLE forum_revisions (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    forum_id INT NOT NULL,
+    editor_id INT,
+    action ENUM('edit', 'delete') NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    title_diff TEXT,
+    description_diff TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_forum_revisions_forum (forum_id, id),
+    FOREIGN KEY (forum_id) REFERENCES forums(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+--table for the old slugs of renamed forum posts, links to them redirect to the current slug
+CREATE TABLE forum_slugs (
+    slug VARCHAR(255) PRIMARY KEY,
+    forum_id INT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_forum_slugs_forum (forum_id),
+    FOREIGN KEY (forum_id) REFERENCES forums(id) ON DELETE CASCADE
+);
 
+--threaded replies, depth counts from 0 for comments on the post itself
+ALTER TABLE comments ADD COLUMN parent_id INT NULL, ADD COLUMN depth TINYINT NOT NULL DEFAULT 0,
+    ADD INDEX idx_comments_parent (parent_id, created_at, id),
+    ADD FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
@@ -14,6 +14,9 @@ func main() {
 		Usage: "networking platform for alumnis",
 		Commands: []*cli.Command{
 			command.StartCommand(),
+			command.UsersCommand(),
+			command.KeysCommand(),
+			command.RegistryCommand(),
 		},
 		Version: "v0.1.5",
 		Authors: []*cli.Author{
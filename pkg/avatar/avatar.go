@@ -0,0 +1,144 @@
+// Package avatar turns uploaded pictures into square profile pictures.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // decoders for the accepted formats
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// Sizes are the square edge lengths every avatar is stored at, smallest first.
+var Sizes = []int{64, 128, 256, 512}
+
+// ContentTypes are the sniffed types accepted for upload.
+var ContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+const (
+	maxEdge   = 8000
+	maxPixels = 16_000_000 // decoding allocates 4 bytes a pixel, this caps it near 64MB
+	quality   = 85
+
+	// maxConcurrent is how many pictures are processed at once. Each holds up
+	// to three full size copies while it is processed, so this bounds the
+	// memory uploads from many accounts at once can take.
+	maxConcurrent = 2
+)
+
+// slots holds a token for each picture being processed.
+var slots = make(chan struct{}, maxConcurrent)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooLarge        = errors.New("image dimensions too large")
+	ErrTooSmall        = errors.New("image dimensions too small")
+)
+
+// Process checks data is an accepted image and returns it as a JPEG at each
+// of Sizes, center cropped to a square. Pictures are decoded and re-encoded,
+// so EXIF and other metadata never reach the output; the EXIF orientation is
+// applied first so phone pictures are upright. Calls wait while
+// maxConcurrent other pictures are being processed.
+func Process(data []byte) (map[int][]byte, error) {
+	if !ContentTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+	// the header is checked before decoding so a small file claiming huge
+	// dimensions is never expanded in memory
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, err.Error())
+	}
+	if config.Width > maxEdge || config.Height > maxEdge || config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	if config.Width < Sizes[0] || config.Height < Sizes[0] {
+		return nil, ErrTooSmall
+	}
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, err.Error())
+	}
+
+	// transparent pixels become white, jpeg has no alpha
+	bounds := decoded.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), decoded, bounds.Min, draw.Over)
+	upright := orient(flat, exifOrientation(data))
+
+	square := cropSquare(upright)
+	out := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resize(square, size), &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		out[size] = buf.Bytes()
+	}
+	return out, nil
+}
+
+// cropSquare returns the largest centered square of img.
+func cropSquare(img *image.RGBA) *image.RGBA {
+	bounds := img.Bounds()
+	edge := bounds.Dx()
+	if bounds.Dy() < edge {
+		edge = bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-edge)/2
+	y := bounds.Min.Y + (bounds.Dy()-edge)/2
+	return img.SubImage(image.Rect(x, y, x+edge, y+edge)).(*image.RGBA)
+}
+
+// resize scales a square image to size x size by averaging the source
+// pixels each output pixel covers, which keeps downscaled pictures smooth.
+// Upscaling small pictures falls back to repeating pixels.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	bounds := src.Bounds()
+	edge := bounds.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := y * edge / size
+		y1 := (y + 1) * edge / size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0 := x * edge / size
+			x1 := (x + 1) * edge / size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, n uint32
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[offset])
+					g += uint32(src.Pix[offset+1])
+					b += uint32(src.Pix[offset+2])
+					offset += 4
+					n++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = 0xff
+		}
+	}
+	return dst
+}
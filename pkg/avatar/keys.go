@@ -0,0 +1,45 @@
+package avatar
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultSize is the size stored as the user's profile picture url, the
+// other sizes sit next to it with the size swapped.
+const DefaultSize = 256
+
+var versionPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Dir is the blob key prefix for a user's avatars.
+func Dir(userID int) string {
+	return fmt.Sprintf("avatars/%d/", userID)
+}
+
+// Key is the blob key of one size of an avatar. version identifies the
+// upload, so a new picture gets new keys and cached urls never go stale.
+func Key(userID int, version string, size int) string {
+	return fmt.Sprintf("%s%s-%d.jpg", Dir(userID), version, size)
+}
+
+// Keys lists the blob keys of every size of an avatar.
+func Keys(userID int, version string) []string {
+	keys := make([]string, 0, len(Sizes))
+	for _, size := range Sizes {
+		keys = append(keys, Key(userID, version, size))
+	}
+	return keys
+}
+
+// Version returns the version of a profile picture url built from Key with
+// DefaultSize, dirURL being the url of Dir(userID). ok is false for urls not
+// pointing at an uploaded avatar, e.g. ones set before uploads existed.
+func Version(pictureURL string, dirURL string) (version string, ok bool) {
+	name := strings.TrimPrefix(pictureURL, dirURL)
+	if name == pictureURL {
+		return "", false
+	}
+	version = strings.TrimSuffix(name, fmt.Sprintf("-%d.jpg", DefaultSize))
+	return version, versionPattern.MatchString(version)
+}
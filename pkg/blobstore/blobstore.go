@@ -0,0 +1,20 @@
+// Package blobstore stores uploaded files such as profile pictures.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore keeps blobs under slash separated keys, e.g.
+// "avatars/12/3f2a-256.jpg", and knows the public URL each one is served
+// from. Keys are never reused for different content, so URLs can be cached
+// forever.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
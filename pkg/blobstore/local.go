@@ -0,0 +1,96 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var _ BlobStore = &localStore{}
+
+type localStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore keeps blobs as files under dir. baseURL is where Handler is
+// mounted, e.g. "/media" or "https://cdn.example.com/media".
+func NewLocalStore(dir string, baseURL string) (*localStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStore{
+		dir:     dir,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}, nil
+}
+
+// path maps a key to a file under dir, refusing keys that would leave it.
+func (ls *localStore) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned != "/"+key || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes to a temporary file first so readers never see half a blob.
+func (ls *localStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	target, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (ls *localStore) Delete(ctx context.Context, key string) error {
+	target, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (ls *localStore) URL(key string) string {
+	return ls.baseURL + "/" + key
+}
+
+// Handler serves the stored files. It has to be mounted with the base URL's
+// path stripped, directory listings are refused.
+func (ls *localStore) Handler() http.Handler {
+	files := http.FileServer(http.Dir(ls.dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || strings.HasPrefix(path.Base(r.URL.Path), ".") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"github.com/jim-nnamdi/jinx/pkg/runner"
+	"github.com/urfave/cli/v2"
+)
+
+// databaseFlags are the MySQL connection flags shared by every command that
+// talks to the database.
+func databaseFlags(cfg *runner.DatabaseConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "mysql-database-name",
+			EnvVars:     []string{"AC_DBNAME"},
+			Usage:       "Sample database name",
+			Destination: &cfg.MySQLDatabaseName,
+			Value:       "",
+		},
+		&cli.StringFlag{
+			Name:        "mysql-database-password",
+			EnvVars:     []string{"AC_PASSWORD"},
+			Usage:       "Sample database password",
+			Destination: &cfg.MySQLDatabasePassword,
+			Value:       "",
+		},
+		&cli.StringFlag{
+			Name:        "mysql-database-User",
+			EnvVars:     []string{"AC_USER"},
+			Usage:       "Sample database user",
+			Destination: &cfg.MySQLDatabaseUser,
+			Value:       "",
+		},
+		&cli.StringFlag{
+			Name:        "mysql-database-Host",
+			EnvVars:     []string{"AC_HOST"},
+			Usage:       "Sample database host",
+			Destination: &cfg.MySQLDatabaseHost,
+			Value:       "",
+		},
+		&cli.StringFlag{
+			Name:        "mysql-database-Port",
+			EnvVars:     []string{"AC_PORT"},
+			Usage:       "Sample database port",
+			Destination: &cfg.MySQLDatabasePort,
+			Value:       "",
+		},
+	}
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"github.com/jim-nnamdi/jinx/pkg/jwtkeys"
+	"github.com/jim-nnamdi/jinx/pkg/runner"
+	"github.com/urfave/cli/v2"
+)
+
+func KeysCommand() *cli.Command {
+	var (
+		keysRunner = &runner.KeysRunner{}
+	)
+
+	fileFlag := &cli.StringFlag{
+		Name:        "file",
+		EnvVars:     []string{"AC_JWT_KEYS"},
+		Usage:       "keyset file, the same one passed to start --jwt-keys",
+		Destination: &keysRunner.File,
+		Required:    true,
+	}
+	algFlag := &cli.StringFlag{
+		Name:        "alg",
+		Usage:       "algorithm of the new key: HS256, RS256 or EdDSA",
+		Destination: &keysRunner.Algorithm,
+		Value:       jwtkeys.HS256,
+	}
+
+	cmd := &cli.Command{
+		Name:  "keys",
+		Usage: "manage the keys access tokens are signed with",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list the keys in the keyset",
+				Flags:  []cli.Flag{fileFlag},
+				Action: keysRunner.List,
+			},
+			{
+				Name:   "generate",
+				Usage:  "add a key that verifies tokens, roll it out before rotating to it",
+				Flags:  []cli.Flag{fileFlag, algFlag},
+				Action: keysRunner.Generate,
+			},
+			{
+				Name:  "rotate",
+				Usage: "sign new tokens with --kid, or with a freshly generated key",
+				Flags: []cli.Flag{fileFlag, algFlag,
+					&cli.StringFlag{
+						Name:        "kid",
+						Usage:       "id of the key to sign with",
+						Destination: &keysRunner.KeyID,
+					},
+				},
+				Action: keysRunner.Rotate,
+			},
+			{
+				Name:  "retire",
+				Usage: "remove a key once the tokens it signed have expired",
+				Flags: []cli.Flag{fileFlag,
+					&cli.StringFlag{
+						Name:        "kid",
+						Usage:       "id of the key to remove",
+						Destination: &keysRunner.KeyID,
+						Required:    true,
+					},
+				},
+				Action: keysRunner.Retire,
+			},
+		},
+	}
+	return cmd
+}
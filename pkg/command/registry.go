@@ -0,0 +1,38 @@
+package command
+
+import (
+	"github.com/jim-nnamdi/jinx/pkg/runner"
+	"github.com/urfave/cli/v2"
+)
+
+func RegistryCommand() *cli.Command {
+	var (
+		registryRunner = &runner.RegistryRunner{}
+	)
+
+	cmd := &cli.Command{
+		Name:  "registry",
+		Usage: "manage the graduate registry used to verify alumni",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "import",
+				Usage: "import graduates from a csv file with matric_number,name,faculty,degree,year columns",
+				Flags: append(databaseFlags(&registryRunner.DatabaseConfig),
+					&cli.StringFlag{
+						Name:        "file",
+						Usage:       "path of the csv file",
+						Destination: &registryRunner.File,
+						Required:    true,
+					},
+					&cli.BoolFlag{
+						Name:        "dry-run",
+						Usage:       "only validate the file",
+						Destination: &registryRunner.DryRun,
+					},
+				),
+				Action: registryRunner.Import,
+			},
+		},
+	}
+	return cmd
+}
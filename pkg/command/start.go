@@ -1,6 +1,8 @@
 package command
 
 import (
+	"time"
+
 	"github.com/jim-nnamdi/jinx/pkg/runner"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"github.com/urfave/cli/v2"
@@ -14,7 +16,7 @@ func StartCommand() *cli.Command {
 	cmd := &cli.Command{
 		Name:  "start",
 		Usage: "starts the server",
-		Flags: []cli.Flag{
+		Flags: append(databaseFlags(&startRunner.DatabaseConfig),
 			&cli.StringFlag{
 				Name:        "listen-addr",
 				EnvVars:     []string{"LISTEN_ADDR"},
@@ -23,55 +25,200 @@ func StartCommand() *cli.Command {
 				Value:       ":8080", // TODO: check that this is correct port to serve on
 			},
 			&cli.StringFlag{
-				Name:        "mysql-database-name",
-				EnvVars:     []string{"AC_DBNAME"},
-				Usage:       "Sample database name",
-				Destination: &startRunner.MySQLDatabaseName,
+				Name:        "jwt_issuer",
+				EnvVars:     []string{"JWTISSUER"},
+				Usage:       "issuer claim of access tokens",
+				Destination: &utils.JWTISSUER,
 				Value:       "",
 			},
 			&cli.StringFlag{
-				Name:        "mysql-database-password",
-				EnvVars:     []string{"AC_PASSWORD"},
-				Usage:       "Sample database password",
-				Destination: &startRunner.MySQLDatabasePassword,
+				Name:        "secret",
+				EnvVars:     []string{"AC_JWT_SECRET"},
+				Usage:       "signs access tokens when no jwt-keys file is set",
+				Destination: &utils.MYSTIC,
 				Value:       "",
 			},
 			&cli.StringFlag{
-				Name:        "mysql-database-User",
-				EnvVars:     []string{"AC_USER"},
-				Usage:       "Sample database user",
-				Destination: &startRunner.MySQLDatabaseUser,
+				Name:        "link-secret",
+				EnvVars:     []string{"AC_LINK_SECRET"},
+				Usage:       "secret for signed email links and two factor sign in tokens, must differ from the jwt secret",
+				Destination: &utils.LinkSecret,
 				Value:       "",
 			},
 			&cli.StringFlag{
-				Name:        "mysql-database-Host",
-				EnvVars:     []string{"AC_HOST"},
-				Usage:       "Sample database host",
-				Destination: &startRunner.MySQLDatabaseHost,
+				Name:        "jwt-keys",
+				EnvVars:     []string{"AC_JWT_KEYS"},
+				Usage:       "keyset file created with the keys command, replaces the secret for access tokens",
+				Destination: &startRunner.JWTKeysFile,
 				Value:       "",
 			},
+			&cli.TimestampFlag{
+				Name:        "jwt-legacy-until",
+				EnvVars:     []string{"AC_JWT_LEGACY_UNTIL"},
+				Usage:       "with jwt-keys, accept tokens signed with the secret until this RFC 3339 time, e.g. the switch over plus the access token ttl",
+				Layout:      time.RFC3339,
+				Destination: &startRunner.JWTLegacyUntil,
+			},
+			&cli.DurationFlag{
+				Name:        "access-token-ttl",
+				EnvVars:     []string{"AC_ACCESS_TOKEN_TTL"},
+				Usage:       "how long an access token (jwt) stays valid",
+				Destination: &utils.AccessTokenTTL,
+				Value:       utils.AccessTokenTTL,
+			},
+			&cli.DurationFlag{
+				Name:        "refresh-token-ttl",
+				EnvVars:     []string{"AC_REFRESH_TOKEN_TTL"},
+				Usage:       "how long a refresh token stays valid",
+				Destination: &utils.RefreshTokenTTL,
+				Value:       utils.RefreshTokenTTL,
+			},
+			&cli.StringFlag{
+				Name:        "app-url",
+				EnvVars:     []string{"AC_APP_URL"},
+				Usage:       "base url of the web app, used for links in emails",
+				Destination: &startRunner.AppURL,
+				Value:       "http://localhost:3000",
+			},
+			&cli.StringFlag{
+				Name:        "mail-from",
+				EnvVars:     []string{"AC_MAIL_FROM"},
+				Usage:       "sender address for outgoing emails",
+				Destination: &startRunner.MailFrom,
+				Value:       "no-reply@alumni.lasu.edu.ng",
+			},
+			&cli.StringFlag{
+				Name:        "mail-outbox",
+				EnvVars:     []string{"AC_MAIL_OUTBOX"},
+				Usage:       "file emails are written to when no smtp host is set, - for stdout",
+				Destination: &startRunner.MailOutbox,
+				Value:       "-",
+			},
 			&cli.StringFlag{
-				Name:        "mysql-database-Port",
-				EnvVars:     []string{"AC_PORT"},
-				Usage:       "Sample database port",
-				Destination: &startRunner.MySQLDatabasePort,
+				Name:        "smtp-host",
+				EnvVars:     []string{"AC_SMTP_HOST"},
+				Usage:       "smtp relay host, emails go to the outbox when empty",
+				Destination: &startRunner.SMTPHost,
 				Value:       "",
 			},
 			&cli.StringFlag{
-				Name:        "jwt_issuer",
-				EnvVars:     []string{"JWTISSUER"},
-				Usage:       "Sample database port",
-				Destination: &utils.JWTISSUER,
+				Name:        "smtp-port",
+				EnvVars:     []string{"AC_SMTP_PORT"},
+				Usage:       "smtp relay port",
+				Destination: &startRunner.SMTPPort,
+				Value:       "587",
+			},
+			&cli.StringFlag{
+				Name:        "smtp-user",
+				EnvVars:     []string{"AC_SMTP_USER"},
+				Usage:       "smtp username",
+				Destination: &startRunner.SMTPUser,
 				Value:       "",
 			},
 			&cli.StringFlag{
-				Name:        "secret",
-				EnvVars:     []string{"AC_PORT"},
-				Usage:       "Sample database port",
-				Destination: &utils.MYSTIC,
+				Name:        "smtp-password",
+				EnvVars:     []string{"AC_SMTP_PASSWORD"},
+				Usage:       "smtp password",
+				Destination: &startRunner.SMTPPassword,
 				Value:       "",
 			},
-		},
+			&cli.StringFlag{
+				Name:        "unverified-accounts",
+				EnvVars:     []string{"AC_UNVERIFIED_ACCOUNTS"},
+				Usage:       "what accounts with an unverified email may do: allow, restrict (read only) or reject",
+				Destination: &utils.UnverifiedPolicy,
+				Value:       utils.UnverifiedRestrict,
+			},
+			&cli.StringFlag{
+				Name:        "registration-policy",
+				EnvVars:     []string{"AC_REGISTRATION_POLICY"},
+				Usage:       "who may register: open, invite (invite code required) or domain (email in registration-domains)",
+				Destination: &utils.RegistrationPolicy,
+				Value:       utils.RegistrationOpen,
+			},
+			&cli.StringSliceFlag{
+				Name:        "registration-domains",
+				EnvVars:     []string{"AC_REGISTRATION_DOMAINS"},
+				Usage:       "email domains allowed to register under the domain policy, e.g. lasu.edu.ng",
+				Destination: &startRunner.RegistrationDomains,
+			},
+			&cli.StringSliceFlag{
+				Name:        "blocked-email-domains",
+				EnvVars:     []string{"AC_BLOCKED_EMAIL_DOMAINS"},
+				Usage:       "email domains that may never register, defaults to common disposable email providers",
+				Destination: &startRunner.BlockedEmailDomains,
+				Value:       cli.NewStringSlice(utils.DisposableEmailDomains...),
+			},
+			&cli.DurationFlag{
+				Name:        "account-deletion-grace",
+				EnvVars:     []string{"AC_ACCOUNT_DELETION_GRACE"},
+				Usage:       "how long after a deletion request accounts are anonymized, the user can cancel until then",
+				Destination: &utils.AccountDeletionGrace,
+				Value:       utils.AccountDeletionGrace,
+			},
+			&cli.DurationFlag{
+				Name:        "recommendations-max-age",
+				EnvVars:     []string{"AC_RECOMMENDATIONS_MAX_AGE"},
+				Usage:       "how long cached people you may know recommendations are served before they are recomputed",
+				Destination: &utils.RecommendationsMaxAge,
+				Value:       utils.RecommendationsMaxAge,
+			},
+			&cli.StringFlag{
+				Name:        "media-dir",
+				EnvVars:     []string{"AC_MEDIA_DIR"},
+				Usage:       "directory uploaded profile pictures are stored in",
+				Destination: &startRunner.MediaDir,
+				Value:       "./media",
+			},
+			&cli.StringFlag{
+				Name:        "media-url",
+				EnvVars:     []string{"AC_MEDIA_URL"},
+				Usage:       "base url uploads are served from, /media is served by this api",
+				Destination: &startRunner.MediaURL,
+				Value:       "/media",
+			},
+			&cli.Int64Flag{
+				Name:        "max-upload-bytes",
+				EnvVars:     []string{"AC_MAX_UPLOAD_BYTES"},
+				Usage:       "largest accepted profile picture upload in bytes",
+				Destination: &utils.MaxUploadBytes,
+				Value:       utils.MaxUploadBytes,
+			},
+			&cli.BoolFlag{
+				Name:        "trust-proxy-headers",
+				EnvVars:     []string{"AC_TRUST_PROXY_HEADERS"},
+				Usage:       "take the client ip from X-Forwarded-For, only enable behind a proxy that sets it",
+				Destination: &utils.TrustProxyHeaders,
+			},
+			&cli.IntFlag{
+				Name:        "login-max-failures",
+				EnvVars:     []string{"AC_LOGIN_MAX_FAILURES"},
+				Usage:       "failed sign ins in a row before an email is locked",
+				Destination: &utils.MaxLoginFailures,
+				Value:       utils.MaxLoginFailures,
+			},
+			&cli.DurationFlag{
+				Name:        "login-lockout",
+				EnvVars:     []string{"AC_LOGIN_LOCKOUT"},
+				Usage:       "how long a locked email has to wait",
+				Destination: &utils.LoginLockout,
+				Value:       utils.LoginLockout,
+			},
+			&cli.IntFlag{
+				Name:        "login-ip-limit",
+				EnvVars:     []string{"AC_LOGIN_IP_LIMIT"},
+				Usage:       "sign in attempts allowed per ip within login-ip-window",
+				Destination: &utils.LoginIPLimit,
+				Value:       utils.LoginIPLimit,
+			},
+			&cli.DurationFlag{
+				Name:        "login-ip-window",
+				EnvVars:     []string{"AC_LOGIN_IP_WINDOW"},
+				Usage:       "sliding window for login-ip-limit",
+				Destination: &utils.LoginIPWindow,
+				Value:       utils.LoginIPWindow,
+			},
+		),
 
 		Action: startRunner.Run,
 	}
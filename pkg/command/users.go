@@ -0,0 +1,57 @@
+package command
+
+import (
+	"github.com/jim-nnamdi/jinx/pkg/runner"
+	"github.com/urfave/cli/v2"
+)
+
+func UsersCommand() *cli.Command {
+	var (
+		usersRunner = &runner.UsersRunner{}
+	)
+
+	cmd := &cli.Command{
+		Name:  "users",
+		Usage: "manage user accounts",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "require-2fa",
+				Usage: "require an account to use two factor authentication",
+				Flags: append(databaseFlags(&usersRunner.DatabaseConfig),
+					&cli.StringFlag{
+						Name:        "email",
+						Usage:       "email of the account",
+						Destination: &usersRunner.Email,
+						Required:    true,
+					},
+					&cli.BoolFlag{
+						Name:        "disable",
+						Usage:       "lift the requirement instead",
+						Destination: &usersRunner.Disable,
+					},
+				),
+				Action: usersRunner.RequireTwoFactor,
+			},
+			{
+				Name:  "set-role",
+				Usage: "assign a role (member, moderator, admin) to an account",
+				Flags: append(databaseFlags(&usersRunner.DatabaseConfig),
+					&cli.StringFlag{
+						Name:        "email",
+						Usage:       "email of the account",
+						Destination: &usersRunner.Email,
+						Required:    true,
+					},
+					&cli.StringFlag{
+						Name:        "role",
+						Usage:       "role to assign",
+						Destination: &usersRunner.Role,
+						Required:    true,
+					},
+				),
+				Action: usersRunner.SetRole,
+			},
+		},
+	}
+	return cmd
+}
@@ -14,11 +14,20 @@ type Database interface {
 	io.Closer
 
 	/* user interaction queries */
-	CreateUser(ctx context.Context, username string, password string, email string, degree string, gradyear string, currentjob string, phone string, sessionkey string, profilepicture string, linkedinprofile string, twitterprofile string) (bool, error)
+	CreateUser(ctx context.Context, username string, password string, email string, degree string, gradyear string, currentjob string, phone string, profilepicture string, linkedinprofile string, twitterprofile string) (bool, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	CheckUser(ctx context.Context, email string, password string) (*model.User, error)
-	GetBySessionKey(ctx context.Context, sessionkey string) (*model.User, error)
+	GetUserByID(ctx context.Context, userID int) (*model.User, error)
+	MarkEmailVerified(ctx context.Context, userID int) (bool, error)
+	MarkVerificationSent(ctx context.Context, userID int, notBefore time.Time) (bool, error)
 	GetUserPortfolio(ctx context.Context, user_email string) (*[]model.PortfolioOrder, error)
+	UpdateUserProfile(ctx context.Context, user *model.User) (bool, error)
+	SetPendingEmail(ctx context.Context, userID int, email string) error
+	ConfirmEmailChange(ctx context.Context, userID int, email string) (bool, error)
+	SetProfilePicture(ctx context.Context, userID int, url string) error
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetPrivacySettings(ctx context.Context, userID int) (model.PrivacySettings, error)
+	SetPrivacySettings(ctx context.Context, userID int, settings model.PrivacySettings) error
 
 	/* transactions */
 	GetUserTransactions(ctx context.Context, user_email string) (*[]model.Transaction, error)
@@ -28,9 +37,17 @@ type Database interface {
 	AddNewForumPost(ctx context.Context, title string, description string, author string, slug string, created_at time.Time, updated_at time.Time) (bool, error)
 	GetSingleForumPost(ctx context.Context, slug string) (*model.Forum, error)
 	GetAllForums(ctx context.Context) (*[]model.Forum, error)
-	GetCommentsByForumID(ctx context.Context, forumID int) ([]model.Comment, error)
+	GetCommentsByForumID(ctx context.Context, forumID int, viewerID int, replies int) ([]model.Comment, error)
+	GetCommentReplies(ctx context.Context, parentID int, viewerID int, after *model.CommentCursor, limit int) ([]model.Comment, error)
+	GetCommentParent(ctx context.Context, id int) (*model.CommentParent, error)
+	AddReply(ctx context.Context, userID int, parentID int, comment string) (bool, error)
 	SendMessage(ctx context.Context, senderId int, receiverId int, message string, createdAt time.Time, updatedAt time.Time) (bool, error)
 	AddComment(ctx context.Context, userID int, forumID int, comment string) (bool, error)
+	UpdateForumPost(ctx context.Context, forumID int, editorID int, title string, description string, slug string, titleDiff string, descriptionDiff string) (bool, error)
+	DeleteForumPost(ctx context.Context, forumID int, editorID int) (bool, error)
+	GetForumRevisions(ctx context.Context, forumID int) ([]model.ForumRevision, error)
+	GetTakenForumSlugs(ctx context.Context, base string, forumID int) ([]string, error)
+	GetForumSlugTarget(ctx context.Context, slug string) (string, error)
 	CreateGroup(ctx context.Context, name string, userID int) (int, error)
 	AddGroupMember(ctx context.Context, groupID int, userID int) (bool, error)
 	SendGroupMessage(ctx context.Context, groupID int, userID int, message string) (bool, error)
@@ -38,4 +55,137 @@ type Database interface {
 	GetGroupCreator(ctx context.Context, groupID int) (*model.User, error)
 	CheckGroupMembership(ctx context.Context, groupID int, userID int) (bool, error)
 	FetchUserChats(ctx context.Context, userID1, userID2 int) ([]*model.Chat, error)
+
+	/* connections */
+	CreateConnectionRequest(ctx context.Context, requesterID int, recipientID int) (int, *model.ConnectionRequest, error)
+	GetConnectionRequest(ctx context.Context, requestID int) (*model.ConnectionRequest, error)
+	GetConnectionRequestBetween(ctx context.Context, userID1 int, userID2 int) (*model.ConnectionRequest, error)
+	UpdateConnectionRequestStatus(ctx context.Context, requestID int, fromStatus string, toStatus string) (bool, error)
+	GetIncomingConnectionRequests(ctx context.Context, userID int) ([]model.ConnectionRequest, error)
+	GetOutgoingConnectionRequests(ctx context.Context, userID int) ([]model.ConnectionRequest, error)
+	GetUserConnections(ctx context.Context, userID int) ([]model.Connection, error)
+	CheckConnection(ctx context.Context, userID1 int, userID2 int) (bool, error)
+
+	/* refresh tokens and access token revocation */
+	CreateRefreshToken(ctx context.Context, userID int, tokenHash string, sessionID string, expiresAt time.Time) (bool, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, tokenID int) (bool, error)
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeExpiredTokens(ctx context.Context) error
+
+	/* password resets */
+	CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (bool, error)
+	GetPasswordReset(ctx context.Context, tokenHash string) (*model.PasswordReset, error)
+	UsePasswordReset(ctx context.Context, resetID int, userID int, password string) (bool, error)
+	InvalidatePasswordResets(ctx context.Context, userID int) error
+	UpdateUserPassword(ctx context.Context, userID int, password string) (bool, error)
+
+	/* two factor authentication */
+	SaveTwoFactorSecret(ctx context.Context, userID int, secret string) error
+	GetTwoFactor(ctx context.Context, userID int) (*model.TwoFactor, error)
+	EnableTwoFactor(ctx context.Context, userID int) (bool, error)
+	DisableTwoFactor(ctx context.Context, userID int) error
+	UseTwoFactorStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	SetMFARequired(ctx context.Context, userID int, required bool) error
+
+	/* roles and permissions */
+	GetRoles(ctx context.Context) ([]model.Role, error)
+	GetRole(ctx context.Context, name string) (*model.Role, error)
+	SetUserRole(ctx context.Context, userID int, role string) error
+
+	/* login attempts */
+	GetLoginAttempt(ctx context.Context, email string) (*model.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, email string) (*model.LoginAttempt, error)
+	LockLogin(ctx context.Context, email string, until time.Time) error
+	ResetLoginAttempts(ctx context.Context, email string) error
+	PurgeLoginAttempts(ctx context.Context, before time.Time) error
+
+	/* sessions */
+	CreateSession(ctx context.Context, sessionID string, userID int, device string, ip string, expiresAt time.Time) error
+	GetSession(ctx context.Context, sessionID string) (*model.Session, error)
+	GetUserSessions(ctx context.Context, userID int) ([]model.Session, error)
+	TouchSession(ctx context.Context, sessionID string, ip string) error
+	ExtendSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUserSessions(ctx context.Context, userID int, exceptID string) error
+
+	/* personal access tokens */
+	CreatePersonalAccessToken(ctx context.Context, userID int, name string, tokenHash string, prefix string, scopes []string, expiresAt *time.Time) (int, error)
+	GetPersonalAccessToken(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error)
+	GetUserPersonalAccessTokens(ctx context.Context, userID int) ([]model.PersonalAccessToken, error)
+	TouchPersonalAccessToken(ctx context.Context, tokenID int, ip string) error
+	RevokePersonalAccessToken(ctx context.Context, tokenID int, userID int) (bool, error)
+	RevokeUserAccessTokens(ctx context.Context, userID int) error
+
+	/* graduate registry */
+	ImportGraduates(ctx context.Context, graduates []model.Graduate) (int, error)
+	GetGraduate(ctx context.Context, matricNumber string) (*model.Graduate, error)
+	ClaimGraduate(ctx context.Context, graduateID int, userID int) (bool, error)
+
+	/* invite codes */
+	CreateInviteCode(ctx context.Context, codeHash string, prefix string, note string, maxUses int, expiresAt *time.Time, createdBy int) (int, error)
+	GetInviteCodes(ctx context.Context) ([]model.InviteCode, error)
+	RedeemInviteCode(ctx context.Context, codeHash string) (bool, error)
+	ReleaseInviteCode(ctx context.Context, codeHash string) error
+	RevokeInviteCode(ctx context.Context, inviteID int) (bool, error)
+
+	/* data export and account deletion */
+	ExportUserData(ctx context.Context, user *model.User) (*model.UserExport, error)
+	ScheduleAccountDeletion(ctx context.Context, userID int, at time.Time) error
+	CancelAccountDeletion(ctx context.Context, userID int) (bool, error)
+	GetAccountsDueForDeletion(ctx context.Context) ([]int, error)
+	AnonymizeUser(ctx context.Context, userID int) error
+
+	/* work history and education */
+	CreateExperience(ctx context.Context, experience *model.Experience) (int, error)
+	GetExperience(ctx context.Context, userID int, experienceID int) (*model.Experience, error)
+	GetUserExperiences(ctx context.Context, userID int) ([]model.Experience, error)
+	UpdateExperience(ctx context.Context, experience *model.Experience) error
+	DeleteExperience(ctx context.Context, userID int, experienceID int) (bool, error)
+	CreateEducation(ctx context.Context, education *model.Education) (int, error)
+	GetEducation(ctx context.Context, userID int, educationID int) (*model.Education, error)
+	GetUserEducations(ctx context.Context, userID int) ([]model.Education, error)
+	UpdateEducation(ctx context.Context, education *model.Education) error
+	DeleteEducation(ctx context.Context, userID int, educationID int) (bool, error)
+
+	/* skills and endorsements */
+	AddUserSkill(ctx context.Context, userID int, name string, key string) (int, bool, error)
+	RemoveUserSkill(ctx context.Context, userID int, skillID int) (bool, error)
+	GetUserSkills(ctx context.Context, userID int, viewerID int) ([]model.UserSkill, error)
+	SearchSkills(ctx context.Context, prefix string, limit int) ([]model.Skill, error)
+	EndorseSkill(ctx context.Context, endorserID int, userID int, skillID int) (bool, error)
+	WithdrawEndorsement(ctx context.Context, endorserID int, userID int, skillID int) (bool, error)
+
+	/* alumni directory */
+	SearchUsers(ctx context.Context, search model.DirectorySearch) ([]model.DirectoryEntry, error)
+
+	/* people you may know */
+	GetRecommendationSignals(ctx context.Context, userID int, degree string, gradYearFrom string, gradYearTo string) ([]model.RecommendationSignals, error)
+	SaveRecommendations(ctx context.Context, userID int, recommendations []model.Recommendation) error
+	GetRecommendationsAt(ctx context.Context, userID int) (*time.Time, error)
+	GetStaleRecommendationUserIDs(ctx context.Context, before time.Time, afterID int, limit int) ([]int, error)
+	GetRecommendations(ctx context.Context, userID int, limit int) ([]model.Recommendation, error)
+
+	/* blocks and mutes */
+	BlockUser(ctx context.Context, blockerID int, blockedID int) (bool, error)
+	UnblockUser(ctx context.Context, blockerID int, blockedID int) (bool, error)
+	GetBlockedUsers(ctx context.Context, userID int) ([]model.RestrictedUser, error)
+	IsBlocked(ctx context.Context, userID1 int, userID2 int) (bool, error)
+	MuteUser(ctx context.Context, muterID int, mutedID int) (bool, error)
+	UnmuteUser(ctx context.Context, muterID int, mutedID int) (bool, error)
+	GetMutedUsers(ctx context.Context, userID int) ([]model.RestrictedUser, error)
+	IsMuted(ctx context.Context, muterID int, mutedID int) (bool, error)
+
+	/* follows */
+	Follow(ctx context.Context, followerID int, followeeID int) (string, bool, error)
+	RemoveFollow(ctx context.Context, followerID int, followeeID int) (bool, error)
+	ApproveFollow(ctx context.Context, followerID int, followeeID int) (bool, error)
+	GetFollowStatus(ctx context.Context, followerID int, followeeID int) (string, error)
+	GetFollowStats(ctx context.Context, userID int, viewerID int) (*model.FollowStats, error)
+	SetFollowApproval(ctx context.Context, userID int, required bool) error
+	GetFollowers(ctx context.Context, userID int, status string, after *model.FollowCursor, limit int) ([]model.FollowEntry, error)
+	GetFollowing(ctx context.Context, userID int, status string, after *model.FollowCursor, limit int) ([]model.FollowEntry, error)
 }
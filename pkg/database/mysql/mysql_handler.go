@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"time"
 
+	driver "github.com/go-sql-driver/mysql"
 	"github.com/jim-nnamdi/jinx/pkg/model"
 )
 
@@ -17,10 +19,11 @@ var (
 )
 
 type mysqlDatabase struct {
+	conn *sql.DB // for transactions and queries built per request
+
 	createUser           *sql.Stmt
 	checkUser            *sql.Stmt
 	getUserByEmail       *sql.Stmt
-	getBySessionKey      *sql.Stmt
 	getUserPortfolios    *sql.Stmt
 	getUserTransactions  *sql.Stmt
 	createNewTransaction *sql.Stmt
@@ -37,33 +40,425 @@ type mysqlDatabase struct {
 	getGroupAdmin        *sql.Stmt
 	checkIfMember        *sql.Stmt
 	getChats             *sql.Stmt
+
+	lockConnectionPair            *sql.Stmt
+	createConnectionRequest       *sql.Stmt
+	getConnectionRequest          *sql.Stmt
+	getConnectionRequestBetween   *sql.Stmt
+	updateConnectionRequestStatus *sql.Stmt
+	getIncomingConnectionRequests *sql.Stmt
+	getOutgoingConnectionRequests *sql.Stmt
+	getUserConnections            *sql.Stmt
+	checkConnection               *sql.Stmt
+
+	getUserByID             *sql.Stmt
+	createRefreshToken      *sql.Stmt
+	getRefreshToken         *sql.Stmt
+	markRefreshTokenUsed    *sql.Stmt
+	revokeSessionTokens     *sql.Stmt
+	revokeUserRefreshTokens *sql.Stmt
+	revokeAccessToken       *sql.Stmt
+	isAccessTokenRevoked    *sql.Stmt
+	purgeRefreshTokens      *sql.Stmt
+	purgeRevokedTokens      *sql.Stmt
+
+	createPasswordReset      *sql.Stmt
+	getPasswordReset         *sql.Stmt
+	usePasswordReset         *sql.Stmt
+	invalidatePasswordResets *sql.Stmt
+	updateUserPassword       *sql.Stmt
+
+	markEmailVerified    *sql.Stmt
+	markVerificationSent *sql.Stmt
+
+	updateUserProfile  *sql.Stmt
+	setPendingEmail    *sql.Stmt
+	confirmEmailChange *sql.Stmt
+	setProfilePicture  *sql.Stmt
+	getUserByUsername  *sql.Stmt
+	getPrivacy         *sql.Stmt
+	setPrivacy         *sql.Stmt
+
+	saveTwoFactorSecret *sql.Stmt
+	getTwoFactor        *sql.Stmt
+	enableTwoFactor     *sql.Stmt
+	deleteTwoFactor     *sql.Stmt
+	useTwoFactorStep    *sql.Stmt
+	deleteRecoveryCodes *sql.Stmt
+	addRecoveryCode     *sql.Stmt
+	useRecoveryCode     *sql.Stmt
+	setMFARequired      *sql.Stmt
+
+	getRoles    *sql.Stmt
+	getRole     *sql.Stmt
+	setUserRole *sql.Stmt
+
+	recordLoginFailure *sql.Stmt
+	getLoginAttempt    *sql.Stmt
+	lockLogin          *sql.Stmt
+	resetLoginAttempts *sql.Stmt
+	purgeLoginAttempts *sql.Stmt
+
+	createSession      *sql.Stmt
+	getSession         *sql.Stmt
+	getUserSessions    *sql.Stmt
+	touchSession       *sql.Stmt
+	extendSession      *sql.Stmt
+	revokeSession      *sql.Stmt
+	revokeUserSessions *sql.Stmt
+	purgeSessions      *sql.Stmt
+
+	createAccessToken      *sql.Stmt
+	getAccessToken         *sql.Stmt
+	getUserAccessTokens    *sql.Stmt
+	touchAccessToken       *sql.Stmt
+	revokeAccessTokenByID  *sql.Stmt
+	revokeUserAccessTokens *sql.Stmt
+
+	importGraduate *sql.Stmt
+	getGraduate    *sql.Stmt
+	claimGraduate  *sql.Stmt
+	verifyAlumnus  *sql.Stmt
+
+	createInviteCode  *sql.Stmt
+	getInviteCodes    *sql.Stmt
+	redeemInviteCode  *sql.Stmt
+	releaseInviteCode *sql.Stmt
+	revokeInviteCode  *sql.Stmt
+
+	exportForumPosts          *sql.Stmt
+	exportComments            *sql.Stmt
+	exportChats               *sql.Stmt
+	exportGroupMessages       *sql.Stmt
+	exportTransactions        *sql.Stmt
+	scheduleAccountDeletion   *sql.Stmt
+	cancelAccountDeletion     *sql.Stmt
+	getAccountsDueForDeletion *sql.Stmt
+	anonymizeUser             []*sql.Stmt // run in order by AnonymizeUser, each takes the user id
+
+	createExperience *sql.Stmt
+	getExperience    *sql.Stmt
+	getExperiences   *sql.Stmt
+	updateExperience *sql.Stmt
+	deleteExperience *sql.Stmt
+	createEducation  *sql.Stmt
+	getEducation     *sql.Stmt
+	getEducations    *sql.Stmt
+	updateEducation  *sql.Stmt
+	deleteEducation  *sql.Stmt
+
+	upsertSkill         *sql.Stmt
+	addUserSkill        *sql.Stmt
+	removeUserSkill     *sql.Stmt
+	getUserSkills       *sql.Stmt
+	searchSkills        *sql.Stmt
+	endorseSkill        *sql.Stmt
+	withdrawEndorsement *sql.Stmt
+
+	getRecommendationSignals      *sql.Stmt
+	deleteRecommendations         *sql.Stmt
+	insertRecommendation          *sql.Stmt
+	setRecommendationsAt          *sql.Stmt
+	getRecommendationsAt          *sql.Stmt
+	getStaleRecommendationUserIDs *sql.Stmt
+
+	blockUser                *sql.Stmt
+	removeBlockedConnections *sql.Stmt
+	unblockUser              *sql.Stmt
+	getBlockedUsers          *sql.Stmt
+	checkBlocked             *sql.Stmt
+	muteUser                 *sql.Stmt
+	unmuteUser               *sql.Stmt
+	getMutedUsers            *sql.Stmt
+	checkMuted               *sql.Stmt
+
+	follow               *sql.Stmt
+	removeFollow         *sql.Stmt
+	approveFollow        *sql.Stmt
+	getFollowStatus      *sql.Stmt
+	getFollowStats       *sql.Stmt
+	setFollowApproval    *sql.Stmt
+	acceptPendingFollows *sql.Stmt
+	removeBlockedFollows *sql.Stmt
+	exportFollows        *sql.Stmt
+
+	updateForumPost   *sql.Stmt
+	deleteForumPost   *sql.Stmt
+	addForumRevision  *sql.Stmt
+	getForumRevisions *sql.Stmt
+
+	saveOldForumSlug   *sql.Stmt
+	reuseOldForumSlug  *sql.Stmt
+	getForumSlugTarget *sql.Stmt
+
+	addReply         *sql.Stmt
+	getCommentParent *sql.Stmt
+}
+
+// userColumns lists the users columns in the order scanUser reads them.
+const userColumns = "id, username, password, email, COALESCE(pending_email, ''), degree, grad_year, current_job, phone, profile_picture, linkedin_profile, twitter_profile, email_verified_at, verification_sent_at, mfa_required, role, alumni_verified_at, deletion_scheduled_at, deleted_at, created_at, updated_at"
+
+const sessionQuery = "SELECT id, user_id, COALESCE(device, ''), COALESCE(ip, ''), created_at, last_seen_at, expires_at, revoked_at FROM sessions"
+
+const accessTokenQuery = "SELECT id, user_id, name, token_hash, token_prefix, scopes, last_used_at, COALESCE(last_used_ip, ''), expires_at, revoked_at, created_at FROM personal_access_tokens"
+
+// anonymizeUserQueries strip a user's personal data while keeping what they
+// wrote, which is then shown under the anonymized account. The user row is
+// updated last since the first queries look up the current email.
+var anonymizeUserQueries = []string{
+	"UPDATE forums SET author = NULL WHERE author = (SELECT email FROM users WHERE id = ?)",
+	"UPDATE transactions t JOIN users u ON u.id = ? SET t.from_user_email = IF(t.from_user_email = u.email, NULL, t.from_user_email), t.to_user_email = IF(t.to_user_email = u.email, NULL, t.to_user_email), t.user_email = IF(t.user_email = u.email, NULL, t.user_email) WHERE u.email IN (t.from_user_email, t.to_user_email, t.user_email)",
+	"UPDATE portfolio_orders SET user_email = NULL WHERE user_email = (SELECT email FROM users WHERE id = ?)",
+	"DELETE FROM login_attempts WHERE email = (SELECT LOWER(email) FROM users WHERE id = ?)",
+	"DELETE FROM sessions WHERE user_id = ?",
+	"DELETE FROM personal_access_tokens WHERE user_id = ?",
+	"DELETE FROM password_resets WHERE user_id = ?",
+	"DELETE FROM recovery_codes WHERE user_id = ?",
+	"DELETE FROM two_factor WHERE user_id = ?",
+	"DELETE FROM connection_requests WHERE ? IN (requester_id, recipient_id)",
+	"DELETE FROM group_members WHERE user_id = ?",
+	"DELETE FROM profile_privacy WHERE user_id = ?",
+	"DELETE FROM experiences WHERE user_id = ?",
+	"DELETE FROM educations WHERE user_id = ?",
+	"DELETE FROM skill_endorsements WHERE endorser_id = ?",
+	"DELETE FROM user_skills WHERE user_id = ?",
+	"DELETE FROM recommendations WHERE ? IN (user_id, candidate_id)",
+	"DELETE FROM user_blocks WHERE ? IN (blocker_id, blocked_id)",
+	"DELETE FROM user_mutes WHERE ? IN (muter_id, muted_id)",
+	"DELETE FROM follows WHERE ? IN (follower_id, followee_id)",
+	"UPDATE graduate_registry SET claimed_by = NULL, claimed_at = NULL WHERE claimed_by = ?",
+	"UPDATE users SET username = CONCAT('deleted-user-', id), email = CONCAT('deleted-', id, '@deleted.invalid'), password = '', degree = '', grad_year = '', current_job = '', phone = '', profile_picture = '', linkedin_profile = '', twitter_profile = '', email_verified_at = NULL, verification_sent_at = NULL, mfa_required = 0, role = 'member', alumni_verified_at = NULL, deletion_scheduled_at = NULL, recommendations_at = NULL, follow_approval = 0, deleted_at = NOW() WHERE id = ?",
 }
 
+// recommendationSignalsQuery collects candidates for a user's "people you may
+// know" with what they have in common: the same degree within a range of
+// graduation years, groups, forums commented on and connections. Members the
+// user is already connected to, has a pending request with or a block
+// between are left out.
+const recommendationSignalsQuery = "SELECT u.id, COALESCE(u.degree, ''), COALESCE(u.grad_year, ''), SUM(c.shared_groups), SUM(c.shared_forums), SUM(c.mutual_connections) FROM (" +
+	"SELECT id AS candidate_id, 0 AS shared_groups, 0 AS shared_forums, 0 AS mutual_connections FROM users WHERE degree = ? AND degree <> '' AND grad_year BETWEEN ? AND ?" +
+	" UNION ALL SELECT theirs.user_id, COUNT(DISTINCT theirs.group_id), 0, 0 FROM group_members mine JOIN group_members theirs ON theirs.group_id = mine.group_id WHERE mine.user_id = ? GROUP BY theirs.user_id" +
+	" UNION ALL SELECT theirs.user_id, 0, COUNT(DISTINCT theirs.forum_id), 0 FROM comments mine JOIN comments theirs ON theirs.forum_id = mine.forum_id WHERE mine.user_id = ? GROUP BY theirs.user_id" +
+	" UNION ALL SELECT fof.recipient_id, 0, 0, COUNT(DISTINCT f.friend_id) FROM (" + friendsQuery + ") f JOIN connection_requests fof ON fof.requester_id = f.friend_id AND fof.status = 'accepted' GROUP BY fof.recipient_id" +
+	" UNION ALL SELECT fof.requester_id, 0, 0, COUNT(DISTINCT f.friend_id) FROM (" + friendsQuery + ") f JOIN connection_requests fof ON fof.recipient_id = f.friend_id AND fof.status = 'accepted' GROUP BY fof.requester_id" +
+	") c JOIN users u ON u.id = c.candidate_id WHERE u.id <> ? AND u.deleted_at IS NULL AND u.deletion_scheduled_at IS NULL" +
+	" AND NOT EXISTS (SELECT 1 FROM connection_requests cr WHERE cr.status IN ('pending', 'accepted') AND ((cr.requester_id = ? AND cr.recipient_id = u.id) OR (cr.requester_id = u.id AND cr.recipient_id = ?)))" +
+	" AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?))" +
+	" GROUP BY u.id, u.degree, u.grad_year"
+
+// blockedBetween matches a block between the two users given, whichever of
+// them blocked the other.
+const blockedBetween = "SELECT 1 FROM user_blocks WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)"
+
+// friendsQuery selects the ids of a user's connections as friend_id.
+const friendsQuery = "SELECT IF(requester_id = ?, recipient_id, requester_id) AS friend_id FROM connection_requests WHERE status = 'accepted' AND (requester_id = ? OR recipient_id = ?)"
+
+const experienceQuery = "SELECT id, user_id, company, title, COALESCE(location, ''), start_month, COALESCE(end_month, ''), COALESCE(description, ''), created_at, updated_at FROM experiences"
+
+const educationQuery = "SELECT id, user_id, institution, COALESCE(degree, ''), COALESCE(field_of_study, ''), COALESCE(start_year, ''), COALESCE(end_year, ''), created_at, updated_at FROM educations"
+
+const roleQuery = "SELECT r.name, COALESCE(r.description, ''), r.require_2fa, rp.permission FROM roles r LEFT JOIN role_permissions rp ON rp.role = r.name"
+
+const connectionRequestQuery = "SELECT cr.id, cr.requester_id, ru.username, cr.recipient_id, au.username, cr.status, cr.created_at, cr.updated_at FROM connection_requests cr JOIN users ru ON cr.requester_id = ru.id JOIN users au ON cr.recipient_id = au.id"
+
 func NewMySQLDatabase(db *sql.DB) (*mysqlDatabase, error) {
 	var (
-		createUser           = "INSERT INTO users(username, password, email, degree, grad_year,current_job, phone, session_key,profile_picture,linkedin_profile,twitter_profile) VALUES(?,?,?,?,?,?,?,?,?,?,?);"
-		checkUser            = "SELECT * FROM users where email = ? AND password=?;"
-		getUserByEmail       = "SELECT * FROM users where email = ?;"
-		getBySessionKey      = "SELECT * FROM users where session_key=?;"
+		createUser           = "INSERT INTO users(username, password, email, degree, grad_year,current_job, phone, profile_picture,linkedin_profile,twitter_profile) VALUES(?,?,?,?,?,?,?,?,?,?);"
+		checkUser            = "SELECT " + userColumns + " FROM users where email = ? AND password=?;"
+		getUserByEmail       = "SELECT " + userColumns + " FROM users where email = ?;"
 		getUserPortfolios    = "SELECT * FROM portfolio_orders WHERE `user_email` = ?;"
 		getUserTransactions  = "SELECT * FROM transactions WHERE `user_email` = ?;"
 		createNewTransaction = "INSERT INTO transactions(from_user_id,from_user_email, to_user_id, to_user_email,type,created_at,updated_at,amount,user_email) VALUES(?,?,?,?,?,?,?,?,?);"
 		addNewForumPost      = "INSERT INTO forums(title, description, author, slug, created_at, updated_at) VALUES (?,?,?,?,?,?)"
-		getSingleForumPost   = "SELECT * FROM forums WHERE `slug` = ?;"
-		getAllForums         = "SELECT title, description, author, slug, created_at, updated_at FROM forums"
-		sendMessage          = "INSERT INTO chat_messages (sender, recipient, message, created_at,updated_at) VALUES (?,?,?,?,?)"
-		addComment           = "INSERT INTO comments (user_id, forum_id, comment) VALUES (?, ?, ?)"
-		getCommentsByForum   = "SELECT c.id, u.username, c.comment, c.created_at FROM comments c JOIN users u ON c.user_id = u.id WHERE c.forum_id = ? ORDER BY c.created_at ASC"
+		getSingleForumPost   = "SELECT f.id, f.title, f.description, COALESCE(f.author, ''), COALESCE(u.username, ''), f.slug, f.created_at, f.updated_at, f.deleted_at FROM forums f LEFT JOIN users u ON u.email = f.author WHERE f.slug = ?"
+		getAllForums         = "SELECT f.id, f.title, f.description, COALESCE(f.author, ''), COALESCE(u.username, ''), f.slug, f.created_at, f.updated_at FROM forums f LEFT JOIN users u ON u.email = f.author WHERE f.deleted_at IS NULL ORDER BY f.created_at DESC, f.id DESC"
+		sendMessage          = "INSERT INTO chat_messages (sender, recipient, message, created_at,updated_at) SELECT ?,?,?,?,? FROM DUAL WHERE NOT EXISTS (" + blockedBetween + ")"
+		addComment           = "INSERT INTO comments (user_id, forum_id, comment) SELECT ?, id, ? FROM forums WHERE id = ? AND deleted_at IS NULL"
+		getCommentsByForum   = "WITH RECURSIVE ranked AS (SELECT c.id, c.parent_id, c.depth, c.user_id, c.comment, c.created_at, ROW_NUMBER() OVER (PARTITION BY c.parent_id ORDER BY c.created_at, c.id) AS position, COUNT(*) OVER (PARTITION BY c.parent_id) AS siblings FROM comments c WHERE c.forum_id = ? AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = ? AND m.muted_id = c.user_id) AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = ? AND b.blocked_id = c.user_id)), shown AS (SELECT id, parent_id, depth, user_id, comment, created_at, siblings FROM ranked WHERE parent_id IS NULL UNION ALL SELECT r.id, r.parent_id, r.depth, r.user_id, r.comment, r.created_at, r.siblings FROM ranked r JOIN shown s ON r.parent_id = s.id WHERE r.position <= ?) SELECT s.id, s.parent_id, s.depth, u.username, s.comment, s.created_at, s.siblings FROM shown s JOIN users u ON u.id = s.user_id ORDER BY s.created_at ASC, s.id ASC"
 		createGroup          = "INSERT INTO groups (name, created_by) VALUES (?,?)"
 		addGroupMember       = "INSERT INTO group_members (group_id, user_id) VALUES (?, ?)"
 		sendGroupMessage     = "INSERT INTO group_messages (group_id, user_id, message) VALUES (?, ?, ?)"
 		getGroupMessages     = "SELECT gm.id, u.username, gm.message, gm.created_at FROM group_messages gm JOIN users u ON gm.user_id = u.id WHERE gm.group_id = ? ORDER BY gm.created_at ASC"
 		getGroupAdmin        = "SELECT u.id, u.username, u.email FROM groups g JOIN users u ON g.created_by = u.id WHERE g.id = ?"
 		checkIfMember        = "SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ?"
-		getChats             = "SELECT id, sender, recipient, message, created_at, updated_at FROM chat_messages WHERE (sender = ? AND recipient = ?) OR (sender = ? AND recipient = ?) ORDER BY created_at ASC"
+		getChats             = "SELECT id, sender, recipient, message, created_at, updated_at FROM chat_messages WHERE ((sender = ? AND recipient = ?) OR (sender = ? AND recipient = ?)) AND NOT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?) ORDER BY created_at ASC"
 		database             = &mysqlDatabase{}
 		err                  error
+
+		/* connections */
+		lockConnectionPair            = "SELECT id FROM users WHERE id IN (?, ?) ORDER BY id FOR UPDATE"
+		createConnectionRequest       = "INSERT INTO connection_requests (requester_id, recipient_id, status) VALUES (?, ?, 'pending')"
+		getConnectionRequest          = connectionRequestQuery + " WHERE cr.id = ?"
+		getConnectionRequestBetween   = connectionRequestQuery + " WHERE ((cr.requester_id = ? AND cr.recipient_id = ?) OR (cr.requester_id = ? AND cr.recipient_id = ?)) AND cr.status IN ('pending', 'accepted') ORDER BY cr.id DESC LIMIT 1"
+		updateConnectionRequestStatus = "UPDATE connection_requests SET status = ? WHERE id = ? AND status = ?"
+		getIncomingConnectionRequests = connectionRequestQuery + " WHERE cr.recipient_id = ? AND cr.status = 'pending' ORDER BY cr.created_at DESC"
+		getOutgoingConnectionRequests = connectionRequestQuery + " WHERE cr.requester_id = ? AND cr.status = 'pending' ORDER BY cr.created_at DESC"
+		getUserConnections            = "SELECT cr.id, u.id, u.username, COALESCE(u.degree, ''), COALESCE(u.grad_year, ''), cr.updated_at FROM connection_requests cr JOIN users u ON u.id = IF(cr.requester_id = ?, cr.recipient_id, cr.requester_id) WHERE cr.status = 'accepted' AND (cr.requester_id = ? OR cr.recipient_id = ?) ORDER BY cr.updated_at DESC"
+		checkConnection               = "SELECT COUNT(*) FROM connection_requests WHERE status = 'accepted' AND ((requester_id = ? AND recipient_id = ?) OR (requester_id = ? AND recipient_id = ?))"
+
+		/* tokens */
+		getUserByID             = "SELECT " + userColumns + " FROM users where id = ?;"
+		createRefreshToken      = "INSERT INTO refresh_tokens (user_id, token_hash, session_id, expires_at) VALUES (?, ?, ?, ?)"
+		getRefreshToken         = "SELECT id, user_id, token_hash, session_id, expires_at, used_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash = ?"
+		markRefreshTokenUsed    = "UPDATE refresh_tokens SET used_at = NOW() WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL"
+		revokeSessionTokens     = "UPDATE refresh_tokens SET revoked_at = NOW() WHERE session_id = ? AND revoked_at IS NULL"
+		revokeUserRefreshTokens = "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = ? AND session_id <> ? AND revoked_at IS NULL"
+		revokeAccessToken       = "INSERT IGNORE INTO revoked_tokens (jti, expires_at) VALUES (?, ?)"
+		isAccessTokenRevoked    = "SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?"
+		purgeRefreshTokens      = "DELETE FROM refresh_tokens WHERE expires_at < NOW()"
+		purgeRevokedTokens      = "DELETE FROM revoked_tokens WHERE expires_at < NOW()"
+
+		/* password resets */
+		createPasswordReset      = "INSERT INTO password_resets (user_id, token_hash, expires_at) VALUES (?, ?, ?)"
+		getPasswordReset         = "SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_resets WHERE token_hash = ?"
+		usePasswordReset         = "UPDATE password_resets SET used_at = NOW() WHERE id = ? AND used_at IS NULL"
+		invalidatePasswordResets = "UPDATE password_resets SET used_at = NOW() WHERE user_id = ? AND used_at IS NULL"
+		updateUserPassword       = "UPDATE users SET password = ? WHERE id = ?"
+
+		/* email verification */
+		markEmailVerified    = "UPDATE users SET email_verified_at = NOW() WHERE id = ? AND email_verified_at IS NULL"
+		markVerificationSent = "UPDATE users SET verification_sent_at = NOW() WHERE id = ? AND (verification_sent_at IS NULL OR verification_sent_at < ?)"
+
+		/* profiles */
+		updateUserProfile  = "UPDATE users SET username = ?, degree = ?, grad_year = ?, current_job = ?, phone = ?, linkedin_profile = ?, twitter_profile = ? WHERE id = ?"
+		setPendingEmail    = "UPDATE users SET pending_email = NULLIF(?, '') WHERE id = ?"
+		confirmEmailChange = "UPDATE users SET email = pending_email, pending_email = NULL, email_verified_at = NOW() WHERE id = ? AND pending_email = ?"
+		setProfilePicture  = "UPDATE users SET profile_picture = ? WHERE id = ?"
+		getUserByUsername  = "SELECT " + userColumns + " FROM users WHERE username = ?"
+		getPrivacy         = "SELECT field, visibility FROM profile_privacy WHERE user_id = ?"
+		setPrivacy         = "INSERT INTO profile_privacy (user_id, field, visibility) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE visibility = VALUES(visibility)"
+
+		/* two factor authentication */
+		saveTwoFactorSecret = "INSERT INTO two_factor (user_id, secret) VALUES (?, ?) ON DUPLICATE KEY UPDATE secret = VALUES(secret), enabled_at = NULL, last_used_step = 0"
+		getTwoFactor        = "SELECT user_id, secret, enabled_at, last_used_step, created_at FROM two_factor WHERE user_id = ?"
+		enableTwoFactor     = "UPDATE two_factor SET enabled_at = NOW() WHERE user_id = ? AND enabled_at IS NULL"
+		deleteTwoFactor     = "DELETE FROM two_factor WHERE user_id = ?"
+		useTwoFactorStep    = "UPDATE two_factor SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?"
+		deleteRecoveryCodes = "DELETE FROM recovery_codes WHERE user_id = ?"
+		addRecoveryCode     = "INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)"
+		useRecoveryCode     = "UPDATE recovery_codes SET used_at = NOW() WHERE user_id = ? AND code_hash = ? AND used_at IS NULL"
+		setMFARequired      = "UPDATE users SET mfa_required = ? WHERE id = ?"
+
+		/* roles and permissions */
+		getRoles    = roleQuery + " ORDER BY r.name, rp.permission"
+		getRole     = roleQuery + " WHERE r.name = ? ORDER BY rp.permission"
+		setUserRole = "UPDATE users SET role = ? WHERE id = ?"
+
+		/* login attempts */
+		recordLoginFailure = "INSERT INTO login_attempts (email, failures, last_failure_at) VALUES (?, 1, NOW()) ON DUPLICATE KEY UPDATE failures = failures + 1, last_failure_at = NOW()"
+		getLoginAttempt    = "SELECT email, failures, last_failure_at, locked_until FROM login_attempts WHERE email = ?"
+		lockLogin          = "UPDATE login_attempts SET locked_until = ? WHERE email = ?"
+		resetLoginAttempts = "DELETE FROM login_attempts WHERE email = ?"
+		purgeLoginAttempts = "DELETE FROM login_attempts WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < NOW())"
+
+		/* sessions */
+		createSession      = "INSERT INTO sessions (id, user_id, device, ip, last_seen_at, expires_at) VALUES (?, ?, ?, ?, NOW(), ?)"
+		getSession         = sessionQuery + " WHERE id = ?"
+		getUserSessions    = sessionQuery + " WHERE user_id = ? AND revoked_at IS NULL AND expires_at > NOW() ORDER BY last_seen_at DESC"
+		touchSession       = "UPDATE sessions SET last_seen_at = NOW(), ip = ? WHERE id = ? AND last_seen_at < NOW() - INTERVAL 1 MINUTE"
+		extendSession      = "UPDATE sessions SET expires_at = ? WHERE id = ? AND revoked_at IS NULL"
+		revokeSession      = "UPDATE sessions SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL"
+		revokeUserSessions = "UPDATE sessions SET revoked_at = NOW() WHERE user_id = ? AND id <> ? AND revoked_at IS NULL"
+		purgeSessions      = "DELETE FROM sessions WHERE expires_at < NOW()"
+
+		/* personal access tokens */
+		createAccessToken      = "INSERT INTO personal_access_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+		getAccessToken         = accessTokenQuery + " WHERE token_hash = ?"
+		getUserAccessTokens    = accessTokenQuery + " WHERE user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW()) ORDER BY created_at DESC"
+		touchAccessToken       = "UPDATE personal_access_tokens SET last_used_at = NOW(), last_used_ip = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL 1 MINUTE)"
+		revokeAccessTokenByID  = "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE id = ? AND user_id = ? AND revoked_at IS NULL"
+		revokeUserAccessTokens = "UPDATE personal_access_tokens SET revoked_at = NOW() WHERE user_id = ? AND revoked_at IS NULL"
+
+		/* graduate registry */
+		importGraduate = "INSERT INTO graduate_registry (matric_number, full_name, faculty, degree, grad_year) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE full_name = VALUES(full_name), faculty = VALUES(faculty), degree = VALUES(degree), grad_year = VALUES(grad_year)"
+		getGraduate    = "SELECT id, matric_number, full_name, faculty, degree, grad_year, claimed_by, claimed_at FROM graduate_registry WHERE matric_number = ?"
+		claimGraduate  = "UPDATE graduate_registry SET claimed_by = ?, claimed_at = NOW() WHERE id = ? AND claimed_by IS NULL"
+		verifyAlumnus  = "UPDATE users u JOIN graduate_registry g ON g.claimed_by = u.id SET u.alumni_verified_at = NOW(), u.degree = g.degree, u.grad_year = g.grad_year WHERE u.id = ? AND g.id = ?"
+
+		/* invite codes */
+		createInviteCode  = "INSERT INTO invite_codes (code_hash, code_prefix, note, max_uses, expires_at, created_by) VALUES (?, ?, ?, ?, ?, ?)"
+		getInviteCodes    = "SELECT id, code_prefix, COALESCE(note, ''), max_uses, uses, expires_at, created_by, created_at, revoked_at FROM invite_codes WHERE revoked_at IS NULL AND uses < max_uses AND (expires_at IS NULL OR expires_at > NOW()) ORDER BY created_at DESC"
+		redeemInviteCode  = "UPDATE invite_codes SET uses = uses + 1 WHERE code_hash = ? AND revoked_at IS NULL AND uses < max_uses AND (expires_at IS NULL OR expires_at > NOW())"
+		releaseInviteCode = "UPDATE invite_codes SET uses = uses - 1 WHERE code_hash = ? AND uses > 0"
+		revokeInviteCode  = "UPDATE invite_codes SET revoked_at = NOW() WHERE id = ? AND revoked_at IS NULL"
+
+		/* data export and account deletion */
+		exportForumPosts          = "SELECT id, title, description, COALESCE(author, ''), slug, created_at, updated_at, deleted_at FROM forums WHERE author = ? ORDER BY created_at"
+		exportComments            = "SELECT c.id, f.slug, c.parent_id, c.comment, c.created_at FROM comments c JOIN forums f ON f.id = c.forum_id WHERE c.user_id = ? ORDER BY c.created_at"
+		exportChats               = "SELECT id, COALESCE(sender, 0), COALESCE(recipient, 0), message, created_at, updated_at FROM chat_messages WHERE sender = ? OR recipient = ? ORDER BY created_at"
+		exportGroupMessages       = "SELECT gm.id, g.id, g.name, gm.message, gm.created_at FROM group_messages gm JOIN groups g ON g.id = gm.group_id WHERE gm.user_id = ? ORDER BY gm.created_at"
+		exportTransactions        = "SELECT id, COALESCE(from_user_id, 0), COALESCE(from_user_email, ''), COALESCE(to_user_id, 0), COALESCE(to_user_email, ''), COALESCE(type, ''), created_at, updated_at, COALESCE(amount, 0), COALESCE(user_email, '') FROM transactions WHERE from_user_id = ? OR to_user_id = ? OR user_email = ? ORDER BY created_at"
+		scheduleAccountDeletion   = "UPDATE users SET deletion_scheduled_at = ? WHERE id = ? AND deleted_at IS NULL"
+		cancelAccountDeletion     = "UPDATE users SET deletion_scheduled_at = NULL WHERE id = ? AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL"
+		getAccountsDueForDeletion = "SELECT id FROM users WHERE deletion_scheduled_at <= NOW() AND deleted_at IS NULL"
+
+		/* work history and education, current jobs first */
+		createExperience = "INSERT INTO experiences (user_id, company, title, location, start_month, end_month, description) VALUES (?, ?, ?, NULLIF(?, ''), ?, NULLIF(?, ''), NULLIF(?, ''))"
+		getExperience    = experienceQuery + " WHERE id = ? AND user_id = ?"
+		getExperiences   = experienceQuery + " WHERE user_id = ? ORDER BY end_month IS NULL DESC, end_month DESC, start_month DESC"
+		updateExperience = "UPDATE experiences SET company = ?, title = ?, location = NULLIF(?, ''), start_month = ?, end_month = NULLIF(?, ''), description = NULLIF(?, '') WHERE id = ? AND user_id = ?"
+		deleteExperience = "DELETE FROM experiences WHERE id = ? AND user_id = ?"
+		createEducation  = "INSERT INTO educations (user_id, institution, degree, field_of_study, start_year, end_year) VALUES (?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''))"
+		getEducation     = educationQuery + " WHERE id = ? AND user_id = ?"
+		getEducations    = educationQuery + " WHERE user_id = ? ORDER BY end_year IS NULL DESC, end_year DESC, start_year DESC"
+		updateEducation  = "UPDATE educations SET institution = ?, degree = NULLIF(?, ''), field_of_study = NULLIF(?, ''), start_year = NULLIF(?, ''), end_year = NULLIF(?, '') WHERE id = ? AND user_id = ?"
+		deleteEducation  = "DELETE FROM educations WHERE id = ? AND user_id = ?"
+
+		/* skills and endorsements, the upsert makes LAST_INSERT_ID the existing id too */
+		upsertSkill         = "INSERT INTO skills (name, skill_key) VALUES (?, ?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)"
+		addUserSkill        = "INSERT IGNORE INTO user_skills (user_id, skill_id) VALUES (?, ?)"
+		removeUserSkill     = "DELETE FROM user_skills WHERE user_id = ? AND skill_id = ?"
+		getUserSkills       = "SELECT s.id, s.name, COUNT(e.endorser_id), COALESCE(MAX(e.endorser_id = ?), 0) FROM user_skills us JOIN skills s ON s.id = us.skill_id LEFT JOIN skill_endorsements e ON e.user_id = us.user_id AND e.skill_id = us.skill_id WHERE us.user_id = ? GROUP BY s.id, s.name ORDER BY COUNT(e.endorser_id) DESC, s.name"
+		searchSkills        = "SELECT s.id, s.name, COUNT(us.user_id) FROM skills s LEFT JOIN user_skills us ON us.skill_id = s.id WHERE s.skill_key LIKE ? GROUP BY s.id, s.name ORDER BY COUNT(us.user_id) DESC, s.name LIMIT ?"
+		endorseSkill        = "INSERT IGNORE INTO skill_endorsements (user_id, skill_id, endorser_id) VALUES (?, ?, ?)"
+		withdrawEndorsement = "DELETE FROM skill_endorsements WHERE user_id = ? AND skill_id = ? AND endorser_id = ?"
+
+		/* people you may know */
+		getRecommendationSignals      = recommendationSignalsQuery
+		deleteRecommendations         = "DELETE FROM recommendations WHERE user_id = ?"
+		insertRecommendation          = "INSERT INTO recommendations (user_id, candidate_id, score, mutual_connections, shared_groups, shared_forums, same_degree) VALUES (?, ?, ?, ?, ?, ?, ?)"
+		setRecommendationsAt          = "UPDATE users SET recommendations_at = NOW() WHERE id = ?"
+		getRecommendationsAt          = "SELECT recommendations_at FROM users WHERE id = ?"
+		getStaleRecommendationUserIDs = "SELECT id FROM users WHERE recommendations_at < ? AND deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?"
+
+		/* blocks and mutes */
+		blockUser                = "INSERT IGNORE INTO user_blocks (blocker_id, blocked_id) VALUES (?, ?)"
+		removeBlockedConnections = "DELETE FROM connection_requests WHERE status IN ('pending', 'accepted') AND ((requester_id = ? AND recipient_id = ?) OR (requester_id = ? AND recipient_id = ?))"
+		unblockUser              = "DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?"
+		getBlockedUsers          = "SELECT u.id, u.username, b.created_at FROM user_blocks b JOIN users u ON u.id = b.blocked_id WHERE b.blocker_id = ? ORDER BY b.created_at DESC"
+		checkBlocked             = "SELECT COUNT(*) FROM user_blocks WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)"
+		muteUser                 = "INSERT IGNORE INTO user_mutes (muter_id, muted_id) VALUES (?, ?)"
+		unmuteUser               = "DELETE FROM user_mutes WHERE muter_id = ? AND muted_id = ?"
+		getMutedUsers            = "SELECT u.id, u.username, m.created_at FROM user_mutes m JOIN users u ON u.id = m.muted_id WHERE m.muter_id = ? ORDER BY m.created_at DESC"
+		checkMuted               = "SELECT COUNT(*) FROM user_mutes WHERE muter_id = ? AND muted_id = ?"
+
+		/* follows, the followee's follow_approval decides whether a new follow is pending */
+		follow               = "INSERT IGNORE INTO follows (follower_id, followee_id, status) SELECT ?, id, IF(follow_approval, 'pending', 'accepted') FROM users WHERE id = ?"
+		removeFollow         = "DELETE FROM follows WHERE follower_id = ? AND followee_id = ?"
+		approveFollow        = "UPDATE follows SET status = 'accepted' WHERE follower_id = ? AND followee_id = ? AND status = 'pending'"
+		getFollowStatus      = "SELECT status FROM follows WHERE follower_id = ? AND followee_id = ?"
+		getFollowStats       = "SELECT (SELECT COUNT(*) FROM follows WHERE followee_id = u.id AND status = 'accepted'), (SELECT COUNT(*) FROM follows WHERE follower_id = u.id AND status = 'accepted'), u.follow_approval, COALESCE((SELECT status FROM follows WHERE follower_id = ? AND followee_id = u.id), '') FROM users u WHERE u.id = ?"
+		setFollowApproval    = "UPDATE users SET follow_approval = ? WHERE id = ?"
+		acceptPendingFollows = "UPDATE follows SET status = 'accepted' WHERE followee_id = ? AND status = 'pending'"
+		removeBlockedFollows = "DELETE FROM follows WHERE (follower_id = ? AND followee_id = ?) OR (follower_id = ? AND followee_id = ?)"
+		exportFollows        = "SELECT follower_id, followee_id, status, created_at FROM follows WHERE ? IN (follower_id, followee_id) ORDER BY created_at"
+
+		/* forum post edits, each saves the post as it was in forum_revisions first */
+		updateForumPost   = "UPDATE forums SET title = ?, description = ?, slug = ? WHERE id = ? AND deleted_at IS NULL"
+		deleteForumPost   = "UPDATE forums SET deleted_at = NOW() WHERE id = ? AND deleted_at IS NULL"
+		addForumRevision  = "INSERT INTO forum_revisions (forum_id, editor_id, action, title, description, title_diff, description_diff) SELECT id, ?, ?, title, description, ?, ? FROM forums WHERE id = ? AND deleted_at IS NULL"
+		getForumRevisions = "SELECT r.id, COALESCE(r.editor_id, 0), COALESCE(u.username, ''), r.action, r.title, r.description, COALESCE(r.title_diff, ''), COALESCE(r.description_diff, ''), r.created_at FROM forum_revisions r LEFT JOIN users u ON u.id = r.editor_id WHERE r.forum_id = ? ORDER BY r.id DESC"
+
+		/* old forum slugs, kept so links to renamed posts redirect */
+		saveOldForumSlug   = "INSERT INTO forum_slugs (slug, forum_id) SELECT slug, id FROM forums WHERE id = ? AND slug <> ?"
+		reuseOldForumSlug  = "DELETE FROM forum_slugs WHERE slug = ? AND forum_id = ?"
+		getForumSlugTarget = "SELECT f.slug FROM forum_slugs s JOIN forums f ON f.id = s.forum_id WHERE s.slug = ? AND f.deleted_at IS NULL"
+
+		/* threaded comments, replies take the forum of their parent and nest one deeper */
+		addReply         = "INSERT INTO comments (user_id, forum_id, parent_id, depth, comment) SELECT ?, c.forum_id, c.id, c.depth + 1, ? FROM comments c JOIN forums f ON f.id = c.forum_id WHERE c.id = ? AND c.depth < ? AND f.deleted_at IS NULL"
+		getCommentParent = "SELECT c.id, COALESCE(c.user_id, 0), c.forum_id, f.slug, c.depth FROM comments c JOIN forums f ON f.id = c.forum_id WHERE c.id = ? AND f.deleted_at IS NULL"
 	)
+	database.conn = db
 	if database.createUser, err = db.Prepare(createUser); err != nil {
 		return nil, err
 	}
@@ -73,9 +468,6 @@ func NewMySQLDatabase(db *sql.DB) (*mysqlDatabase, error) {
 	if database.getUserByEmail, err = db.Prepare(getUserByEmail); err != nil {
 		return nil, err
 	}
-	if database.getBySessionKey, err = db.Prepare(getBySessionKey); err != nil {
-		return nil, err
-	}
 	if database.getUserPortfolios, err = db.Prepare(getUserPortfolios); err != nil {
 		return nil, err
 	}
@@ -106,335 +498,2592 @@ func NewMySQLDatabase(db *sql.DB) (*mysqlDatabase, error) {
 	if database.createGroup, err = db.Prepare(createGroup); err != nil {
 		return nil, err
 	}
-	if database.addGroupMember, err = db.Prepare(addGroupMember); err != nil {
-		return nil, err
+	if database.addGroupMember, err = db.Prepare(addGroupMember); err != nil {
+		return nil, err
+	}
+	if database.sendGroupMessage, err = db.Prepare(sendGroupMessage); err != nil {
+		return nil, err
+	}
+	if database.getGroupMessages, err = db.Prepare(getGroupMessages); err != nil {
+		return nil, err
+	}
+	if database.getGroupAdmin, err = db.Prepare(getGroupAdmin); err != nil {
+		return nil, err
+	}
+	if database.checkIfMember, err = db.Prepare(checkIfMember); err != nil {
+		return nil, err
+	}
+	if database.getChats, err = db.Prepare(getChats); err != nil {
+		return nil, err
+	}
+	if database.lockConnectionPair, err = db.Prepare(lockConnectionPair); err != nil {
+		return nil, err
+	}
+	if database.createConnectionRequest, err = db.Prepare(createConnectionRequest); err != nil {
+		return nil, err
+	}
+	if database.getConnectionRequest, err = db.Prepare(getConnectionRequest); err != nil {
+		return nil, err
+	}
+	if database.getConnectionRequestBetween, err = db.Prepare(getConnectionRequestBetween); err != nil {
+		return nil, err
+	}
+	if database.updateConnectionRequestStatus, err = db.Prepare(updateConnectionRequestStatus); err != nil {
+		return nil, err
+	}
+	if database.getIncomingConnectionRequests, err = db.Prepare(getIncomingConnectionRequests); err != nil {
+		return nil, err
+	}
+	if database.getOutgoingConnectionRequests, err = db.Prepare(getOutgoingConnectionRequests); err != nil {
+		return nil, err
+	}
+	if database.getUserConnections, err = db.Prepare(getUserConnections); err != nil {
+		return nil, err
+	}
+	if database.checkConnection, err = db.Prepare(checkConnection); err != nil {
+		return nil, err
+	}
+	if database.getUserByID, err = db.Prepare(getUserByID); err != nil {
+		return nil, err
+	}
+	if database.createRefreshToken, err = db.Prepare(createRefreshToken); err != nil {
+		return nil, err
+	}
+	if database.getRefreshToken, err = db.Prepare(getRefreshToken); err != nil {
+		return nil, err
+	}
+	if database.markRefreshTokenUsed, err = db.Prepare(markRefreshTokenUsed); err != nil {
+		return nil, err
+	}
+	if database.revokeSessionTokens, err = db.Prepare(revokeSessionTokens); err != nil {
+		return nil, err
+	}
+	if database.revokeUserRefreshTokens, err = db.Prepare(revokeUserRefreshTokens); err != nil {
+		return nil, err
+	}
+	if database.revokeAccessToken, err = db.Prepare(revokeAccessToken); err != nil {
+		return nil, err
+	}
+	if database.isAccessTokenRevoked, err = db.Prepare(isAccessTokenRevoked); err != nil {
+		return nil, err
+	}
+	if database.purgeRefreshTokens, err = db.Prepare(purgeRefreshTokens); err != nil {
+		return nil, err
+	}
+	if database.purgeRevokedTokens, err = db.Prepare(purgeRevokedTokens); err != nil {
+		return nil, err
+	}
+	if database.createPasswordReset, err = db.Prepare(createPasswordReset); err != nil {
+		return nil, err
+	}
+	if database.getPasswordReset, err = db.Prepare(getPasswordReset); err != nil {
+		return nil, err
+	}
+	if database.usePasswordReset, err = db.Prepare(usePasswordReset); err != nil {
+		return nil, err
+	}
+	if database.invalidatePasswordResets, err = db.Prepare(invalidatePasswordResets); err != nil {
+		return nil, err
+	}
+	if database.updateUserPassword, err = db.Prepare(updateUserPassword); err != nil {
+		return nil, err
+	}
+	if database.markEmailVerified, err = db.Prepare(markEmailVerified); err != nil {
+		return nil, err
+	}
+	if database.markVerificationSent, err = db.Prepare(markVerificationSent); err != nil {
+		return nil, err
+	}
+	if database.updateUserProfile, err = db.Prepare(updateUserProfile); err != nil {
+		return nil, err
+	}
+	if database.setPendingEmail, err = db.Prepare(setPendingEmail); err != nil {
+		return nil, err
+	}
+	if database.confirmEmailChange, err = db.Prepare(confirmEmailChange); err != nil {
+		return nil, err
+	}
+	if database.setProfilePicture, err = db.Prepare(setProfilePicture); err != nil {
+		return nil, err
+	}
+	if database.getUserByUsername, err = db.Prepare(getUserByUsername); err != nil {
+		return nil, err
+	}
+	if database.getPrivacy, err = db.Prepare(getPrivacy); err != nil {
+		return nil, err
+	}
+	if database.setPrivacy, err = db.Prepare(setPrivacy); err != nil {
+		return nil, err
+	}
+	if database.saveTwoFactorSecret, err = db.Prepare(saveTwoFactorSecret); err != nil {
+		return nil, err
+	}
+	if database.getTwoFactor, err = db.Prepare(getTwoFactor); err != nil {
+		return nil, err
+	}
+	if database.enableTwoFactor, err = db.Prepare(enableTwoFactor); err != nil {
+		return nil, err
+	}
+	if database.deleteTwoFactor, err = db.Prepare(deleteTwoFactor); err != nil {
+		return nil, err
+	}
+	if database.useTwoFactorStep, err = db.Prepare(useTwoFactorStep); err != nil {
+		return nil, err
+	}
+	if database.deleteRecoveryCodes, err = db.Prepare(deleteRecoveryCodes); err != nil {
+		return nil, err
+	}
+	if database.addRecoveryCode, err = db.Prepare(addRecoveryCode); err != nil {
+		return nil, err
+	}
+	if database.useRecoveryCode, err = db.Prepare(useRecoveryCode); err != nil {
+		return nil, err
+	}
+	if database.setMFARequired, err = db.Prepare(setMFARequired); err != nil {
+		return nil, err
+	}
+	if database.getRoles, err = db.Prepare(getRoles); err != nil {
+		return nil, err
+	}
+	if database.getRole, err = db.Prepare(getRole); err != nil {
+		return nil, err
+	}
+	if database.setUserRole, err = db.Prepare(setUserRole); err != nil {
+		return nil, err
+	}
+	if database.recordLoginFailure, err = db.Prepare(recordLoginFailure); err != nil {
+		return nil, err
+	}
+	if database.getLoginAttempt, err = db.Prepare(getLoginAttempt); err != nil {
+		return nil, err
+	}
+	if database.lockLogin, err = db.Prepare(lockLogin); err != nil {
+		return nil, err
+	}
+	if database.resetLoginAttempts, err = db.Prepare(resetLoginAttempts); err != nil {
+		return nil, err
+	}
+	if database.purgeLoginAttempts, err = db.Prepare(purgeLoginAttempts); err != nil {
+		return nil, err
+	}
+	if database.createSession, err = db.Prepare(createSession); err != nil {
+		return nil, err
+	}
+	if database.getSession, err = db.Prepare(getSession); err != nil {
+		return nil, err
+	}
+	if database.getUserSessions, err = db.Prepare(getUserSessions); err != nil {
+		return nil, err
+	}
+	if database.touchSession, err = db.Prepare(touchSession); err != nil {
+		return nil, err
+	}
+	if database.extendSession, err = db.Prepare(extendSession); err != nil {
+		return nil, err
+	}
+	if database.revokeSession, err = db.Prepare(revokeSession); err != nil {
+		return nil, err
+	}
+	if database.revokeUserSessions, err = db.Prepare(revokeUserSessions); err != nil {
+		return nil, err
+	}
+	if database.purgeSessions, err = db.Prepare(purgeSessions); err != nil {
+		return nil, err
+	}
+	if database.createAccessToken, err = db.Prepare(createAccessToken); err != nil {
+		return nil, err
+	}
+	if database.getAccessToken, err = db.Prepare(getAccessToken); err != nil {
+		return nil, err
+	}
+	if database.getUserAccessTokens, err = db.Prepare(getUserAccessTokens); err != nil {
+		return nil, err
+	}
+	if database.touchAccessToken, err = db.Prepare(touchAccessToken); err != nil {
+		return nil, err
+	}
+	if database.revokeAccessTokenByID, err = db.Prepare(revokeAccessTokenByID); err != nil {
+		return nil, err
+	}
+	if database.revokeUserAccessTokens, err = db.Prepare(revokeUserAccessTokens); err != nil {
+		return nil, err
+	}
+	if database.importGraduate, err = db.Prepare(importGraduate); err != nil {
+		return nil, err
+	}
+	if database.getGraduate, err = db.Prepare(getGraduate); err != nil {
+		return nil, err
+	}
+	if database.claimGraduate, err = db.Prepare(claimGraduate); err != nil {
+		return nil, err
+	}
+	if database.verifyAlumnus, err = db.Prepare(verifyAlumnus); err != nil {
+		return nil, err
+	}
+	if database.createInviteCode, err = db.Prepare(createInviteCode); err != nil {
+		return nil, err
+	}
+	if database.getInviteCodes, err = db.Prepare(getInviteCodes); err != nil {
+		return nil, err
+	}
+	if database.redeemInviteCode, err = db.Prepare(redeemInviteCode); err != nil {
+		return nil, err
+	}
+	if database.releaseInviteCode, err = db.Prepare(releaseInviteCode); err != nil {
+		return nil, err
+	}
+	if database.revokeInviteCode, err = db.Prepare(revokeInviteCode); err != nil {
+		return nil, err
+	}
+	if database.exportForumPosts, err = db.Prepare(exportForumPosts); err != nil {
+		return nil, err
+	}
+	if database.exportComments, err = db.Prepare(exportComments); err != nil {
+		return nil, err
+	}
+	if database.exportChats, err = db.Prepare(exportChats); err != nil {
+		return nil, err
+	}
+	if database.exportGroupMessages, err = db.Prepare(exportGroupMessages); err != nil {
+		return nil, err
+	}
+	if database.exportTransactions, err = db.Prepare(exportTransactions); err != nil {
+		return nil, err
+	}
+	if database.scheduleAccountDeletion, err = db.Prepare(scheduleAccountDeletion); err != nil {
+		return nil, err
+	}
+	if database.cancelAccountDeletion, err = db.Prepare(cancelAccountDeletion); err != nil {
+		return nil, err
+	}
+	if database.getAccountsDueForDeletion, err = db.Prepare(getAccountsDueForDeletion); err != nil {
+		return nil, err
+	}
+	for _, query := range anonymizeUserQueries {
+		stmt, err := db.Prepare(query)
+		if err != nil {
+			return nil, err
+		}
+		database.anonymizeUser = append(database.anonymizeUser, stmt)
+	}
+	if database.createExperience, err = db.Prepare(createExperience); err != nil {
+		return nil, err
+	}
+	if database.getExperience, err = db.Prepare(getExperience); err != nil {
+		return nil, err
+	}
+	if database.getExperiences, err = db.Prepare(getExperiences); err != nil {
+		return nil, err
+	}
+	if database.updateExperience, err = db.Prepare(updateExperience); err != nil {
+		return nil, err
+	}
+	if database.deleteExperience, err = db.Prepare(deleteExperience); err != nil {
+		return nil, err
+	}
+	if database.createEducation, err = db.Prepare(createEducation); err != nil {
+		return nil, err
+	}
+	if database.getEducation, err = db.Prepare(getEducation); err != nil {
+		return nil, err
+	}
+	if database.getEducations, err = db.Prepare(getEducations); err != nil {
+		return nil, err
+	}
+	if database.updateEducation, err = db.Prepare(updateEducation); err != nil {
+		return nil, err
+	}
+	if database.deleteEducation, err = db.Prepare(deleteEducation); err != nil {
+		return nil, err
+	}
+	if database.upsertSkill, err = db.Prepare(upsertSkill); err != nil {
+		return nil, err
+	}
+	if database.addUserSkill, err = db.Prepare(addUserSkill); err != nil {
+		return nil, err
+	}
+	if database.removeUserSkill, err = db.Prepare(removeUserSkill); err != nil {
+		return nil, err
+	}
+	if database.getUserSkills, err = db.Prepare(getUserSkills); err != nil {
+		return nil, err
+	}
+	if database.searchSkills, err = db.Prepare(searchSkills); err != nil {
+		return nil, err
+	}
+	if database.endorseSkill, err = db.Prepare(endorseSkill); err != nil {
+		return nil, err
+	}
+	if database.withdrawEndorsement, err = db.Prepare(withdrawEndorsement); err != nil {
+		return nil, err
+	}
+	if database.getRecommendationSignals, err = db.Prepare(getRecommendationSignals); err != nil {
+		return nil, err
+	}
+	if database.deleteRecommendations, err = db.Prepare(deleteRecommendations); err != nil {
+		return nil, err
+	}
+	if database.insertRecommendation, err = db.Prepare(insertRecommendation); err != nil {
+		return nil, err
+	}
+	if database.setRecommendationsAt, err = db.Prepare(setRecommendationsAt); err != nil {
+		return nil, err
+	}
+	if database.getRecommendationsAt, err = db.Prepare(getRecommendationsAt); err != nil {
+		return nil, err
+	}
+	if database.getStaleRecommendationUserIDs, err = db.Prepare(getStaleRecommendationUserIDs); err != nil {
+		return nil, err
+	}
+	if database.blockUser, err = db.Prepare(blockUser); err != nil {
+		return nil, err
+	}
+	if database.removeBlockedConnections, err = db.Prepare(removeBlockedConnections); err != nil {
+		return nil, err
+	}
+	if database.unblockUser, err = db.Prepare(unblockUser); err != nil {
+		return nil, err
+	}
+	if database.getBlockedUsers, err = db.Prepare(getBlockedUsers); err != nil {
+		return nil, err
+	}
+	if database.checkBlocked, err = db.Prepare(checkBlocked); err != nil {
+		return nil, err
+	}
+	if database.muteUser, err = db.Prepare(muteUser); err != nil {
+		return nil, err
+	}
+	if database.unmuteUser, err = db.Prepare(unmuteUser); err != nil {
+		return nil, err
+	}
+	if database.getMutedUsers, err = db.Prepare(getMutedUsers); err != nil {
+		return nil, err
+	}
+	if database.checkMuted, err = db.Prepare(checkMuted); err != nil {
+		return nil, err
+	}
+	if database.follow, err = db.Prepare(follow); err != nil {
+		return nil, err
+	}
+	if database.removeFollow, err = db.Prepare(removeFollow); err != nil {
+		return nil, err
+	}
+	if database.approveFollow, err = db.Prepare(approveFollow); err != nil {
+		return nil, err
+	}
+	if database.getFollowStatus, err = db.Prepare(getFollowStatus); err != nil {
+		return nil, err
+	}
+	if database.getFollowStats, err = db.Prepare(getFollowStats); err != nil {
+		return nil, err
+	}
+	if database.setFollowApproval, err = db.Prepare(setFollowApproval); err != nil {
+		return nil, err
+	}
+	if database.acceptPendingFollows, err = db.Prepare(acceptPendingFollows); err != nil {
+		return nil, err
+	}
+	if database.removeBlockedFollows, err = db.Prepare(removeBlockedFollows); err != nil {
+		return nil, err
+	}
+	if database.exportFollows, err = db.Prepare(exportFollows); err != nil {
+		return nil, err
+	}
+	if database.updateForumPost, err = db.Prepare(updateForumPost); err != nil {
+		return nil, err
+	}
+	if database.deleteForumPost, err = db.Prepare(deleteForumPost); err != nil {
+		return nil, err
+	}
+	if database.addForumRevision, err = db.Prepare(addForumRevision); err != nil {
+		return nil, err
+	}
+	if database.getForumRevisions, err = db.Prepare(getForumRevisions); err != nil {
+		return nil, err
+	}
+	if database.saveOldForumSlug, err = db.Prepare(saveOldForumSlug); err != nil {
+		return nil, err
+	}
+	if database.reuseOldForumSlug, err = db.Prepare(reuseOldForumSlug); err != nil {
+		return nil, err
+	}
+	if database.getForumSlugTarget, err = db.Prepare(getForumSlugTarget); err != nil {
+		return nil, err
+	}
+	if database.addReply, err = db.Prepare(addReply); err != nil {
+		return nil, err
+	}
+	if database.getCommentParent, err = db.Prepare(getCommentParent); err != nil {
+		return nil, err
+	}
+	return database, nil
+}
+
+func (db *mysqlDatabase) CreateUser(ctx context.Context, username string, password string, email string, degree string, gradyear string, currentjob string, phone string, profilepicture string, linkedinprofile string, twitterprofile string) (bool, error) {
+	userQuery, err := db.createUser.ExecContext(ctx, username, password, email, degree, gradyear, currentjob, phone, profilepicture, linkedinprofile, twitterprofile)
+	if err != nil {
+		return false, err
+	}
+	lastId, err := userQuery.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if lastId == 0 || lastId < 1 {
+		return false, err
+	}
+	return true, nil
+}
+
+func scanUser(row interface{ Scan(...any) error }) (*model.User, error) {
+	user := &model.User{}
+	err := row.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.PendingEmail, &user.Degree, &user.GradYear, &user.CurrentJob, &user.Phone, &user.ProfilePicture, &user.LinkedinProfile, &user.TwitterProfile, &user.EmailVerifiedAt, &user.VerificationSentAt, &user.MFARequired, &user.Role, &user.AlumniVerifiedAt, &user.DeletionScheduled, &user.DeletedAt, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (db *mysqlDatabase) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	user, err := scanUser(db.getUserByEmail.QueryRowContext(ctx, email))
+	if err != nil {
+		log.Println("get user by email", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (db *mysqlDatabase) CheckUser(ctx context.Context, email string, password string) (*model.User, error) {
+	user, err := scanUser(db.checkUser.QueryRowContext(ctx, email, password))
+	if err != nil {
+		log.Println("checkuser", err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (db *mysqlDatabase) GetUserByID(ctx context.Context, userID int) (*model.User, error) {
+	user, err := scanUser(db.getUserByID.QueryRowContext(ctx, userID))
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// MarkEmailVerified activates an account. It reports false when the email
+// was already verified.
+func (db *mysqlDatabase) MarkEmailVerified(ctx context.Context, userID int) (bool, error) {
+	result, err := db.markEmailVerified.ExecContext(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// MarkVerificationSent records that a verification email is going out. It
+// reports false, and records nothing, when the previous email was sent after
+// notBefore, which callers use to throttle resends.
+func (db *mysqlDatabase) MarkVerificationSent(ctx context.Context, userID int, notBefore time.Time) (bool, error) {
+	result, err := db.markVerificationSent.ExecContext(ctx, userID, notBefore)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateUserProfile saves the editable profile fields of user. It reports
+// false if nothing changed.
+func (db *mysqlDatabase) UpdateUserProfile(ctx context.Context, user *model.User) (bool, error) {
+	result, err := db.updateUserProfile.ExecContext(ctx, user.Username, user.Degree, user.GradYear, user.CurrentJob, user.Phone, user.LinkedinProfile, user.TwitterProfile, user.Id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetPendingEmail records the address a user is changing to until it is
+// verified. An empty email clears it.
+func (db *mysqlDatabase) SetPendingEmail(ctx context.Context, userID int, email string) error {
+	_, err := db.setPendingEmail.ExecContext(ctx, email, userID)
+	return err
+}
+
+// ConfirmEmailChange makes the verified pending email the account email. It
+// reports false if email is no longer the pending one.
+func (db *mysqlDatabase) ConfirmEmailChange(ctx context.Context, userID int, email string) (bool, error) {
+	result, err := db.confirmEmailChange.ExecContext(ctx, userID, email)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SetProfilePicture points the user's profile picture at url, empty removes it.
+func (db *mysqlDatabase) SetProfilePicture(ctx context.Context, userID int, url string) error {
+	_, err := db.setProfilePicture.ExecContext(ctx, url, userID)
+	return err
+}
+
+func (db *mysqlDatabase) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return scanUser(db.getUserByUsername.QueryRowContext(ctx, username))
+}
+
+// GetPrivacySettings returns the fields the user has changed the visibility
+// of, the others keep model.PrivacyDefaults.
+func (db *mysqlDatabase) GetPrivacySettings(ctx context.Context, userID int) (model.PrivacySettings, error) {
+	settings := model.PrivacySettings{}
+	rows, err := db.getPrivacy.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var field, visibility string
+		if err := rows.Scan(&field, &visibility); err != nil {
+			return nil, err
+		}
+		settings[field] = visibility
+	}
+	return settings, rows.Err()
+}
+
+// SetPrivacySettings saves the visibility of the fields in settings, all or
+// none of them.
+func (db *mysqlDatabase) SetPrivacySettings(ctx context.Context, userID int, settings model.PrivacySettings) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	setPrivacy := tx.StmtContext(ctx, db.setPrivacy)
+	for field, visibility := range settings {
+		if _, err := setPrivacy.ExecContext(ctx, userID, field, visibility); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *mysqlDatabase) GetUserPortfolio(ctx context.Context, user_email string) (*[]model.PortfolioOrder, error) {
+	var portfolioOrders = []model.PortfolioOrder{}
+	var portfolioOrder = model.PortfolioOrder{}
+	getPortfolio, err := db.getUserPortfolios.QueryContext(ctx, user_email)
+	if err != nil {
+		return nil, err
+	}
+	defer getPortfolio.Close()
+	for getPortfolio.Next() {
+		err := getPortfolio.Scan(&portfolioOrder.Id, &portfolioOrder.Type, &portfolioOrder.Security, &portfolioOrder.Unit, &portfolioOrder.Status, &portfolioOrder.Cancelled, &portfolioOrder.UserID, &portfolioOrder.UserEmail, &portfolioOrder.CreatedAt, &portfolioOrder.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	portfolioOrders = append(portfolioOrders, portfolioOrder)
+	return &portfolioOrders, nil
+}
+
+func (db *mysqlDatabase) GetUserTransactions(ctx context.Context, user_email string) (*[]model.Transaction, error) {
+	var transaction = model.Transaction{}
+	var transactions = []model.Transaction{}
+	getTransactions, err := db.getUserTransactions.QueryContext(ctx, user_email)
+	if err != nil {
+		return nil, err
+	}
+	defer getTransactions.Close()
+	for getTransactions.Next() {
+		err := getTransactions.Scan(&transaction.Id, &transaction.FromUserID, &transaction.FromUserEmail, &transaction.ToUserID, &transaction.ToUserEmail, &transaction.TransactionType, &transaction.CreatedAt, &transaction.UpdatedAt, &transaction.Amount, &transaction.UserEmail)
+		if err != nil {
+			return nil, err
+		}
+	}
+	transactions = append(transactions, transaction)
+	return &transactions, nil
+}
+
+func (db *mysqlDatabase) CreateNewTransaction(ctx context.Context, from_user int, from_user_email string, to_user int, to_user_email string, transactiontype string, created_at time.Time, updated_at time.Time, amount int, user_email string) (bool, error) {
+	createNewTx, err := db.createNewTransaction.ExecContext(ctx, from_user, from_user_email, to_user, to_user_email, transactiontype, created_at, updated_at, amount, user_email)
+	if err != nil {
+		return false, err
+	}
+	lastInsert, err := createNewTx.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if lastInsert <= 0 {
+		return false, err
+	}
+	return true, nil
+}
+
+// ErrSlugTaken is returned when another post got the slug first. Pick a new
+// one and try again.
+var ErrSlugTaken = fmt.Errorf("forum slug is taken")
+
+// isDuplicateEntry reports whether err is MySQL refusing a row that breaks a
+// UNIQUE key.
+func isDuplicateEntry(err error) bool {
+	mysqlErr, ok := err.(*driver.MySQLError)
+	return ok && mysqlErr.Number == 1062
+}
+
+// AddNewForumPost returns ErrSlugTaken if a post with slug already exists.
+func (db *mysqlDatabase) AddNewForumPost(ctx context.Context, title string, description string, author string, slug string, created_at time.Time, updated_at time.Time) (bool, error) {
+	createNewForum, err := db.addNewForumPost.ExecContext(ctx, title, description, author, slug, created_at, updated_at)
+	if isDuplicateEntry(err) {
+		return false, ErrSlugTaken
+	}
+	if err != nil {
+		return false, err
+	}
+	lastInsert, err := createNewForum.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if lastInsert <= 0 {
+		return false, err
+	}
+	return true, nil
+}
+
+// SendMessage stores a chat message. It reports false, without an error, when
+// either user has blocked the other.
+func (db *mysqlDatabase) SendMessage(ctx context.Context, senderId int, receiverId int, message string, createdAt time.Time, updatedAt time.Time) (bool, error) {
+	sendmessage, err := db.sendMessage.ExecContext(ctx, senderId, receiverId, message, createdAt, updatedAt, senderId, receiverId, receiverId, senderId)
+	if err != nil {
+		return false, err
+	}
+	lastInsert, err := sendmessage.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if lastInsert <= 0 {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetSingleForumPost returns the post with slug, deleted or not. Check
+// DeletedAt before showing it.
+func (db *mysqlDatabase) GetSingleForumPost(ctx context.Context, slug string) (*model.Forum, error) {
+	forum := &model.Forum{}
+	getForumBySlug := db.getSingleForumPost.QueryRowContext(ctx, slug)
+	err := getForumBySlug.Scan(&forum.Id, &forum.Title, &forum.Description, &forum.Author, &forum.AuthorUsername, &forum.Slug, &forum.CreatedAt, &forum.UpdatedAt, &forum.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return forum, nil
+}
+
+// GetAllForums returns the posts that were not deleted, newest first.
+func (db *mysqlDatabase) GetAllForums(ctx context.Context) (*[]model.Forum, error) {
+	var forums = []model.Forum{}
+	getForums, err := db.getAllForums.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer getForums.Close()
+	for getForums.Next() {
+		var forum model.Forum
+		err := getForums.Scan(&forum.Id, &forum.Title, &forum.Description, &forum.Author, &forum.AuthorUsername, &forum.Slug, &forum.CreatedAt, &forum.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		forums = append(forums, forum)
+	}
+	return &forums, getForums.Err()
+}
+
+// AddComment reports false if the post does not exist or was deleted.
+func (db *mysqlDatabase) AddComment(ctx context.Context, userID int, forumID int, comment string) (bool, error) {
+	incoming, err := db.addComment.ExecContext(ctx, userID, comment, forumID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := incoming.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateForumPost replaces the title, description and slug of a post,
+// saving the previous ones with the diffs in a revision by editorID. A
+// replaced slug is kept for GetForumSlugTarget. It reports false if the post
+// does not exist or was deleted, and returns ErrSlugTaken if another post
+// has slug.
+func (db *mysqlDatabase) UpdateForumPost(ctx context.Context, forumID int, editorID int, title string, description string, slug string, titleDiff string, descriptionDiff string) (bool, error) {
+	return db.reviseForumPost(ctx, forumID, editorID, model.ForumRevisionEdit, titleDiff, descriptionDiff, func(tx *sql.Tx) error {
+		if _, err := tx.StmtContext(ctx, db.saveOldForumSlug).ExecContext(ctx, forumID, slug); err != nil {
+			return err
+		}
+		if _, err := tx.StmtContext(ctx, db.reuseOldForumSlug).ExecContext(ctx, slug, forumID); err != nil {
+			return err
+		}
+		_, err := tx.StmtContext(ctx, db.updateForumPost).ExecContext(ctx, title, description, slug, forumID)
+		if isDuplicateEntry(err) {
+			return ErrSlugTaken
+		}
+		return err
+	})
+}
+
+// DeleteForumPost hides a post and stops new comments on it, keeping the
+// post and its comments for the revision history. It reports false if the
+// post does not exist or was already deleted.
+func (db *mysqlDatabase) DeleteForumPost(ctx context.Context, forumID int, editorID int) (bool, error) {
+	return db.reviseForumPost(ctx, forumID, editorID, model.ForumRevisionDelete, "", "", func(tx *sql.Tx) error {
+		_, err := tx.StmtContext(ctx, db.deleteForumPost).ExecContext(ctx, forumID)
+		return err
+	})
+}
+
+// reviseForumPost saves a revision of the post as it is, then makes the
+// change in the same transaction.
+func (db *mysqlDatabase) reviseForumPost(ctx context.Context, forumID int, editorID int, action string, titleDiff string, descriptionDiff string, change func(tx *sql.Tx) error) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	result, err := tx.StmtContext(ctx, db.addForumRevision).ExecContext(ctx, editorID, action, titleDiff, descriptionDiff, forumID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if err := change(tx); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetTakenForumSlugs returns the current and old slugs of posts other than
+// forumID that are base or base followed by a hyphen and more.
+func (db *mysqlDatabase) GetTakenForumSlugs(ctx context.Context, base string, forumID int) ([]string, error) {
+	pattern := likeEscaper.Replace(base) + "-%"
+	rows, err := db.conn.QueryContext(ctx, "SELECT slug FROM forums WHERE (slug = ? OR slug LIKE ?) AND id <> ?"+
+		" UNION SELECT slug FROM forum_slugs WHERE (slug = ? OR slug LIKE ?) AND forum_id <> ?", base, pattern, forumID, base, pattern, forumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	taken := []string{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		taken = append(taken, slug)
+	}
+	return taken, rows.Err()
+}
+
+// GetForumSlugTarget returns the current slug of the post that used to have
+// slug, or "" if none did or it was deleted.
+func (db *mysqlDatabase) GetForumSlugTarget(ctx context.Context, slug string) (string, error) {
+	var target string
+	err := db.getForumSlugTarget.QueryRowContext(ctx, slug).Scan(&target)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return target, err
+}
+
+// GetForumRevisions returns the revisions of a post, latest first.
+func (db *mysqlDatabase) GetForumRevisions(ctx context.Context, forumID int) ([]model.ForumRevision, error) {
+	revisions := []model.ForumRevision{}
+	err := scanRows(ctx, db.getForumRevisions, []any{forumID}, func(row *sql.Rows) error {
+		var revision model.ForumRevision
+		if err := row.Scan(&revision.ID, &revision.EditorID, &revision.Editor, &revision.Action, &revision.Title, &revision.Description, &revision.TitleDiff, &revision.DescriptionDiff, &revision.CreatedAt); err != nil {
+			return err
+		}
+		revisions = append(revisions, revision)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetCommentsByForumID returns the top level comments on a forum post and the
+// first replies of each comment as a flat list, oldest first, leaving out the
+// ones by users viewerID muted or blocked. Pass 0 for anonymous viewers.
+// ReplyCount counts all of a comment's replies, GetCommentReplies pages
+// through the ones not returned.
+func (db *mysqlDatabase) GetCommentsByForumID(ctx context.Context, forumID int, viewerID int, replies int) ([]model.Comment, error) {
+	comments := []model.Comment{}
+	rows, err := db.getCommentsByForum.QueryContext(ctx, forumID, viewerID, viewerID, replies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replyCounts := map[int]int{}
+	for rows.Next() {
+		var (
+			comment  model.Comment
+			siblings int
+		)
+		if err := rows.Scan(&comment.ID, &comment.ParentID, &comment.Depth, &comment.Username, &comment.Comment, &comment.CreatedAt, &siblings); err != nil {
+			return nil, err
+		}
+		if comment.ParentID != nil {
+			replyCounts[*comment.ParentID] = siblings
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		comments[i].ReplyCount = replyCounts[comments[i].ID]
+	}
+	return comments, nil
+}
+
+// commentVisible is a condition on the comments in alias that holds where
+// the author is not muted or blocked by viewerID.
+func commentVisible(alias string, viewerID int) (string, []any) {
+	condition := "NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = ? AND m.muted_id = " + alias + ".user_id)" +
+		" AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE b.blocker_id = ? AND b.blocked_id = " + alias + ".user_id)"
+	return condition, []any{viewerID, viewerID}
+}
+
+// GetCommentReplies returns a page of the direct replies to parentID, oldest
+// first, counting their own replies. Replies by users viewerID muted or
+// blocked are left out and not counted. Pages continue after the cursor.
+func (db *mysqlDatabase) GetCommentReplies(ctx context.Context, parentID int, viewerID int, after *model.CommentCursor, limit int) ([]model.Comment, error) {
+	replyVisible, replyArgs := commentVisible("r", viewerID)
+	visible, visibleArgs := commentVisible("c", viewerID)
+	query := "SELECT c.id, c.parent_id, c.depth, u.username, c.comment, c.created_at," +
+		" (SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id AND " + replyVisible + ")" +
+		" FROM comments c JOIN users u ON u.id = c.user_id JOIN forums f ON f.id = c.forum_id" +
+		" WHERE c.parent_id = ? AND f.deleted_at IS NULL AND " + visible
+	args := append(replyArgs, parentID)
+	args = append(args, visibleArgs...)
+	if after != nil {
+		query += " AND (c.created_at > ? OR (c.created_at = ? AND c.id > ?))"
+		args = append(args, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	query += " ORDER BY c.created_at, c.id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	replies := []model.Comment{}
+	for rows.Next() {
+		var reply model.Comment
+		if err := rows.Scan(&reply.ID, &reply.ParentID, &reply.Depth, &reply.Username, &reply.Comment, &reply.CreatedAt, &reply.ReplyCount); err != nil {
+			return nil, err
+		}
+		reply.MoreReplies = reply.ReplyCount > 0
+		replies = append(replies, reply)
+	}
+	return replies, rows.Err()
+}
+
+// GetCommentParent returns the comment with id if its post was not deleted.
+func (db *mysqlDatabase) GetCommentParent(ctx context.Context, id int) (*model.CommentParent, error) {
+	parent := &model.CommentParent{}
+	if err := db.getCommentParent.QueryRowContext(ctx, id).Scan(&parent.ID, &parent.UserID, &parent.ForumID, &parent.ForumSlug, &parent.Depth); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// AddReply stores a reply by userID to parentID. It reports false if the
+// parent is gone, its post was deleted or it is at model.MaxCommentDepth.
+func (db *mysqlDatabase) AddReply(ctx context.Context, userID int, parentID int, comment string) (bool, error) {
+	result, err := db.addReply.ExecContext(ctx, userID, comment, parentID, model.MaxCommentDepth)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CreateGroup creates a new group.
+func (db *mysqlDatabase) CreateGroup(ctx context.Context, name string, createdby int) (int, error) {
+	result, err := db.createGroup.ExecContext(ctx, name, createdby)
+	if err != nil {
+		return 0, err
+	}
+	g_lid, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("last insert id: %v", g_lid)
+		return 0, err
+	}
+	if g_lid <= 0 {
+		return 0, fmt.Errorf("unable to create new group")
+	}
+	return int(g_lid), nil
+}
+
+// AddGroupMember adds a new member to the group.
+func (db *mysqlDatabase) AddGroupMember(ctx context.Context, groupID int, userID int) (bool, error) {
+	group, err := db.addGroupMember.ExecContext(ctx, groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	mem_lid, err := group.LastInsertId()
+	if err != nil {
+		log.Printf("last insert id: %v", mem_lid)
+		return false, err
+	}
+	if mem_lid <= 0 {
+		return false, fmt.Errorf("unable to add new member")
+	}
+	return true, nil
+}
+
+func (db *mysqlDatabase) SendGroupMessage(ctx context.Context, groupID int, userID int, message string) (bool, error) {
+	newMessage, err := db.sendGroupMessage.ExecContext(ctx, groupID, userID, message)
+	if err != nil {
+		return false, err
+	}
+	nm_lid, err := newMessage.LastInsertId()
+	if err != nil {
+		log.Printf("last insert id: %v", nm_lid)
+		return false, err
+	}
+	if nm_lid <= 0 {
+		return false, fmt.Errorf("unable to send group message")
+	}
+	return true, nil
+}
+
+func (db *mysqlDatabase) GetGroupMessages(ctx context.Context, groupID int) ([]model.GroupMessage, error) {
+	messages := []model.GroupMessage{}
+	rows, err := db.getGroupMessages.QueryContext(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message model.GroupMessage
+		if err := rows.Scan(&message.ID, &message.Username, &message.Message, &message.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (db *mysqlDatabase) GetGroupCreator(ctx context.Context, groupID int) (*model.User, error) {
+	user := &model.User{}
+
+	// Execute the query
+	row := db.getGroupAdmin.QueryRowContext(ctx, groupID)
+	err := row.Scan(&user.Id, &user.Username, &user.Email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no group found with id %d", groupID)
+		}
+		log.Println("Error fetching group creator:", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CheckGroupMembership checks if a user is a member of a specific group.
+func (db *mysqlDatabase) CheckGroupMembership(ctx context.Context, groupID int, userID int) (bool, error) {
+	var count int
+	err := db.checkIfMember.QueryRowContext(ctx, groupID, userID).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// FetchUserChats returns the messages between two users, oldest first, for
+// userID1 to read. It returns none once userID2 has blocked userID1.
+func (db *mysqlDatabase) FetchUserChats(ctx context.Context, userID1, userID2 int) ([]*model.Chat, error) {
+
+	rows, err := db.getChats.QueryContext(ctx, userID1, userID2, userID2, userID1, userID2, userID1)
+	if err != nil {
+		log.Println("Error fetching user chats:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []*model.Chat
+	for rows.Next() {
+		var chat model.Chat
+		err := rows.Scan(&chat.Id, &chat.SenderID, &chat.RecipientID, &chat.Message, &chat.CreatedAt, &chat.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, &chat)
+	}
+	return chats, nil
+}
+
+// CreateConnectionRequest records a pending connection request and returns its
+// id. If a pending or accepted request between the two users exists already,
+// nothing is created and that request is returned instead. Both user rows are
+// locked while checking, so two requests between the same pair, sent either
+// way round, can't both be created.
+func (db *mysqlDatabase) CreateConnectionRequest(ctx context.Context, requesterID int, recipientID int) (int, *model.ConnectionRequest, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.StmtContext(ctx, db.lockConnectionPair).QueryContext(ctx, requesterID, recipientID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return 0, nil, err
+	}
+	existing, err := scanConnectionRequest(tx.StmtContext(ctx, db.getConnectionRequestBetween).QueryRowContext(ctx, requesterID, recipientID, recipientID, requesterID))
+	if err == nil {
+		return 0, existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, nil, err
+	}
+	result, err := tx.StmtContext(ctx, db.createConnectionRequest).ExecContext(ctx, requesterID, recipientID)
+	if err != nil {
+		return 0, nil, err
+	}
+	cr_lid, err := result.LastInsertId()
+	if err != nil {
+		return 0, nil, err
+	}
+	if cr_lid <= 0 {
+		return 0, nil, fmt.Errorf("unable to create connection request")
+	}
+	return int(cr_lid), nil, tx.Commit()
+}
+
+func scanConnectionRequest(row interface{ Scan(...any) error }) (*model.ConnectionRequest, error) {
+	request := &model.ConnectionRequest{}
+	err := row.Scan(&request.Id, &request.RequesterID, &request.RequesterUsername, &request.RecipientID, &request.RecipientUsername, &request.Status, &request.CreatedAt, &request.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (db *mysqlDatabase) GetConnectionRequest(ctx context.Context, requestID int) (*model.ConnectionRequest, error) {
+	return scanConnectionRequest(db.getConnectionRequest.QueryRowContext(ctx, requestID))
+}
+
+// GetConnectionRequestBetween returns the latest pending or accepted request
+// between two users, regardless of who initiated it.
+func (db *mysqlDatabase) GetConnectionRequestBetween(ctx context.Context, userID1 int, userID2 int) (*model.ConnectionRequest, error) {
+	return scanConnectionRequest(db.getConnectionRequestBetween.QueryRowContext(ctx, userID1, userID2, userID2, userID1))
+}
+
+// UpdateConnectionRequestStatus moves a request from fromStatus to toStatus.
+// It reports false when the request was not in fromStatus anymore.
+func (db *mysqlDatabase) UpdateConnectionRequestStatus(ctx context.Context, requestID int, fromStatus string, toStatus string) (bool, error) {
+	result, err := db.updateConnectionRequestStatus.ExecContext(ctx, toStatus, requestID, fromStatus)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (db *mysqlDatabase) queryConnectionRequests(ctx context.Context, stmt *sql.Stmt, userID int) ([]model.ConnectionRequest, error) {
+	requests := []model.ConnectionRequest{}
+	rows, err := stmt.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		request, err := scanConnectionRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	return requests, rows.Err()
+}
+
+func (db *mysqlDatabase) GetIncomingConnectionRequests(ctx context.Context, userID int) ([]model.ConnectionRequest, error) {
+	return db.queryConnectionRequests(ctx, db.getIncomingConnectionRequests, userID)
+}
+
+func (db *mysqlDatabase) GetOutgoingConnectionRequests(ctx context.Context, userID int) ([]model.ConnectionRequest, error) {
+	return db.queryConnectionRequests(ctx, db.getOutgoingConnectionRequests, userID)
+}
+
+func (db *mysqlDatabase) GetUserConnections(ctx context.Context, userID int) ([]model.Connection, error) {
+	connections := []model.Connection{}
+	rows, err := db.getUserConnections.QueryContext(ctx, userID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var connection model.Connection
+		if err := rows.Scan(&connection.ConnectionRequestId, &connection.UserID, &connection.Username, &connection.Degree, &connection.GradYear, &connection.ConnectedAt); err != nil {
+			return nil, err
+		}
+		connections = append(connections, connection)
+	}
+	return connections, rows.Err()
+}
+
+// CheckConnection checks if two users have an accepted connection.
+func (db *mysqlDatabase) CheckConnection(ctx context.Context, userID1 int, userID2 int) (bool, error) {
+	var count int
+	err := db.checkConnection.QueryRowContext(ctx, userID1, userID2, userID2, userID1).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (db *mysqlDatabase) CreateRefreshToken(ctx context.Context, userID int, tokenHash string, sessionID string, expiresAt time.Time) (bool, error) {
+	result, err := db.createRefreshToken.ExecContext(ctx, userID, tokenHash, sessionID, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	rt_lid, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if rt_lid <= 0 {
+		return false, fmt.Errorf("unable to store refresh token")
+	}
+	return true, nil
+}
+
+func (db *mysqlDatabase) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	token := &model.RefreshToken{}
+	row := db.getRefreshToken.QueryRowContext(ctx, tokenHash)
+	err := row.Scan(&token.Id, &token.UserID, &token.TokenHash, &token.SessionID, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// MarkRefreshTokenUsed flags a refresh token as rotated. It reports false when
+// the token was already used or revoked, which callers treat as reuse.
+func (db *mysqlDatabase) MarkRefreshTokenUsed(ctx context.Context, tokenID int) (bool, error) {
+	result, err := db.markRefreshTokenUsed.ExecContext(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeAccessToken adds a token id to the denylist until the token expires.
+func (db *mysqlDatabase) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := db.revokeAccessToken.ExecContext(ctx, jti, expiresAt)
+	return err
+}
+
+func (db *mysqlDatabase) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int
+	err := db.isAccessTokenRevoked.QueryRowContext(ctx, jti).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgeExpiredTokens removes sessions, refresh tokens and denylist entries
+// that can no longer be presented because they have expired.
+func (db *mysqlDatabase) PurgeExpiredTokens(ctx context.Context) error {
+	if _, err := db.purgeSessions.ExecContext(ctx); err != nil {
+		return err
+	}
+	if _, err := db.purgeRefreshTokens.ExecContext(ctx); err != nil {
+		return err
+	}
+	_, err := db.purgeRevokedTokens.ExecContext(ctx)
+	return err
+}
+
+func (db *mysqlDatabase) CreatePasswordReset(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) (bool, error) {
+	result, err := db.createPasswordReset.ExecContext(ctx, userID, tokenHash, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	pr_lid, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+	if pr_lid <= 0 {
+		return false, fmt.Errorf("unable to store password reset")
+	}
+	return true, nil
+}
+
+func (db *mysqlDatabase) GetPasswordReset(ctx context.Context, tokenHash string) (*model.PasswordReset, error) {
+	reset := &model.PasswordReset{}
+	row := db.getPasswordReset.QueryRowContext(ctx, tokenHash)
+	err := row.Scan(&reset.Id, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return reset, nil
+}
+
+// UsePasswordReset consumes a reset token and sets the user's new password
+// in one transaction, so a failed update leaves the token usable. It reports
+// false when the token has already been used.
+func (db *mysqlDatabase) UsePasswordReset(ctx context.Context, resetID int, userID int, password string) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	result, err := tx.StmtContext(ctx, db.usePasswordReset).ExecContext(ctx, resetID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	if _, err = tx.StmtContext(ctx, db.updateUserPassword).ExecContext(ctx, password, userID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// InvalidatePasswordResets consumes every outstanding reset token of a user.
+func (db *mysqlDatabase) InvalidatePasswordResets(ctx context.Context, userID int) error {
+	_, err := db.invalidatePasswordResets.ExecContext(ctx, userID)
+	return err
+}
+
+func (db *mysqlDatabase) UpdateUserPassword(ctx context.Context, userID int, password string) (bool, error) {
+	result, err := db.updateUserPassword.ExecContext(ctx, password, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// SaveTwoFactorSecret starts (or restarts) enrollment with a new secret. The
+// secret only protects logins once EnableTwoFactor is called.
+func (db *mysqlDatabase) SaveTwoFactorSecret(ctx context.Context, userID int, secret string) error {
+	_, err := db.saveTwoFactorSecret.ExecContext(ctx, userID, secret)
+	return err
+}
+
+func (db *mysqlDatabase) GetTwoFactor(ctx context.Context, userID int) (*model.TwoFactor, error) {
+	twoFactor := &model.TwoFactor{}
+	row := db.getTwoFactor.QueryRowContext(ctx, userID)
+	err := row.Scan(&twoFactor.UserID, &twoFactor.Secret, &twoFactor.EnabledAt, &twoFactor.LastUsedStep, &twoFactor.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return twoFactor, nil
+}
+
+func (db *mysqlDatabase) EnableTwoFactor(ctx context.Context, userID int) (bool, error) {
+	result, err := db.enableTwoFactor.ExecContext(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// DisableTwoFactor removes the totp secret and all recovery codes.
+func (db *mysqlDatabase) DisableTwoFactor(ctx context.Context, userID int) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.StmtContext(ctx, db.deleteTwoFactor).ExecContext(ctx, userID); err != nil {
+		return err
+	}
+	if _, err = tx.StmtContext(ctx, db.deleteRecoveryCodes).ExecContext(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTwoFactorStep records the step of an accepted code. It reports false
+// when that step (or a later one) was already used, i.e. a replayed code.
+func (db *mysqlDatabase) UseTwoFactorStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := db.useTwoFactorStep.ExecContext(ctx, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReplaceRecoveryCodes swaps all recovery codes of a user for a new set.
+func (db *mysqlDatabase) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.StmtContext(ctx, db.deleteRecoveryCodes).ExecContext(ctx, userID); err != nil {
+		return err
+	}
+	addCode := tx.StmtContext(ctx, db.addRecoveryCode)
+	for _, codeHash := range codeHashes {
+		if _, err = addCode.ExecContext(ctx, userID, codeHash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code. It reports false when the code
+// does not exist or was already used.
+func (db *mysqlDatabase) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	result, err := db.useRecoveryCode.ExecContext(ctx, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (db *mysqlDatabase) SetMFARequired(ctx context.Context, userID int, required bool) error {
+	_, err := db.setMFARequired.ExecContext(ctx, required, userID)
+	return err
+}
+
+// queryRoles collects the role rows, one per granted permission, into roles.
+func queryRoles(rows *sql.Rows) ([]model.Role, error) {
+	defer rows.Close()
+	roles := []model.Role{}
+	for rows.Next() {
+		var (
+			role       model.Role
+			permission sql.NullString
+		)
+		if err := rows.Scan(&role.Name, &role.Description, &role.Require2FA, &permission); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Name != role.Name {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func (db *mysqlDatabase) GetRoles(ctx context.Context) ([]model.Role, error) {
+	rows, err := db.getRoles.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return queryRoles(rows)
+}
+
+// GetRole returns the role with its permissions, or sql.ErrNoRows if there is
+// no such role.
+func (db *mysqlDatabase) GetRole(ctx context.Context, name string) (*model.Role, error) {
+	rows, err := db.getRole.QueryContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := queryRoles(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &roles[0], nil
+}
+
+func (db *mysqlDatabase) SetUserRole(ctx context.Context, userID int, role string) error {
+	_, err := db.setUserRole.ExecContext(ctx, role, userID)
+	return err
+}
+
+// GetLoginAttempt returns the failed sign ins recorded for email, or
+// sql.ErrNoRows if there are none.
+func (db *mysqlDatabase) GetLoginAttempt(ctx context.Context, email string) (*model.LoginAttempt, error) {
+	attempt := &model.LoginAttempt{}
+	err := db.getLoginAttempt.QueryRowContext(ctx, email).Scan(&attempt.Email, &attempt.Failures, &attempt.LastFailureAt, &attempt.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// RecordLoginFailure counts a failed sign in for email and returns the
+// updated record.
+func (db *mysqlDatabase) RecordLoginFailure(ctx context.Context, email string) (*model.LoginAttempt, error) {
+	if _, err := db.recordLoginFailure.ExecContext(ctx, email); err != nil {
+		return nil, err
+	}
+	return db.GetLoginAttempt(ctx, email)
+}
+
+func (db *mysqlDatabase) LockLogin(ctx context.Context, email string, until time.Time) error {
+	_, err := db.lockLogin.ExecContext(ctx, until, email)
+	return err
+}
+
+func (db *mysqlDatabase) ResetLoginAttempts(ctx context.Context, email string) error {
+	_, err := db.resetLoginAttempts.ExecContext(ctx, email)
+	return err
+}
+
+// PurgeLoginAttempts forgets failures older than before, unless the email is
+// still locked.
+func (db *mysqlDatabase) PurgeLoginAttempts(ctx context.Context, before time.Time) error {
+	_, err := db.purgeLoginAttempts.ExecContext(ctx, before)
+	return err
+}
+
+func (db *mysqlDatabase) CreateSession(ctx context.Context, sessionID string, userID int, device string, ip string, expiresAt time.Time) error {
+	_, err := db.createSession.ExecContext(ctx, sessionID, userID, device, ip, expiresAt)
+	return err
+}
+
+func scanSession(row interface{ Scan(...any) error }) (*model.Session, error) {
+	session := &model.Session{}
+	err := row.Scan(&session.ID, &session.UserID, &session.Device, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *mysqlDatabase) GetSession(ctx context.Context, sessionID string) (*model.Session, error) {
+	return scanSession(db.getSession.QueryRowContext(ctx, sessionID))
+}
+
+// GetUserSessions lists the sessions of a user that are still usable, most
+// recently seen first.
+func (db *mysqlDatabase) GetUserSessions(ctx context.Context, userID int) ([]model.Session, error) {
+	rows, err := db.getUserSessions.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []model.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records activity on a session. Writes are limited to one a
+// minute per session since it runs on every authenticated request.
+func (db *mysqlDatabase) TouchSession(ctx context.Context, sessionID string, ip string) error {
+	_, err := db.touchSession.ExecContext(ctx, ip, sessionID)
+	return err
+}
+
+func (db *mysqlDatabase) ExtendSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	_, err := db.extendSession.ExecContext(ctx, expiresAt, sessionID)
+	return err
+}
+
+// RevokeSession signs a device out by revoking the session and its refresh
+// tokens together.
+func (db *mysqlDatabase) RevokeSession(ctx context.Context, sessionID string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.StmtContext(ctx, db.revokeSession).ExecContext(ctx, sessionID); err != nil {
+		return err
+	}
+	if _, err = tx.StmtContext(ctx, db.revokeSessionTokens).ExecContext(ctx, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeUserSessions signs out every device of a user except exceptID, which
+// may be empty to sign out all of them.
+func (db *mysqlDatabase) RevokeUserSessions(ctx context.Context, userID int, exceptID string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.StmtContext(ctx, db.revokeUserSessions).ExecContext(ctx, userID, exceptID); err != nil {
+		return err
+	}
+	if _, err = tx.StmtContext(ctx, db.revokeUserRefreshTokens).ExecContext(ctx, userID, exceptID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreatePersonalAccessToken stores a new token and returns its id.
+func (db *mysqlDatabase) CreatePersonalAccessToken(ctx context.Context, userID int, name string, tokenHash string, prefix string, scopes []string, expiresAt *time.Time) (int, error) {
+	result, err := db.createAccessToken.ExecContext(ctx, userID, name, tokenHash, prefix, strings.Join(scopes, ","), expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func scanAccessToken(row interface{ Scan(...any) error }) (*model.PersonalAccessToken, error) {
+	var (
+		token  = &model.PersonalAccessToken{}
+		scopes string
+	)
+	err := row.Scan(&token.Id, &token.UserID, &token.Name, &token.TokenHash, &token.Prefix, &scopes, &token.LastUsedAt, &token.LastUsedIP, &token.ExpiresAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	return token, nil
+}
+
+func (db *mysqlDatabase) GetPersonalAccessToken(ctx context.Context, tokenHash string) (*model.PersonalAccessToken, error) {
+	return scanAccessToken(db.getAccessToken.QueryRowContext(ctx, tokenHash))
+}
+
+// GetUserPersonalAccessTokens lists the tokens of a user that still work.
+func (db *mysqlDatabase) GetUserPersonalAccessTokens(ctx context.Context, userID int) ([]model.PersonalAccessToken, error) {
+	rows, err := db.getUserAccessTokens.QueryContext(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []model.PersonalAccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchPersonalAccessToken records the use of a token, at most once a minute.
+func (db *mysqlDatabase) TouchPersonalAccessToken(ctx context.Context, tokenID int, ip string) error {
+	_, err := db.touchAccessToken.ExecContext(ctx, ip, tokenID)
+	return err
+}
+
+// RevokePersonalAccessToken reports false if the user has no such active
+// token.
+func (db *mysqlDatabase) RevokePersonalAccessToken(ctx context.Context, tokenID int, userID int) (bool, error) {
+	result, err := db.revokeAccessTokenByID.ExecContext(ctx, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// RevokeUserAccessTokens revokes every personal access token of a user, e.g.
+// when the account is recovered and tokens minted by an intruder must stop
+// working.
+func (db *mysqlDatabase) RevokeUserAccessTokens(ctx context.Context, userID int) error {
+	_, err := db.revokeUserAccessTokens.ExecContext(ctx, userID)
+	return err
+}
+
+// ImportGraduates adds or updates registry records by matric number in one
+// transaction and returns how many rows changed. Claims are left alone.
+func (db *mysqlDatabase) ImportGraduates(ctx context.Context, graduates []model.Graduate) (int, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt := tx.StmtContext(ctx, db.importGraduate)
+	changed := 0
+	for _, graduate := range graduates {
+		result, err := stmt.ExecContext(ctx, graduate.MatricNumber, graduate.FullName, graduate.Faculty, graduate.Degree, graduate.GradYear)
+		if err != nil {
+			return 0, fmt.Errorf("import %s: %w", graduate.MatricNumber, err)
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+			changed++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+func (db *mysqlDatabase) GetGraduate(ctx context.Context, matricNumber string) (*model.Graduate, error) {
+	graduate := &model.Graduate{}
+	err := db.getGraduate.QueryRowContext(ctx, matricNumber).Scan(&graduate.Id, &graduate.MatricNumber, &graduate.FullName, &graduate.Faculty, &graduate.Degree, &graduate.GradYear, &graduate.ClaimedBy, &graduate.ClaimedAt)
+	if err != nil {
+		return nil, err
+	}
+	return graduate, nil
+}
+
+// ClaimGraduate links a registry record to a user and marks the user a
+// verified alumnus, taking degree and graduation year from the registry. It
+// reports false if the record was claimed first.
+func (db *mysqlDatabase) ClaimGraduate(ctx context.Context, graduateID int, userID int) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	result, err := tx.StmtContext(ctx, db.claimGraduate).ExecContext(ctx, userID, graduateID)
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+	if _, err := tx.StmtContext(ctx, db.verifyAlumnus).ExecContext(ctx, userID, graduateID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateInviteCode stores a new invite code and returns its id.
+func (db *mysqlDatabase) CreateInviteCode(ctx context.Context, codeHash string, prefix string, note string, maxUses int, expiresAt *time.Time, createdBy int) (int, error) {
+	result, err := db.createInviteCode.ExecContext(ctx, codeHash, prefix, note, maxUses, expiresAt, createdBy)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// GetInviteCodes lists the invite codes that can still be redeemed.
+func (db *mysqlDatabase) GetInviteCodes(ctx context.Context) ([]model.InviteCode, error) {
+	rows, err := db.getInviteCodes.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	invites := []model.InviteCode{}
+	for rows.Next() {
+		var invite model.InviteCode
+		if err := rows.Scan(&invite.Id, &invite.Prefix, &invite.Note, &invite.MaxUses, &invite.Uses, &invite.ExpiresAt, &invite.CreatedBy, &invite.CreatedAt, &invite.RevokedAt); err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// RedeemInviteCode uses up one use of a code. It reports false if the code is
+// unknown, revoked, expired or used up.
+func (db *mysqlDatabase) RedeemInviteCode(ctx context.Context, codeHash string) (bool, error) {
+	result, err := db.redeemInviteCode.ExecContext(ctx, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReleaseInviteCode gives back a use taken by RedeemInviteCode, for when the
+// registration it was redeemed for fails.
+func (db *mysqlDatabase) ReleaseInviteCode(ctx context.Context, codeHash string) error {
+	_, err := db.releaseInviteCode.ExecContext(ctx, codeHash)
+	return err
+}
+
+func (db *mysqlDatabase) RevokeInviteCode(ctx context.Context, inviteID int) (bool, error) {
+	result, err := db.revokeInviteCode.ExecContext(ctx, inviteID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ExportUserData collects the content a user has written and the records
+// that involve them.
+func (db *mysqlDatabase) ExportUserData(ctx context.Context, user *model.User) (*model.UserExport, error) {
+	export := &model.UserExport{
+		ForumPosts:    []model.Forum{},
+		Comments:      []model.ExportedComment{},
+		Chats:         []model.Chat{},
+		GroupMessages: []model.ExportedGroupMessage{},
+		Transactions:  []model.Transaction{},
+	}
+	queries := []struct {
+		stmt *sql.Stmt
+		args []any
+		scan func(row *sql.Rows) error
+	}{
+		{db.exportForumPosts, []any{user.Email}, func(row *sql.Rows) error {
+			var forum model.Forum
+			if err := row.Scan(&forum.Id, &forum.Title, &forum.Description, &forum.Author, &forum.Slug, &forum.CreatedAt, &forum.UpdatedAt, &forum.DeletedAt); err != nil {
+				return err
+			}
+			export.ForumPosts = append(export.ForumPosts, forum)
+			return nil
+		}},
+		{db.exportComments, []any{user.Id}, func(row *sql.Rows) error {
+			var comment model.ExportedComment
+			if err := row.Scan(&comment.ID, &comment.ForumSlug, &comment.ParentID, &comment.Comment, &comment.CreatedAt); err != nil {
+				return err
+			}
+			export.Comments = append(export.Comments, comment)
+			return nil
+		}},
+		{db.exportChats, []any{user.Id, user.Id}, func(row *sql.Rows) error {
+			var chat model.Chat
+			if err := row.Scan(&chat.Id, &chat.SenderID, &chat.RecipientID, &chat.Message, &chat.CreatedAt, &chat.UpdatedAt); err != nil {
+				return err
+			}
+			export.Chats = append(export.Chats, chat)
+			return nil
+		}},
+		{db.exportGroupMessages, []any{user.Id}, func(row *sql.Rows) error {
+			var message model.ExportedGroupMessage
+			if err := row.Scan(&message.ID, &message.GroupID, &message.GroupName, &message.Message, &message.CreatedAt); err != nil {
+				return err
+			}
+			export.GroupMessages = append(export.GroupMessages, message)
+			return nil
+		}},
+		{db.exportFollows, []any{user.Id}, func(row *sql.Rows) error {
+			var follow model.ExportedFollow
+			if err := row.Scan(&follow.FollowerID, &follow.FolloweeID, &follow.Status, &follow.CreatedAt); err != nil {
+				return err
+			}
+			export.Follows = append(export.Follows, follow)
+			return nil
+		}},
+		{db.exportTransactions, []any{user.Id, user.Id, user.Email}, func(row *sql.Rows) error {
+			var transaction model.Transaction
+			if err := row.Scan(&transaction.Id, &transaction.FromUserID, &transaction.FromUserEmail, &transaction.ToUserID, &transaction.ToUserEmail, &transaction.TransactionType, &transaction.CreatedAt, &transaction.UpdatedAt, &transaction.Amount, &transaction.UserEmail); err != nil {
+				return err
+			}
+			export.Transactions = append(export.Transactions, transaction)
+			return nil
+		}},
+	}
+	for _, query := range queries {
+		if err := scanRows(ctx, query.stmt, query.args, query.scan); err != nil {
+			return nil, err
+		}
+	}
+	connections, err := db.GetUserConnections(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	export.Connections = connections
+	if export.Experiences, err = db.GetUserExperiences(ctx, user.Id); err != nil {
+		return nil, err
+	}
+	if export.Educations, err = db.GetUserEducations(ctx, user.Id); err != nil {
+		return nil, err
+	}
+	if export.Skills, err = db.GetUserSkills(ctx, user.Id, 0); err != nil {
+		return nil, err
+	}
+	if export.Blocks, err = db.GetBlockedUsers(ctx, user.Id); err != nil {
+		return nil, err
+	}
+	if export.Mutes, err = db.GetMutedUsers(ctx, user.Id); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func scanRows(ctx context.Context, stmt *sql.Stmt, args []any, scan func(row *sql.Rows) error) error {
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (db *mysqlDatabase) ScheduleAccountDeletion(ctx context.Context, userID int, at time.Time) error {
+	_, err := db.scheduleAccountDeletion.ExecContext(ctx, at, userID)
+	return err
+}
+
+// CancelAccountDeletion reports false if no deletion was scheduled.
+func (db *mysqlDatabase) CancelAccountDeletion(ctx context.Context, userID int) (bool, error) {
+	result, err := db.cancelAccountDeletion.ExecContext(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetAccountsDueForDeletion lists users whose deletion grace period is over.
+func (db *mysqlDatabase) GetAccountsDueForDeletion(ctx context.Context) ([]int, error) {
+	rows, err := db.getAccountsDueForDeletion.QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AnonymizeUser deletes a user's personal data and signs them out everywhere
+// in one transaction, see anonymizeUserQueries.
+func (db *mysqlDatabase) AnonymizeUser(ctx context.Context, userID int) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i, stmt := range db.anonymizeUser {
+		if _, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, userID); err != nil {
+			return fmt.Errorf("anonymize user step %d: %w", i+1, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func scanExperience(row interface{ Scan(...any) error }) (*model.Experience, error) {
+	experience := &model.Experience{}
+	err := row.Scan(&experience.Id, &experience.UserID, &experience.Company, &experience.Title, &experience.Location, &experience.Start, &experience.End, &experience.Description, &experience.CreatedAt, &experience.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return experience, nil
+}
+
+func (db *mysqlDatabase) CreateExperience(ctx context.Context, experience *model.Experience) (int, error) {
+	result, err := db.createExperience.ExecContext(ctx, experience.UserID, experience.Company, experience.Title, experience.Location, experience.Start, experience.End, experience.Description)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetExperience returns one of the user's experiences, sql.ErrNoRows if
+// it belongs to someone else.
+func (db *mysqlDatabase) GetExperience(ctx context.Context, userID int, experienceID int) (*model.Experience, error) {
+	return scanExperience(db.getExperience.QueryRowContext(ctx, experienceID, userID))
+}
+
+func (db *mysqlDatabase) GetUserExperiences(ctx context.Context, userID int) ([]model.Experience, error) {
+	experiences := []model.Experience{}
+	err := scanRows(ctx, db.getExperiences, []any{userID}, func(row *sql.Rows) error {
+		experience, err := scanExperience(row)
+		if err != nil {
+			return err
+		}
+		experiences = append(experiences, *experience)
+		return nil
+	})
+	return experiences, err
+}
+
+func (db *mysqlDatabase) UpdateExperience(ctx context.Context, experience *model.Experience) error {
+	_, err := db.updateExperience.ExecContext(ctx, experience.Company, experience.Title, experience.Location, experience.Start, experience.End, experience.Description, experience.Id, experience.UserID)
+	return err
+}
+
+// DeleteExperience reports false if the user has no such experience.
+func (db *mysqlDatabase) DeleteExperience(ctx context.Context, userID int, experienceID int) (bool, error) {
+	result, err := db.deleteExperience.ExecContext(ctx, experienceID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func scanEducation(row interface{ Scan(...any) error }) (*model.Education, error) {
+	education := &model.Education{}
+	err := row.Scan(&education.Id, &education.UserID, &education.Institution, &education.Degree, &education.FieldOfStudy, &education.StartYear, &education.EndYear, &education.CreatedAt, &education.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return education, nil
+}
+
+func (db *mysqlDatabase) CreateEducation(ctx context.Context, education *model.Education) (int, error) {
+	result, err := db.createEducation.ExecContext(ctx, education.UserID, education.Institution, education.Degree, education.FieldOfStudy, education.StartYear, education.EndYear)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetEducation returns one of the user's educations, sql.ErrNoRows if it
+// belongs to someone else.
+func (db *mysqlDatabase) GetEducation(ctx context.Context, userID int, educationID int) (*model.Education, error) {
+	return scanEducation(db.getEducation.QueryRowContext(ctx, educationID, userID))
+}
+
+func (db *mysqlDatabase) GetUserEducations(ctx context.Context, userID int) ([]model.Education, error) {
+	educations := []model.Education{}
+	err := scanRows(ctx, db.getEducations, []any{userID}, func(row *sql.Rows) error {
+		education, err := scanEducation(row)
+		if err != nil {
+			return err
+		}
+		educations = append(educations, *education)
+		return nil
+	})
+	return educations, err
+}
+
+func (db *mysqlDatabase) UpdateEducation(ctx context.Context, education *model.Education) error {
+	_, err := db.updateEducation.ExecContext(ctx, education.Institution, education.Degree, education.FieldOfStudy, education.StartYear, education.EndYear, education.Id, education.UserID)
+	return err
+}
+
+// DeleteEducation reports false if the user has no such education.
+func (db *mysqlDatabase) DeleteEducation(ctx context.Context, userID int, educationID int) (bool, error) {
+	result, err := db.deleteEducation.ExecContext(ctx, educationID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
-	if database.sendGroupMessage, err = db.Prepare(sendGroupMessage); err != nil {
-		return nil, err
+	return affected > 0, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// AddUserSkill lists a skill on the user's profile, adding it to the
+// catalogue if it is new. added is false if the user already listed it.
+func (db *mysqlDatabase) AddUserSkill(ctx context.Context, userID int, name string, key string) (skillID int, added bool, err error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
 	}
-	if database.getGroupMessages, err = db.Prepare(getGroupMessages); err != nil {
-		return nil, err
+	defer tx.Rollback()
+	result, err := tx.StmtContext(ctx, db.upsertSkill).ExecContext(ctx, name, key)
+	if err != nil {
+		return 0, false, err
 	}
-	if database.getGroupAdmin, err = db.Prepare(getGroupAdmin); err != nil {
-		return nil, err
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
 	}
-	if database.checkIfMember, err = db.Prepare(checkIfMember); err != nil {
-		return nil, err
+	if result, err = tx.StmtContext(ctx, db.addUserSkill).ExecContext(ctx, userID, id); err != nil {
+		return 0, false, err
 	}
-	if database.getChats, err = db.Prepare(getChats); err != nil {
-		return nil, err
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, err
 	}
-	return database, nil
+	return int(id), affected > 0, tx.Commit()
 }
 
-func (db *mysqlDatabase) CreateUser(ctx context.Context, username string, password string, email string, degree string, gradyear string, currentjob string, phone string, sessionkey string, profilepicture string, linkedinprofile string, twitterprofile string) (bool, error) {
-	userQuery, err := db.createUser.ExecContext(ctx, username, password, email, degree, gradyear, currentjob, phone, sessionkey, profilepicture, linkedinprofile, twitterprofile)
+// RemoveUserSkill takes a skill off the profile along with its
+// endorsements. It reports false if the user did not list it.
+func (db *mysqlDatabase) RemoveUserSkill(ctx context.Context, userID int, skillID int) (bool, error) {
+	result, err := db.removeUserSkill.ExecContext(ctx, userID, skillID)
 	if err != nil {
 		return false, err
 	}
-	lastId, err := userQuery.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return false, err
 	}
-	if lastId == 0 || lastId < 1 {
+	return affected > 0, nil
+}
+
+// GetUserSkills returns the user's skills, most endorsed first, marking the
+// ones viewerID endorsed.
+func (db *mysqlDatabase) GetUserSkills(ctx context.Context, userID int, viewerID int) ([]model.UserSkill, error) {
+	skills := []model.UserSkill{}
+	err := scanRows(ctx, db.getUserSkills, []any{viewerID, userID}, func(row *sql.Rows) error {
+		var skill model.UserSkill
+		if err := row.Scan(&skill.SkillID, &skill.Name, &skill.Endorsements, &skill.EndorsedByViewer); err != nil {
+			return err
+		}
+		skills = append(skills, skill)
+		return nil
+	})
+	return skills, err
+}
+
+// SearchSkills suggests catalogue skills whose key starts with prefix, the
+// most listed first.
+func (db *mysqlDatabase) SearchSkills(ctx context.Context, prefix string, limit int) ([]model.Skill, error) {
+	skills := []model.Skill{}
+	err := scanRows(ctx, db.searchSkills, []any{likeEscaper.Replace(prefix) + "%", limit}, func(row *sql.Rows) error {
+		var skill model.Skill
+		if err := row.Scan(&skill.Id, &skill.Name, &skill.Users); err != nil {
+			return err
+		}
+		skills = append(skills, skill)
+		return nil
+	})
+	return skills, err
+}
+
+// EndorseSkill records endorserID vouching for a skill the user lists. It
+// reports false if the endorsement already exists.
+func (db *mysqlDatabase) EndorseSkill(ctx context.Context, endorserID int, userID int, skillID int) (bool, error) {
+	result, err := db.endorseSkill.ExecContext(ctx, userID, skillID, endorserID)
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
-	user := &model.User{}
-	getUserByEmail := db.getUserByEmail.QueryRowContext(ctx, email)
-	err := getUserByEmail.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.Degree, &user.GradYear, &user.CurrentJob, &user.Phone, &user.SessionKey, &user.ProfilePicture, &user.LinkedinProfile, &user.TwitterProfile, &user.CreatedAt, &user.UpdatedAt)
+// WithdrawEndorsement reports false if there was no such endorsement.
+func (db *mysqlDatabase) WithdrawEndorsement(ctx context.Context, endorserID int, userID int, skillID int) (bool, error) {
+	result, err := db.withdrawEndorsement.ExecContext(ctx, userID, skillID, endorserID)
 	if err != nil {
-		log.Println("get user by email", err)
-		return nil, err
+		return false, err
 	}
-	return user, nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) CheckUser(ctx context.Context, email string, password string) (*model.User, error) {
-	user := &model.User{}
-	getUserByEmail := db.checkUser.QueryRowContext(ctx, email, password)
-	err := getUserByEmail.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.Degree, &user.GradYear, &user.CurrentJob, &user.Phone, &user.SessionKey, &user.ProfilePicture, &user.LinkedinProfile, &user.TwitterProfile, &user.CreatedAt, &user.UpdatedAt)
+// visibleToViewer is a condition on users u that holds where the viewer may
+// see field, following the user's privacy setting or model.PrivacyDefaults.
+func visibleToViewer(field string, viewerID int) (string, []any) {
+	visibility := "COALESCE((SELECT pp.visibility FROM profile_privacy pp WHERE pp.user_id = u.id AND pp.field = ?), ?)"
+	condition := "(u.id = ? OR " + visibility + " = 'public' OR (" + visibility + " = 'connections' AND EXISTS (" +
+		"SELECT 1 FROM connection_requests cr WHERE cr.status = 'accepted' AND ((cr.requester_id = u.id AND cr.recipient_id = ?) OR (cr.requester_id = ? AND cr.recipient_id = u.id)))))"
+	fallback := model.PrivacyDefaults[field]
+	return condition, []any{viewerID, field, fallback, field, fallback, viewerID, viewerID}
+}
+
+// SearchUsers runs a directory search. The filters vary per search, so the
+// query is built here rather than prepared. Fields are only shown, and only
+// searched, where the searcher may see them, and members with a block
+// between them and the searcher are left out. Pages continue after
+// search.After by comparing the sort column and id, which stays correct
+// while members join or edit their profiles.
+func (db *mysqlDatabase) SearchUsers(ctx context.Context, search model.DirectorySearch) ([]model.DirectoryEntry, error) {
+	jobVisible, jobVisibleArgs := visibleToViewer(model.PrivacyCurrentJob, search.ViewerID)
+
+	where := []string{"u.deleted_at IS NULL", "u.deletion_scheduled_at IS NULL",
+		"NOT EXISTS (SELECT 1 FROM user_blocks b WHERE (b.blocker_id = u.id AND b.blocked_id = ?) OR (b.blocker_id = ? AND b.blocked_id = u.id))"}
+	args := append([]any{}, jobVisibleArgs...)
+	args = append(args, search.ViewerID, search.ViewerID)
+	if search.NamePrefix != "" {
+		where = append(where, "u.username LIKE ?")
+		args = append(args, likeEscaper.Replace(search.NamePrefix)+"%")
+	}
+	if search.Degree != "" {
+		where = append(where, "u.degree = ?")
+		args = append(args, search.Degree)
+	}
+	if search.GradYearFrom != "" {
+		where = append(where, "u.grad_year >= ?")
+		args = append(args, search.GradYearFrom)
+	}
+	if search.GradYearTo != "" {
+		where = append(where, "u.grad_year <= ?")
+		args = append(args, search.GradYearTo)
+	}
+	if search.JobKeyword != "" {
+		where = append(where, "u.current_job LIKE ?", jobVisible)
+		args = append(args, "%"+likeEscaper.Replace(search.JobKeyword)+"%")
+		args = append(args, jobVisibleArgs...)
+	}
+	if search.Company != "" {
+		visible, visibleArgs := visibleToViewer(model.PrivacyExperience, search.ViewerID)
+		where = append(where, "EXISTS (SELECT 1 FROM experiences e WHERE e.user_id = u.id AND e.company LIKE ?)", visible)
+		args = append(args, "%"+likeEscaper.Replace(search.Company)+"%")
+		args = append(args, visibleArgs...)
+	}
+	for _, key := range search.Skills {
+		where = append(where, "EXISTS (SELECT 1 FROM user_skills us JOIN skills s ON s.id = us.skill_id WHERE us.user_id = u.id AND s.skill_key = ?)")
+		args = append(args, key)
+	}
+	if search.Institution != "" {
+		visible, visibleArgs := visibleToViewer(model.PrivacyEducation, search.ViewerID)
+		where = append(where, "EXISTS (SELECT 1 FROM educations ed WHERE ed.user_id = u.id AND ed.institution LIKE ?)", visible)
+		args = append(args, "%"+likeEscaper.Replace(search.Institution)+"%")
+		args = append(args, visibleArgs...)
+	}
+
+	var order string
+	switch search.Sort {
+	case model.DirectorySortGradYear:
+		order = "COALESCE(u.grad_year, '') DESC, u.id ASC"
+		if search.After != nil {
+			where = append(where, "(COALESCE(u.grad_year, '') < ? OR (COALESCE(u.grad_year, '') = ? AND u.id > ?))")
+			args = append(args, search.After.Key, search.After.Key, search.After.Id)
+		}
+	case model.DirectorySortRecent:
+		order = "u.id DESC"
+		if search.After != nil {
+			where = append(where, "u.id < ?")
+			args = append(args, search.After.Id)
+		}
+	default:
+		order = "u.username ASC, u.id ASC"
+		if search.After != nil {
+			where = append(where, "(u.username > ? OR (u.username = ? AND u.id > ?))")
+			args = append(args, search.After.Key, search.After.Key, search.After.Id)
+		}
+	}
+	query := "SELECT u.id, u.username, COALESCE(u.degree, ''), COALESCE(u.grad_year, ''), IF(" + jobVisible + ", COALESCE(u.current_job, ''), ''), COALESCE(u.profile_picture, ''), u.alumni_verified_at IS NOT NULL" +
+		" FROM users u WHERE " + strings.Join(where, " AND ") + " ORDER BY " + order + " LIMIT ?"
+	args = append(args, search.Limit)
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Println("checkuser", err)
 		return nil, err
 	}
-	return user, nil
+	defer rows.Close()
+	entries := []model.DirectoryEntry{}
+	for rows.Next() {
+		var entry model.DirectoryEntry
+		if err := rows.Scan(&entry.Id, &entry.Username, &entry.Degree, &entry.GradYear, &entry.CurrentJob, &entry.ProfilePicture, &entry.VerifiedAlumnus); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
 }
 
-func (db *mysqlDatabase) GetBySessionKey(ctx context.Context, sessionkey string) (*model.User, error) {
-	user := &model.User{}
-	getBySessionKey := db.getBySessionKey.QueryRowContext(ctx, sessionkey)
-	err := getBySessionKey.Scan(&user.Id, &user.Username, &user.Password, &user.Email, &user.Degree, &user.GradYear, &user.CurrentJob, &user.Phone, &user.SessionKey, &user.ProfilePicture, &user.LinkedinProfile, &user.TwitterProfile, &user.CreatedAt, &user.UpdatedAt)
+// GetRecommendationSignals returns every candidate for the user's "people you
+// may know", see recommendationSignalsQuery. Members with the given degree
+// graduating between gradYearFrom and gradYearTo are candidates even with
+// nothing else in common.
+func (db *mysqlDatabase) GetRecommendationSignals(ctx context.Context, userID int, degree string, gradYearFrom string, gradYearTo string) ([]model.RecommendationSignals, error) {
+	args := []any{degree, gradYearFrom, gradYearTo, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID, userID}
+	signals := []model.RecommendationSignals{}
+	err := scanRows(ctx, db.getRecommendationSignals, args, func(row *sql.Rows) error {
+		var candidate model.RecommendationSignals
+		if err := row.Scan(&candidate.CandidateID, &candidate.Degree, &candidate.GradYear, &candidate.SharedGroups, &candidate.SharedForums, &candidate.MutualConnections); err != nil {
+			return err
+		}
+		signals = append(signals, candidate)
+		return nil
+	})
+	return signals, err
+}
+
+// SaveRecommendations replaces the user's cached recommendations and marks
+// them computed now.
+func (db *mysqlDatabase) SaveRecommendations(ctx context.Context, userID int, recommendations []model.Recommendation) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return user, nil
+	defer tx.Rollback()
+	if _, err := tx.StmtContext(ctx, db.deleteRecommendations).ExecContext(ctx, userID); err != nil {
+		return err
+	}
+	insert := tx.StmtContext(ctx, db.insertRecommendation)
+	for _, rec := range recommendations {
+		if _, err := insert.ExecContext(ctx, userID, rec.Id, rec.Score, rec.MutualConnections, rec.SharedGroups, rec.SharedForums, rec.SameDegree); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.StmtContext(ctx, db.setRecommendationsAt).ExecContext(ctx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (db *mysqlDatabase) GetUserPortfolio(ctx context.Context, user_email string) (*[]model.PortfolioOrder, error) {
-	var portfolioOrders = []model.PortfolioOrder{}
-	var portfolioOrder = model.PortfolioOrder{}
-	getPortfolio, err := db.getUserPortfolios.QueryContext(ctx, user_email)
-	if err != nil {
+// GetRecommendationsAt returns when the user's recommendations were last
+// computed, nil if they never were.
+func (db *mysqlDatabase) GetRecommendationsAt(ctx context.Context, userID int) (*time.Time, error) {
+	var computedAt sql.NullTime
+	if err := db.getRecommendationsAt.QueryRowContext(ctx, userID).Scan(&computedAt); err != nil {
 		return nil, err
 	}
-	defer getPortfolio.Close()
-	for getPortfolio.Next() {
-		err := getPortfolio.Scan(&portfolioOrder.Id, &portfolioOrder.Type, &portfolioOrder.Security, &portfolioOrder.Unit, &portfolioOrder.Status, &portfolioOrder.Cancelled, &portfolioOrder.UserID, &portfolioOrder.UserEmail, &portfolioOrder.CreatedAt, &portfolioOrder.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
+	if !computedAt.Valid {
+		return nil, nil
 	}
-	portfolioOrders = append(portfolioOrders, portfolioOrder)
-	return &portfolioOrders, nil
+	return &computedAt.Time, nil
 }
 
-func (db *mysqlDatabase) GetUserTransactions(ctx context.Context, user_email string) (*[]model.Transaction, error) {
-	var transaction = model.Transaction{}
-	var transactions = []model.Transaction{}
-	getTransactions, err := db.getUserTransactions.QueryContext(ctx, user_email)
+// GetStaleRecommendationUserIDs returns up to limit users after afterID whose
+// recommendations were computed before, by id. Users who never asked for
+// recommendations are not included.
+func (db *mysqlDatabase) GetStaleRecommendationUserIDs(ctx context.Context, before time.Time, afterID int, limit int) ([]int, error) {
+	ids := []int{}
+	err := scanRows(ctx, db.getStaleRecommendationUserIDs, []any{before, afterID, limit}, func(row *sql.Rows) error {
+		var id int
+		if err := row.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+// GetRecommendations returns the user's cached recommendations, best first.
+// Members who were deleted, or who the user connected with or blocked
+// since, are skipped, and current jobs are shown as the privacy settings allow.
+func (db *mysqlDatabase) GetRecommendations(ctx context.Context, userID int, limit int) ([]model.Recommendation, error) {
+	jobVisible, jobVisibleArgs := visibleToViewer(model.PrivacyCurrentJob, userID)
+	query := "SELECT u.id, u.username, COALESCE(u.degree, ''), COALESCE(u.grad_year, ''), IF(" + jobVisible + ", COALESCE(u.current_job, ''), ''), COALESCE(u.profile_picture, ''), u.alumni_verified_at IS NOT NULL," +
+		" r.score, r.mutual_connections, r.shared_groups, r.shared_forums, r.same_degree FROM recommendations r JOIN users u ON u.id = r.candidate_id" +
+		" WHERE r.user_id = ? AND u.deleted_at IS NULL AND u.deletion_scheduled_at IS NULL" +
+		" AND NOT EXISTS (SELECT 1 FROM connection_requests cr WHERE cr.status IN ('pending', 'accepted') AND ((cr.requester_id = r.user_id AND cr.recipient_id = u.id) OR (cr.requester_id = u.id AND cr.recipient_id = r.user_id)))" +
+		" AND NOT EXISTS (SELECT 1 FROM user_blocks b WHERE (b.blocker_id = r.user_id AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = r.user_id))" +
+		" ORDER BY r.score DESC, u.id ASC LIMIT ?"
+	args := append(jobVisibleArgs, userID, limit)
+
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer getTransactions.Close()
-	for getTransactions.Next() {
-		err := getTransactions.Scan(&transaction.Id, &transaction.FromUserID, &transaction.FromUserEmail, &transaction.ToUserID, &transaction.ToUserEmail, &transaction.TransactionType, &transaction.CreatedAt, &transaction.UpdatedAt, &transaction.Amount, &transaction.UserEmail)
-		if err != nil {
+	defer rows.Close()
+	recommendations := []model.Recommendation{}
+	for rows.Next() {
+		var rec model.Recommendation
+		if err := rows.Scan(&rec.Id, &rec.Username, &rec.Degree, &rec.GradYear, &rec.CurrentJob, &rec.ProfilePicture, &rec.VerifiedAlumnus,
+			&rec.Score, &rec.MutualConnections, &rec.SharedGroups, &rec.SharedForums, &rec.SameDegree); err != nil {
 			return nil, err
 		}
+		recommendations = append(recommendations, rec)
 	}
-	transactions = append(transactions, transaction)
-	return &transactions, nil
+	return recommendations, rows.Err()
 }
 
-func (db *mysqlDatabase) CreateNewTransaction(ctx context.Context, from_user int, from_user_email string, to_user int, to_user_email string, transactiontype string, created_at time.Time, updated_at time.Time, amount int, user_email string) (bool, error) {
-	createNewTx, err := db.createNewTransaction.ExecContext(ctx, from_user, from_user_email, to_user, to_user_email, transactiontype, created_at, updated_at, amount, user_email)
+// BlockUser blocks blockedID for blockerID and drops any connection, pending
+// request or follow between them. It reports false if the block already
+// existed.
+func (db *mysqlDatabase) BlockUser(ctx context.Context, blockerID int, blockedID int) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return false, err
 	}
-	lastInsert, err := createNewTx.LastInsertId()
+	defer tx.Rollback()
+	result, err := tx.StmtContext(ctx, db.blockUser).ExecContext(ctx, blockerID, blockedID)
 	if err != nil {
 		return false, err
 	}
-	if lastInsert <= 0 {
-		return false, err
-	}
-	return true, nil
-}
-
-func (db *mysqlDatabase) AddNewForumPost(ctx context.Context, title string, description string, author string, slug string, created_at time.Time, updated_at time.Time) (bool, error) {
-	createNewForum, err := db.addNewForumPost.ExecContext(ctx, title, description, author, slug, created_at, updated_at)
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return false, err
 	}
-	lastInsert, err := createNewForum.LastInsertId()
-	if err != nil {
+	if _, err := tx.StmtContext(ctx, db.removeBlockedConnections).ExecContext(ctx, blockerID, blockedID, blockedID, blockerID); err != nil {
 		return false, err
 	}
-	if lastInsert <= 0 {
+	if _, err := tx.StmtContext(ctx, db.removeBlockedFollows).ExecContext(ctx, blockerID, blockedID, blockedID, blockerID); err != nil {
 		return false, err
 	}
-	return true, nil
+	return affected > 0, tx.Commit()
 }
 
-func (db *mysqlDatabase) SendMessage(ctx context.Context, senderId int, receiverId int, message string, createdAt time.Time, updatedAt time.Time) (bool, error) {
-	sendmessage, err := db.sendMessage.ExecContext(ctx, senderId, receiverId, message, createdAt, updatedAt)
+// UnblockUser reports false if blockerID had not blocked blockedID.
+func (db *mysqlDatabase) UnblockUser(ctx context.Context, blockerID int, blockedID int) (bool, error) {
+	result, err := db.unblockUser.ExecContext(ctx, blockerID, blockedID)
 	if err != nil {
 		return false, err
 	}
-	lastInsert, err := sendmessage.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return false, err
 	}
-	if lastInsert <= 0 {
-		return false, err
-	}
-	return true, nil
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) GetSingleForumPost(ctx context.Context, slug string) (*model.Forum, error) {
-	forum := &model.Forum{}
-	getForumBySlug := db.getSingleForumPost.QueryRowContext(ctx, slug)
-	err := getForumBySlug.Scan(&forum.Id, &forum.Title, &forum.Description, &forum.Author, &forum.Slug, &forum.CreatedAt, &forum.UpdatedAt)
-	if err != nil {
-		return nil, err
+// GetBlockedUsers returns the users userID blocked, latest first.
+func (db *mysqlDatabase) GetBlockedUsers(ctx context.Context, userID int) ([]model.RestrictedUser, error) {
+	return db.queryRestrictedUsers(ctx, db.getBlockedUsers, userID)
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (db *mysqlDatabase) IsBlocked(ctx context.Context, userID1 int, userID2 int) (bool, error) {
+	var count int
+	if err := db.checkBlocked.QueryRowContext(ctx, userID1, userID2, userID2, userID1).Scan(&count); err != nil {
+		return false, err
 	}
-	return forum, nil
+	return count > 0, nil
 }
 
-func (db *mysqlDatabase) GetAllForums(ctx context.Context) (*[]model.Forum, error) {
-	var forum = model.Forum{}
-	var forums = []model.Forum{}
-	getForums, err := db.getAllForums.QueryContext(ctx)
+// MuteUser reports false if muterID had already muted mutedID.
+func (db *mysqlDatabase) MuteUser(ctx context.Context, muterID int, mutedID int) (bool, error) {
+	result, err := db.muteUser.ExecContext(ctx, muterID, mutedID)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	for getForums.Next() {
-		err := getForums.Scan(&forum.Title, &forum.Description, &forum.Author, &forum.Slug, &forum.CreatedAt, &forum.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
-	forums = append(forums, forum)
-	return &forums, nil
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) AddComment(ctx context.Context, userID int, forumID int, comment string) (bool, error) {
-	incoming, err := db.addComment.ExecContext(ctx, userID, forumID, comment)
+// UnmuteUser reports false if muterID had not muted mutedID.
+func (db *mysqlDatabase) UnmuteUser(ctx context.Context, muterID int, mutedID int) (bool, error) {
+	result, err := db.unmuteUser.ExecContext(ctx, muterID, mutedID)
 	if err != nil {
 		return false, err
 	}
-	c_lid, err := incoming.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return false, err
 	}
-	if c_lid <= 0 {
-		return false, err
-	}
-	return true, nil
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) GetCommentsByForumID(ctx context.Context, forumID int) ([]model.Comment, error) {
-	comments := []model.Comment{}
-	rows, err := db.getCommentsByForum.QueryContext(ctx, forumID)
-	if err != nil {
-		return nil, err
+// GetMutedUsers returns the users userID muted, latest first.
+func (db *mysqlDatabase) GetMutedUsers(ctx context.Context, userID int) ([]model.RestrictedUser, error) {
+	return db.queryRestrictedUsers(ctx, db.getMutedUsers, userID)
+}
+
+// IsMuted reports whether muterID muted mutedID.
+func (db *mysqlDatabase) IsMuted(ctx context.Context, muterID int, mutedID int) (bool, error) {
+	var count int
+	if err := db.checkMuted.QueryRowContext(ctx, muterID, mutedID).Scan(&count); err != nil {
+		return false, err
 	}
-	defer rows.Close()
+	return count > 0, nil
+}
 
-	for rows.Next() {
-		var comment model.Comment
-		if err := rows.Scan(&comment.ID, &comment.Username, &comment.Comment, &comment.CreatedAt); err != nil {
-			return nil, err
+func (db *mysqlDatabase) queryRestrictedUsers(ctx context.Context, stmt *sql.Stmt, userID int) ([]model.RestrictedUser, error) {
+	users := []model.RestrictedUser{}
+	err := scanRows(ctx, stmt, []any{userID}, func(row *sql.Rows) error {
+		var user model.RestrictedUser
+		if err := row.Scan(&user.UserID, &user.Username, &user.CreatedAt); err != nil {
+			return err
 		}
-		comments = append(comments, comment)
-	}
-	return comments, nil
+		users = append(users, user)
+		return nil
+	})
+	return users, err
 }
 
-// CreateGroup creates a new group.
-func (db *mysqlDatabase) CreateGroup(ctx context.Context, name string, createdby int) (int, error) {
-	result, err := db.createGroup.ExecContext(ctx, name, createdby)
+// Follow makes followerID follow followeeID, pending if the followee
+// approves their followers. It returns the status of the follow and false if
+// it already existed.
+func (db *mysqlDatabase) Follow(ctx context.Context, followerID int, followeeID int) (string, bool, error) {
+	result, err := db.follow.ExecContext(ctx, followerID, followeeID)
 	if err != nil {
-		return 0, err
+		return "", false, err
 	}
-	g_lid, err := result.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("last insert id: %v", g_lid)
-		return 0, err
-	}
-	if g_lid <= 0 {
-		return 0, fmt.Errorf("unable to create new group")
+		return "", false, err
 	}
-	return int(g_lid), nil
+	status, err := db.GetFollowStatus(ctx, followerID, followeeID)
+	return status, affected > 0, err
 }
 
-// AddGroupMember adds a new member to the group.
-func (db *mysqlDatabase) AddGroupMember(ctx context.Context, groupID int, userID int) (bool, error) {
-	group, err := db.addGroupMember.ExecContext(ctx, groupID, userID)
+// RemoveFollow deletes the follow, or follow request, of followerID for
+// followeeID. It reports false if there was none.
+func (db *mysqlDatabase) RemoveFollow(ctx context.Context, followerID int, followeeID int) (bool, error) {
+	result, err := db.removeFollow.ExecContext(ctx, followerID, followeeID)
 	if err != nil {
 		return false, err
 	}
-	mem_lid, err := group.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("last insert id: %v", mem_lid)
 		return false, err
 	}
-	if mem_lid <= 0 {
-		return false, fmt.Errorf("unable to add new member")
-	}
-	return true, nil
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) SendGroupMessage(ctx context.Context, groupID int, userID int, message string) (bool, error) {
-	newMessage, err := db.sendGroupMessage.ExecContext(ctx, groupID, userID, message)
+// ApproveFollow accepts a pending follow request. It reports false if there
+// was no pending request.
+func (db *mysqlDatabase) ApproveFollow(ctx context.Context, followerID int, followeeID int) (bool, error) {
+	result, err := db.approveFollow.ExecContext(ctx, followerID, followeeID)
 	if err != nil {
 		return false, err
 	}
-	nm_lid, err := newMessage.LastInsertId()
+	affected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("last insert id: %v", nm_lid)
 		return false, err
 	}
-	if nm_lid <= 0 {
-		return false, fmt.Errorf("unable to send group message")
-	}
-	return true, nil
+	return affected > 0, nil
 }
 
-func (db *mysqlDatabase) GetGroupMessages(ctx context.Context, groupID int) ([]model.GroupMessage, error) {
-	messages := []model.GroupMessage{}
-	rows, err := db.getGroupMessages.QueryContext(ctx, groupID)
-	if err != nil {
-		return nil, err
+// GetFollowStatus returns the status of followerID's follow of followeeID,
+// empty if they don't follow them.
+func (db *mysqlDatabase) GetFollowStatus(ctx context.Context, followerID int, followeeID int) (string, error) {
+	var status string
+	err := db.getFollowStatus.QueryRowContext(ctx, followerID, followeeID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
-	defer rows.Close()
+	return status, err
+}
 
-	for rows.Next() {
-		var message model.GroupMessage
-		if err := rows.Scan(&message.ID, &message.Username, &message.Message, &message.CreatedAt); err != nil {
-			return nil, err
-		}
-		messages = append(messages, message)
+// GetFollowStats counts the accepted follows of and by userID, with the
+// status of viewerID's follow of them.
+func (db *mysqlDatabase) GetFollowStats(ctx context.Context, userID int, viewerID int) (*model.FollowStats, error) {
+	stats := &model.FollowStats{}
+	if err := db.getFollowStats.QueryRowContext(ctx, viewerID, userID).Scan(&stats.Followers, &stats.Following, &stats.RequireApproval, &stats.ViewerStatus); err != nil {
+		return nil, err
 	}
-	return messages, nil
+	return stats, nil
 }
 
-func (db *mysqlDatabase) GetGroupCreator(ctx context.Context, groupID int) (*model.User, error) {
-	user := &model.User{}
-
-	// Execute the query
-	row := db.getGroupAdmin.QueryRowContext(ctx, groupID)
-	err := row.Scan(&user.Id, &user.Username, &user.Email)
+// SetFollowApproval turns approving followers on or off. Turning it off
+// accepts the pending requests.
+func (db *mysqlDatabase) SetFollowApproval(ctx context.Context, userID int, required bool) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no group found with id %d", groupID)
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.StmtContext(ctx, db.setFollowApproval).ExecContext(ctx, required, userID); err != nil {
+		return err
+	}
+	if !required {
+		if _, err := tx.StmtContext(ctx, db.acceptPendingFollows).ExecContext(ctx, userID); err != nil {
+			return err
 		}
-		log.Println("Error fetching group creator:", err)
-		return nil, err
 	}
+	return tx.Commit()
+}
 
-	return user, nil
+// GetFollowers returns a page of the users following userID with the given
+// status, latest first. Pages continue after the cursor.
+func (db *mysqlDatabase) GetFollowers(ctx context.Context, userID int, status string, after *model.FollowCursor, limit int) ([]model.FollowEntry, error) {
+	return db.queryFollows(ctx, "f.follower_id", "f.followee_id", userID, status, after, limit)
 }
 
-// CheckGroupMembership checks if a user is a member of a specific group.
-func (db *mysqlDatabase) CheckGroupMembership(ctx context.Context, groupID int, userID int) (bool, error) {
-	var count int
-	err := db.checkIfMember.QueryRowContext(ctx, groupID, userID).Scan(&count)
-	if err != nil {
-		return false, err
-	}
-	return count > 0, nil
+// GetFollowing returns a page of the users userID follows with the given
+// status, latest first. Pages continue after the cursor.
+func (db *mysqlDatabase) GetFollowing(ctx context.Context, userID int, status string, after *model.FollowCursor, limit int) ([]model.FollowEntry, error) {
+	return db.queryFollows(ctx, "f.followee_id", "f.follower_id", userID, status, after, limit)
 }
 
-func (db *mysqlDatabase) FetchUserChats(ctx context.Context, userID1, userID2 int) ([]*model.Chat, error) {
+// queryFollows lists the users in listed of the follows where owner is
+// userID. Whether there is a cursor varies per request, so the query is
+// built here.
+func (db *mysqlDatabase) queryFollows(ctx context.Context, listed string, owner string, userID int, status string, after *model.FollowCursor, limit int) ([]model.FollowEntry, error) {
+	query := "SELECT u.id, u.username, COALESCE(u.profile_picture, ''), f.updated_at FROM follows f JOIN users u ON u.id = " + listed +
+		" WHERE " + owner + " = ? AND f.status = ? AND u.deleted_at IS NULL"
+	args := []any{userID, status}
+	if after != nil {
+		query += " AND (f.updated_at < ? OR (f.updated_at = ? AND u.id < ?))"
+		args = append(args, after.Since, after.Since, after.UserID)
+	}
+	query += " ORDER BY f.updated_at DESC, u.id DESC LIMIT ?"
+	args = append(args, limit)
 
-	rows, err := db.getChats.QueryContext(ctx, userID1, userID2, userID2, userID1)
+	rows, err := db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Println("Error fetching user chats:", err)
 		return nil, err
 	}
 	defer rows.Close()
-
-	var chats []*model.Chat
+	entries := []model.FollowEntry{}
 	for rows.Next() {
-		var chat model.Chat
-		err := rows.Scan(&chat.Id, &chat.SenderID, &chat.RecipientID, &chat.Message, &chat.CreatedAt, &chat.UpdatedAt)
-		if err != nil {
+		var entry model.FollowEntry
+		if err := rows.Scan(&entry.UserID, &entry.Username, &entry.ProfilePicture, &entry.Since); err != nil {
 			return nil, err
 		}
-		chats = append(chats, &chat)
+		entries = append(entries, entry)
 	}
-	return chats, nil
+	return entries, rows.Err()
 }
 
 func (db *mysqlDatabase) Close() error {
 	db.createUser.Close()
 	db.checkUser.Close()
-	db.getBySessionKey.Close()
 	db.getUserByEmail.Close()
 	db.getUserPortfolios.Close()
 	db.getUserTransactions.Close()
@@ -450,5 +3099,139 @@ func (db *mysqlDatabase) Close() error {
 	db.getGroupAdmin.Close()
 	db.checkIfMember.Close()
 	db.getChats.Close()
+	db.lockConnectionPair.Close()
+	db.createConnectionRequest.Close()
+	db.getConnectionRequest.Close()
+	db.getConnectionRequestBetween.Close()
+	db.updateConnectionRequestStatus.Close()
+	db.getIncomingConnectionRequests.Close()
+	db.getOutgoingConnectionRequests.Close()
+	db.getUserConnections.Close()
+	db.checkConnection.Close()
+	db.getUserByID.Close()
+	db.createRefreshToken.Close()
+	db.getRefreshToken.Close()
+	db.markRefreshTokenUsed.Close()
+	db.revokeSessionTokens.Close()
+	db.revokeUserRefreshTokens.Close()
+	db.revokeAccessToken.Close()
+	db.isAccessTokenRevoked.Close()
+	db.purgeRefreshTokens.Close()
+	db.purgeRevokedTokens.Close()
+	db.createPasswordReset.Close()
+	db.getPasswordReset.Close()
+	db.usePasswordReset.Close()
+	db.invalidatePasswordResets.Close()
+	db.updateUserPassword.Close()
+	db.markEmailVerified.Close()
+	db.markVerificationSent.Close()
+	db.updateUserProfile.Close()
+	db.setPendingEmail.Close()
+	db.confirmEmailChange.Close()
+	db.setProfilePicture.Close()
+	db.getUserByUsername.Close()
+	db.getPrivacy.Close()
+	db.setPrivacy.Close()
+	db.saveTwoFactorSecret.Close()
+	db.getTwoFactor.Close()
+	db.enableTwoFactor.Close()
+	db.deleteTwoFactor.Close()
+	db.useTwoFactorStep.Close()
+	db.deleteRecoveryCodes.Close()
+	db.addRecoveryCode.Close()
+	db.useRecoveryCode.Close()
+	db.setMFARequired.Close()
+	db.getRoles.Close()
+	db.getRole.Close()
+	db.setUserRole.Close()
+	db.recordLoginFailure.Close()
+	db.getLoginAttempt.Close()
+	db.lockLogin.Close()
+	db.resetLoginAttempts.Close()
+	db.purgeLoginAttempts.Close()
+	db.createSession.Close()
+	db.getSession.Close()
+	db.getUserSessions.Close()
+	db.touchSession.Close()
+	db.extendSession.Close()
+	db.revokeSession.Close()
+	db.revokeUserSessions.Close()
+	db.purgeSessions.Close()
+	db.createAccessToken.Close()
+	db.getAccessToken.Close()
+	db.getUserAccessTokens.Close()
+	db.touchAccessToken.Close()
+	db.revokeAccessTokenByID.Close()
+	db.revokeUserAccessTokens.Close()
+	db.importGraduate.Close()
+	db.getGraduate.Close()
+	db.claimGraduate.Close()
+	db.verifyAlumnus.Close()
+	db.createInviteCode.Close()
+	db.getInviteCodes.Close()
+	db.redeemInviteCode.Close()
+	db.releaseInviteCode.Close()
+	db.revokeInviteCode.Close()
+	db.exportForumPosts.Close()
+	db.exportComments.Close()
+	db.exportChats.Close()
+	db.exportGroupMessages.Close()
+	db.exportTransactions.Close()
+	db.scheduleAccountDeletion.Close()
+	db.cancelAccountDeletion.Close()
+	db.getAccountsDueForDeletion.Close()
+	for _, stmt := range db.anonymizeUser {
+		stmt.Close()
+	}
+	db.createExperience.Close()
+	db.getExperience.Close()
+	db.getExperiences.Close()
+	db.updateExperience.Close()
+	db.deleteExperience.Close()
+	db.createEducation.Close()
+	db.getEducation.Close()
+	db.getEducations.Close()
+	db.updateEducation.Close()
+	db.deleteEducation.Close()
+	db.upsertSkill.Close()
+	db.addUserSkill.Close()
+	db.removeUserSkill.Close()
+	db.getUserSkills.Close()
+	db.searchSkills.Close()
+	db.endorseSkill.Close()
+	db.withdrawEndorsement.Close()
+	db.getRecommendationSignals.Close()
+	db.deleteRecommendations.Close()
+	db.insertRecommendation.Close()
+	db.setRecommendationsAt.Close()
+	db.getRecommendationsAt.Close()
+	db.getStaleRecommendationUserIDs.Close()
+	db.blockUser.Close()
+	db.removeBlockedConnections.Close()
+	db.unblockUser.Close()
+	db.getBlockedUsers.Close()
+	db.checkBlocked.Close()
+	db.muteUser.Close()
+	db.unmuteUser.Close()
+	db.getMutedUsers.Close()
+	db.checkMuted.Close()
+	db.follow.Close()
+	db.removeFollow.Close()
+	db.approveFollow.Close()
+	db.getFollowStatus.Close()
+	db.getFollowStats.Close()
+	db.setFollowApproval.Close()
+	db.acceptPendingFollows.Close()
+	db.removeBlockedFollows.Close()
+	db.exportFollows.Close()
+	db.updateForumPost.Close()
+	db.deleteForumPost.Close()
+	db.addForumRevision.Close()
+	db.getForumRevisions.Close()
+	db.saveOldForumSlug.Close()
+	db.reuseOldForumSlug.Close()
+	db.getForumSlugTarget.Close()
+	db.addReply.Close()
+	db.getCommentParent.Close()
 	return nil
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &acceptHandler{}
+
+type acceptHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewAcceptHandler(logger *zap.Logger, db mysql.Database) *acceptHandler {
+	return &acceptHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+func (ah *acceptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accept_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), ah.logger, ah.db)
+	if err != nil {
+		accept_resp["err"] = "please sign in to access this page"
+		ah.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(accept_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.Atoi(r.FormValue("request_id"))
+	if err != nil {
+		accept_resp["err"] = "invalid connection request id"
+		ah.logger.Error("err parsing connection request id", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(accept_resp, 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	if _, err = ah.conn.AcceptConnectionRequest(r.Context(), requestID, userInfo.Id); err != nil {
+		accept_resp["err"] = "unable to accept connection request"
+		ah.logger.Error("err accepting connection request", zap.Int("request_id", requestID), zap.Error(err))
+		status, publicErr := connectionErrorResponse(err)
+		apiResponse(w, GetErrorResponseBytes(accept_resp, 30, publicErr), status)
+		return
+	}
+
+	accept_resp["request_id"] = requestID
+	accept_resp["status"] = model.ConnectionAccepted
+	accept_resp["message"] = "connection request accepted"
+	apiResponse(w, GetSuccessResponse(accept_resp, 30), http.StatusOK)
+}
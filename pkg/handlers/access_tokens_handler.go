@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	accessTokenPrefix     = "aln_" // marks tokens in logs and secret scanners
+	maxAccessTokens       = 20     // active tokens per user
+	maxAccessTokenDays    = 365
+	accessTokenPrefixSize = len(accessTokenPrefix) + 8
+)
+
+var _ http.Handler = &accessTokensHandler{}
+
+type accessTokensHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewAccessTokensHandler(logger *zap.Logger, mysqlclient mysql.Database) *accessTokensHandler {
+	return &accessTokensHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP lists the user's active personal access tokens.
+func (handler *accessTokensHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tokensres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		tokensres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(tokensres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := handler.mysqlclient.GetUserPersonalAccessTokens(r.Context(), userInfo.Id)
+	if err != nil {
+		tokensres["err"] = "unable to fetch access tokens"
+		handler.logger.Error("err fetching personal access tokens", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(tokensres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	tokensres["tokens"] = tokens
+	apiResponse(w, GetSuccessResponse(tokensres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &createAccessTokenHandler{}
+
+type createAccessTokenHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewCreateAccessTokenHandler(logger *zap.Logger, mysqlclient mysql.Database) *createAccessTokenHandler {
+	return &createAccessTokenHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP creates a personal access token. The token is only ever returned
+// here, the database keeps its hash.
+func (handler *createAccessTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	createres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		createres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || len(name) > 100 {
+		createres["err"] = "token name must be between 1 and 100 characters"
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+	scopes, err := parseScopes(r.FormValue("scopes"))
+	if err != nil {
+		createres["err"] = err.Error()
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+	var expiresAt *time.Time
+	if days := r.FormValue("expires_in_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || n > maxAccessTokenDays {
+			createres["err"] = "expires_in_days must be between 1 and " + strconv.Itoa(maxAccessTokenDays)
+			apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusBadRequest)
+			return
+		}
+		expiry := time.Now().AddDate(0, 0, n)
+		expiresAt = &expiry
+	}
+
+	existing, err := handler.mysqlclient.GetUserPersonalAccessTokens(r.Context(), userInfo.Id)
+	if err != nil {
+		createres["err"] = "unable to create access token, please try again"
+		handler.logger.Error("err fetching personal access tokens", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxAccessTokens {
+		createres["err"] = "you have too many access tokens, revoke one first"
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.NewRandomToken()
+	if err != nil {
+		createres["err"] = "unable to create access token, please try again"
+		handler.logger.Error("err generating personal access token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	token := accessTokenPrefix + secret
+	tokenID, err := handler.mysqlclient.CreatePersonalAccessToken(r.Context(), userInfo.Id, name, utils.HashToken(token), token[:accessTokenPrefixSize], scopes, expiresAt)
+	if err != nil {
+		createres["err"] = "unable to create access token, please try again"
+		handler.logger.Error("err storing personal access token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(createres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	handler.logger.Info("personal access token created", zap.Int("user_id", userInfo.Id), zap.Int("token_id", tokenID), zap.Strings("scopes", scopes))
+
+	createres["id"] = tokenID
+	createres["name"] = name
+	createres["token"] = token
+	createres["scopes"] = scopes
+	createres["expires_at"] = expiresAt
+	createres["message"] = "copy the token now, it will not be shown again"
+	apiResponse(w, GetSuccessResponse(createres, loginTTL), http.StatusCreated)
+}
+
+// parseScopes validates a comma separated scope list against model.Scopes.
+func parseScopes(raw string) ([]string, error) {
+	valid := map[string]bool{}
+	for _, scope := range model.Scopes {
+		valid[scope] = true
+	}
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range strings.Split(raw, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !valid[scope] {
+			return nil, fmt.Errorf("unknown scope %s, valid scopes are: %s", scope, strings.Join(model.Scopes, ", "))
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("please provide at least one scope: %s", strings.Join(model.Scopes, ", "))
+	}
+	return scopes, nil
+}
+
+var _ http.Handler = &revokeAccessTokenHandler{}
+
+type revokeAccessTokenHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRevokeAccessTokenHandler(logger *zap.Logger, mysqlclient mysql.Database) *revokeAccessTokenHandler {
+	return &revokeAccessTokenHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP revokes one of the user's personal access tokens by id.
+func (handler *revokeAccessTokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	revokeres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		revokeres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	tokenID, err := strconv.Atoi(r.FormValue("token_id"))
+	if err != nil || tokenID <= 0 {
+		revokeres["err"] = "token id not provided"
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := handler.mysqlclient.RevokePersonalAccessToken(r.Context(), tokenID, userInfo.Id)
+	if err != nil {
+		revokeres["err"] = "unable to revoke access token, please try again"
+		handler.logger.Error("err revoking personal access token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	// other users' tokens look the same as unknown ones
+	if !revoked {
+		revokeres["err"] = "access token not found"
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusNotFound)
+		return
+	}
+	revokeres["message"] = "access token revoked successfully"
+	apiResponse(w, GetSuccessResponse(revokeres, loginTTL), http.StatusOK)
+}
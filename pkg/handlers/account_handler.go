@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// exports allowed per user, they read every table the user appears in
+const (
+	exportLimit  = 3
+	exportWindow = time.Hour
+)
+
+var _ http.Handler = &exportHandler{}
+
+type exportHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	limiter     *ratelimit.SlidingWindow
+}
+
+func NewExportHandler(logger *zap.Logger, mysqlclient mysql.Database) *exportHandler {
+	return &exportHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		limiter:     ratelimit.NewSlidingWindow(exportLimit, exportWindow),
+	}
+}
+
+// exportProfile is the profile part of the export. The password hash and
+// internal fields are left out.
+func exportProfile(user *model.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                    user.Id,
+		"username":              user.Username,
+		"email":                 user.Email,
+		"phone":                 user.Phone,
+		"degree":                user.Degree,
+		"grad_year":             user.GradYear,
+		"current_job":           user.CurrentJob,
+		"profile_picture":       user.ProfilePicture,
+		"linkedin_profile":      user.LinkedinProfile,
+		"twitter_profile":       user.TwitterProfile,
+		"role":                  user.Role,
+		"email_verified_at":     user.EmailVerifiedAt,
+		"alumni_verified_at":    user.AlumniVerifiedAt,
+		"deletion_scheduled_at": user.DeletionScheduled,
+		"created_at":            user.CreatedAt,
+		"updated_at":            user.UpdatedAt,
+	}
+}
+
+// ServeHTTP sends the user a ZIP archive of JSON files with their profile and
+// everything they have written, or a single JSON document with ?format=json.
+func (handler *exportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	exportres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		exportres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(exportres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if ok, wait := handler.limiter.Allow(strconv.Itoa(userInfo.Id)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		exportres["err"] = "too many exports, please try again later"
+		apiResponse(w, GetErrorResponseBytes(exportres["err"], profileTTL, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	data, err := handler.mysqlclient.ExportUserData(r.Context(), userInfo)
+	if err != nil {
+		exportres["err"] = "unable to export your data, please try again"
+		handler.logger.Error("err exporting user data", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(exportres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", exportProfile(userInfo)},
+		{"forum_posts.json", data.ForumPosts},
+		{"comments.json", data.Comments},
+		{"chats.json", data.Chats},
+		{"group_messages.json", data.GroupMessages},
+		{"transactions.json", data.Transactions},
+		{"connections.json", data.Connections},
+		{"experiences.json", data.Experiences},
+		{"educations.json", data.Educations},
+		{"skills.json", data.Skills},
+		{"blocks.json", data.Blocks},
+		{"mutes.json", data.Mutes},
+		{"follows.json", data.Follows},
+	}
+	handler.logger.Info("user data exported", zap.Int("user_id", userInfo.Id))
+	filename := fmt.Sprintf("alumni-export-%s-%s", userInfo.Username, time.Now().Format("20060102"))
+
+	if r.URL.Query().Get("format") == "json" {
+		document := map[string]interface{}{}
+		for _, file := range files {
+			document[file.name[:len(file.name)-len(".json")]] = file.content
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(document); err != nil {
+			handler.logger.Error("err writing data export", zap.Error(err))
+		}
+		return
+	}
+
+	// headers are sent with the first write, so errors past here can only be logged
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			handler.logger.Error("err writing data export", zap.Error(err))
+			return
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.content); err != nil {
+			handler.logger.Error("err writing data export", zap.Error(err))
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		handler.logger.Error("err writing data export", zap.Error(err))
+	}
+}
+
+var _ http.Handler = &deleteAccountHandler{}
+
+type deleteAccountHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewDeleteAccountHandler(logger *zap.Logger, mysqlclient mysql.Database) *deleteAccountHandler {
+	return &deleteAccountHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP schedules the account for deletion after utils.AccountDeletionGrace.
+// Until then the user can sign in and cancel, afterwards the purge job
+// anonymizes the account.
+func (handler *deleteAccountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deleteres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		deleteres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if userInfo.DeletionScheduled != nil {
+		deleteres["err"] = "your account is already scheduled for deletion"
+		deleteres["deletion_scheduled_at"] = userInfo.DeletionScheduled
+		apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+	if !CheckPasswordHash(r.FormValue("password"), userInfo.Password) {
+		deleteres["err"] = "password incorrect"
+		handler.logger.Debug("wrong password deleting account", zap.Int("user_id", userInfo.Id))
+		apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, userInfo.Id)
+	if err != nil {
+		deleteres["err"] = "unable to delete account, please try again"
+		handler.logger.Error("err fetching two factor", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if twoFactor != nil && twoFactor.EnabledAt != nil {
+		valid, err := checkSecondFactor(r.Context(), handler.mysqlclient, twoFactor, r.FormValue("code"), r.FormValue("recovery_code"))
+		if err != nil || !valid {
+			deleteres["err"] = "invalid authentication code"
+			handler.logger.Debug("invalid second factor deleting account", zap.Int("user_id", userInfo.Id), zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	deleteAt := time.Now().Add(utils.AccountDeletionGrace)
+	if err := handler.mysqlclient.ScheduleAccountDeletion(r.Context(), userInfo.Id, deleteAt); err != nil {
+		deleteres["err"] = "unable to delete account, please try again"
+		handler.logger.Error("err scheduling account deletion", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(deleteres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	// other devices are signed out now, this one stays so the user can cancel
+	current, _ := r.Context().Value(utils.SessionIDKey).(string)
+	if err := handler.mysqlclient.RevokeUserSessions(r.Context(), userInfo.Id, current); err != nil {
+		handler.logger.Error("err revoking sessions for account deletion", zap.Error(err))
+	}
+	handler.logger.Info("account deletion scheduled", zap.Int("user_id", userInfo.Id), zap.Time("delete_at", deleteAt))
+
+	deleteres["deletion_scheduled_at"] = deleteAt
+	deleteres["message"] = fmt.Sprintf("your account will be deleted on %s, sign in before then to cancel", deleteAt.Format("2 January 2006"))
+	apiResponse(w, GetSuccessResponse(deleteres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &cancelDeletionHandler{}
+
+type cancelDeletionHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewCancelDeletionHandler(logger *zap.Logger, mysqlclient mysql.Database) *cancelDeletionHandler {
+	return &cancelDeletionHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP keeps an account that was scheduled for deletion.
+func (handler *cancelDeletionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cancelres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		cancelres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(cancelres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	cancelled, err := handler.mysqlclient.CancelAccountDeletion(r.Context(), userInfo.Id)
+	if err != nil {
+		cancelres["err"] = "unable to cancel account deletion, please try again"
+		handler.logger.Error("err cancelling account deletion", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(cancelres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		cancelres["err"] = "your account is not scheduled for deletion"
+		apiResponse(w, GetErrorResponseBytes(cancelres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+	handler.logger.Info("account deletion cancelled", zap.Int("user_id", userInfo.Id))
+	cancelres["message"] = "account deletion cancelled"
+	apiResponse(w, GetSuccessResponse(cancelres, loginTTL), http.StatusOK)
+}
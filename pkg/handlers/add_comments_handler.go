@@ -23,11 +23,11 @@ func NewCommentHandler(logger *zap.Logger, db mysql.Database) *commentHandler {
 
 func (ch *commentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	make_comment := map[string]interface{}{}
-	userInfo, err := utils.AuthenticateUser(r.Context(), ch.logger, ch.db)
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), ch.logger, ch.db)
 	if err != nil {
-		make_comment["err"] = "please sign in to access this page"
+		make_comment["err"] = err.Error()
 		ch.logger.Debug("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(make_comment["err"], 30, nil), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(make_comment["err"], 30, nil), authErrorStatus(err))
 		return
 	}
 
@@ -2,35 +2,41 @@ package handlers
 
 import (
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/slug"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 )
 
+// slugAttempts is how often a post is saved with a fresh slug when others
+// take it first.
+const slugAttempts = 3
+
 var _ http.Handler = &forumStruct{}
 
 type forumStruct struct {
 	logger *zap.Logger
 	Db     mysql.Database
+	slugs  *slug.Generator
 }
 
-func NewForumStruct(logger *zap.Logger, Db mysql.Database) *forumStruct {
+func NewForumStruct(logger *zap.Logger, Db mysql.Database, slugs *slug.Generator) *forumStruct {
 	return &forumStruct{
 		logger: logger,
 		Db:     Db,
+		slugs:  slugs,
 	}
 }
 
 func (fs *forumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	new_forum_response := map[string]interface{}{}
-	userInfo, err := utils.AuthenticateUser(r.Context(), fs.logger, fs.Db)
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), fs.logger, fs.Db)
 	if err != nil {
-		new_forum_response["err"] = "please sign in to access this page"
+		new_forum_response["err"] = err.Error()
 		fs.logger.Debug("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(new_forum_response["err"], 30, nil), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(new_forum_response["err"], 30, nil), authErrorStatus(err))
 		return
 	}
 	var (
@@ -60,9 +66,19 @@ func (fs *forumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slug := strings.Split(title, " ")
-	_slug := strings.Join(slug, "")
-	add_new_forum_post, err := fs.Db.AddNewForumPost(r.Context(), title, description, author, _slug, time.Now(), time.Now())
+	var (
+		_slug              string
+		add_new_forum_post bool
+	)
+	for attempt := 0; attempt < slugAttempts; attempt++ {
+		if _slug, err = fs.slugs.ForumSlug(r.Context(), title, 0); err != nil {
+			break
+		}
+		add_new_forum_post, err = fs.Db.AddNewForumPost(r.Context(), title, description, author, _slug, time.Now(), time.Now())
+		if err != mysql.ErrSlugTaken {
+			break
+		}
+	}
 	if err != nil {
 		fs.logger.Error("err creating new forum Post", zap.Error(err))
 		afp["error"] = err.Error()
@@ -71,7 +87,8 @@ func (fs *forumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if add_new_forum_post {
 		new_forum_response["title"] = title
-		new_forum_response["author"] = author
+		new_forum_response["author"] = userInfo.Username
+		new_forum_response["slug"] = _slug
 		new_forum_response["message"] = "forum post added successfully"
 		apiResponse(w, GetSuccessResponse(new_forum_response, 30), http.StatusOK)
 	}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -24,11 +25,11 @@ func NewAddGroupMemberHandler(logger *zap.Logger, db mysql.Database) *addGroupMe
 
 func (agh *addGroupMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	agh_resp := map[string]interface{}{}
-	userInfo, err := utils.AuthenticateUser(r.Context(), agh.logger, agh.db)
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), agh.logger, agh.db)
 	if err != nil {
-		agh_resp["err"] = "please sign in to access this page"
+		agh_resp["err"] = err.Error()
 		agh.logger.Error("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(agh_resp["err"], 30, nil), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(agh_resp["err"], 30, nil), authErrorStatus(err))
 		return
 	}
 	groupID, err := strconv.Atoi(r.FormValue("group_id"))
@@ -54,7 +55,8 @@ func (agh *addGroupMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		apiResponse(w, GetErrorResponseBytes(agh_resp["err"], 30, nil), http.StatusUnauthorized)
 		return
 	}
-	if admin.Id != userInfo.Id {
+	// group creators manage their own groups, moderators any group
+	if admin.Id != userInfo.Id && !utils.HasPermission(r.Context(), model.PermissionManageGroups) {
 		log.Printf("%d, %d", admin.Id, userInfo.Id)
 		agh_resp["err"] = "you are not allowed to add members to this group"
 		agh.logger.Warn("admin, user IDs do not match")
@@ -62,6 +64,19 @@ func (agh *addGroupMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	blocked, err := agh.db.IsBlocked(r.Context(), userInfo.Id, newUser)
+	if err != nil {
+		agh_resp["err"] = "unable to add user to group"
+		agh.logger.Error("err checking block", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(agh_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		agh_resp["err"] = "you cannot add this user to a group"
+		apiResponse(w, GetErrorResponseBytes(agh_resp["err"], 30, nil), http.StatusForbidden)
+		return
+	}
+
 	success, err := agh.db.AddGroupMember(r.Context(), groupID, newUser)
 	if err != nil || !success {
 		agh_resp["err"] = "unable to add user to group"
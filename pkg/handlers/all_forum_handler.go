@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +31,11 @@ func (fs *aforumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		apiResponse(w, GetErrorResponseBytes(all_forum_response, 30, nil), http.StatusInternalServerError)
 		return
 	}
-	apiResponse(w, GetSuccessResponse(get_all_posts, 30), http.StatusOK)
+	// authors are shown by username, their emails stay private
+	posts := make([]model.ForumPost, 0, len(*get_all_posts))
+	for i := range *get_all_posts {
+		posts = append(posts, model.NewForumPost(&(*get_all_posts)[i]))
+	}
+	apiResponse(w, GetSuccessResponse(posts, 30), http.StatusOK)
 
 }
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/registry"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// claim attempts allowed per user, so matric numbers cannot be enumerated
+const (
+	alumniClaimLimit  = 5
+	alumniClaimWindow = time.Hour
+)
+
+var _ http.Handler = &claimAlumniHandler{}
+
+type claimAlumniHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	limiter     *ratelimit.SlidingWindow
+}
+
+func NewClaimAlumniHandler(logger *zap.Logger, mysqlclient mysql.Database) *claimAlumniHandler {
+	return &claimAlumniHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		limiter:     ratelimit.NewSlidingWindow(alumniClaimLimit, alumniClaimWindow),
+	}
+}
+
+// ServeHTTP matches the signed in user to a graduate registry record by matric
+// number, name and graduation year. A match makes the user a verified
+// alumnus.
+func (handler *claimAlumniHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	claimres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		claimres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if userInfo.AlumniVerifiedAt != nil {
+		claimres["err"] = "your alumni status is already verified"
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+
+	var (
+		matric   = registry.NormalizeMatric(r.FormValue("matric_number"))
+		fullName = strings.TrimSpace(r.FormValue("full_name"))
+		gradYear = strings.TrimSpace(r.FormValue("grad_year"))
+	)
+	if matric == "" || fullName == "" || gradYear == "" {
+		claimres["err"] = "matric number, full name and graduation year are required"
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if ok, wait := handler.limiter.Allow(strconv.Itoa(userInfo.Id)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		claimres["err"] = "too many verification attempts, please try again later"
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	// unknown matric numbers and mismatched details get the same answer
+	graduate, err := handler.mysqlclient.GetGraduate(r.Context(), matric)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		claimres["err"] = "unable to verify alumni status, please try again"
+		handler.logger.Error("err fetching graduate record", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if err != nil || graduate.GradYear != gradYear || !registry.NameMatches(graduate.FullName, fullName) {
+		claimres["err"] = "no graduate record matches these details"
+		handler.logger.Info("alumni claim did not match", zap.Int("user_id", userInfo.Id), zap.String("matric_number", matric))
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusNotFound)
+		return
+	}
+
+	claimed, err := handler.mysqlclient.ClaimGraduate(r.Context(), graduate.Id, userInfo.Id)
+	if err != nil {
+		claimres["err"] = "unable to verify alumni status, please try again"
+		handler.logger.Error("err claiming graduate record", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		claimres["err"] = "this graduate record is already linked to another account, please contact the alumni office"
+		handler.logger.Warn("alumni claim for claimed record", zap.Int("user_id", userInfo.Id), zap.String("matric_number", matric))
+		apiResponse(w, GetErrorResponseBytes(claimres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+	handler.logger.Info("alumni status verified", zap.Int("user_id", userInfo.Id), zap.String("matric_number", matric))
+
+	claimres["verified_alumnus"] = true
+	claimres["faculty"] = graduate.Faculty
+	claimres["degree"] = graduate.Degree
+	claimres["grad_year"] = graduate.GradYear
+	claimres["message"] = "alumni status verified successfully"
+	apiResponse(w, GetSuccessResponse(claimres, loginTTL), http.StatusOK)
+}
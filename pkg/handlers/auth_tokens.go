@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+)
+
+// ErrTwoFactorEnrollment is returned by issueTokenPair for accounts that must
+// set up two factor authentication first, e.g. after being given a role that
+// requires it. Signing in again hands out an enrollment token.
+var ErrTwoFactorEnrollment = errors.New("two factor authentication must be set up, please sign in again")
+
+// signIn starts a new session for the device making the request and issues
+// its first token pair.
+func signIn(r *http.Request, db mysql.Database, user *model.User) (string, string, error) {
+	sessionID, err := utils.NewTokenID()
+	if err != nil {
+		return "", "", err
+	}
+	expiresAt := time.Now().Add(utils.RefreshTokenTTL)
+	if err := db.CreateSession(r.Context(), sessionID, user.Id, deviceName(r), utils.ClientIP(r), expiresAt); err != nil {
+		return "", "", err
+	}
+	return issueTokenPair(r.Context(), db, user, sessionID)
+}
+
+// enrollmentToken starts a session that may only set up two factor
+// authentication and issues its token. The session is revoked like any
+// other, e.g. by a password reset, and becomes a full session once a code is
+// confirmed.
+func enrollmentToken(r *http.Request, db mysql.Database, user *model.User) (string, error) {
+	sessionID, err := utils.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := time.Now().Add(mfaLoginExpiry)
+	if err := db.CreateSession(r.Context(), sessionID, user.Id, deviceName(r), utils.ClientIP(r), expiresAt); err != nil {
+		return "", err
+	}
+	return utils.GenerateEnrollmentToken(user, sessionID, mfaLoginExpiry, utils.JWTISSUER, utils.JWTKeys)
+}
+
+// issueTokenPair signs a short lived access token and stores a new refresh
+// token for the session, keeping the session alive as long as the refresh
+// token. It returns ErrTwoFactorEnrollment if the user must enroll in two
+// factor authentication and hasn't.
+func issueTokenPair(ctx context.Context, db mysql.Database, user *model.User, sessionID string) (string, string, error) {
+	// permissions are read fresh on every issue, so role changes apply from
+	// the next refresh
+	role, err := db.GetRole(ctx, user.Role)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", "", err
+	}
+	if role != nil {
+		user.Permissions = role.Permissions
+	}
+	if user.MFARequired || (role != nil && role.Require2FA) {
+		twoFactor, err := getTwoFactor(ctx, db, user.Id)
+		if err != nil {
+			return "", "", err
+		}
+		if twoFactor == nil || twoFactor.EnabledAt == nil {
+			return "", "", ErrTwoFactorEnrollment
+		}
+	}
+	accessToken, err := utils.GenerateToken(user, sessionID, utils.AccessTokenTTL, utils.JWTISSUER, utils.JWTKeys)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := utils.NewRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+	expiresAt := time.Now().Add(utils.RefreshTokenTTL)
+	if _, err = db.CreateRefreshToken(ctx, user.Id, utils.HashToken(refreshToken), sessionID, expiresAt); err != nil {
+		return "", "", err
+	}
+	if err = db.ExtendSession(ctx, sessionID, expiresAt); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// deviceName identifies the client a session was started from.
+func deviceName(r *http.Request) string {
+	device := r.FormValue("device")
+	if device == "" {
+		device = r.UserAgent()
+	}
+	if len(device) > 255 {
+		device = device[:255]
+	}
+	return device
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// otherUserID reads the user to block or mute from the user_id form value.
+// It returns a status and message to send when the id is unusable.
+func otherUserID(ctx context.Context, db mysql.Database, r *http.Request, self int) (int, int, string) {
+	userID, err := strconv.Atoi(r.FormValue("user_id"))
+	if err != nil || userID <= 0 {
+		return 0, http.StatusBadRequest, "user id is required"
+	}
+	if userID == self {
+		return 0, http.StatusBadRequest, "you cannot do this to yourself"
+	}
+	user, err := db.GetUserByID(ctx, userID)
+	if err != nil || user.DeletedAt != nil {
+		return 0, http.StatusNotFound, "user not found"
+	}
+	return userID, 0, ""
+}
+
+var _ http.Handler = &blockUserHandler{}
+
+type blockUserHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewBlockUserHandler(logger *zap.Logger, mysqlclient mysql.Database) *blockUserHandler {
+	return &blockUserHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP blocks user_id. Neither user can message the other, send
+// connection requests or add the other to groups, and their connection, if
+// any, is removed. Blocked users' comments are hidden like muted ones.
+func (handler *blockUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	blockres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		blockres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, status, msg := otherUserID(r.Context(), handler.mysqlclient, r, userInfo.Id)
+	if status != 0 {
+		blockres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), status)
+		return
+	}
+	blocked, err := handler.mysqlclient.BlockUser(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		blockres["err"] = "unable to block user, please try again"
+		handler.logger.Error("err blocking user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !blocked {
+		blockres["err"] = "you have already blocked this user"
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusConflict)
+		return
+	}
+	handler.logger.Info("user blocked", zap.Int("blocker_id", userInfo.Id), zap.Int("blocked_id", userID))
+	blockres["message"] = "user blocked"
+	apiResponse(w, GetSuccessResponse(blockres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &unblockUserHandler{}
+
+type unblockUserHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUnblockUserHandler(logger *zap.Logger, mysqlclient mysql.Database) *unblockUserHandler {
+	return &unblockUserHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP lifts the block on /users/blocks/{id}. A removed connection is
+// not restored.
+func (handler *unblockUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	blockres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		blockres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	unblocked, err := handler.mysqlclient.UnblockUser(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		blockres["err"] = "unable to unblock user, please try again"
+		handler.logger.Error("err unblocking user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !unblocked {
+		blockres["err"] = "you have not blocked this user"
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	blockres["message"] = "user unblocked"
+	apiResponse(w, GetSuccessResponse(blockres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &blockedUsersHandler{}
+
+type blockedUsersHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewBlockedUsersHandler(logger *zap.Logger, mysqlclient mysql.Database) *blockedUsersHandler {
+	return &blockedUsersHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+func (handler *blockedUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	blockres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		blockres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	blocked, err := handler.mysqlclient.GetBlockedUsers(r.Context(), userInfo.Id)
+	if err != nil {
+		blockres["err"] = "unable to fetch blocked users, please try again"
+		handler.logger.Error("err fetching blocked users", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(blockres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	blockres["blocked"] = blocked
+	apiResponse(w, GetSuccessResponse(blockres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &muteUserHandler{}
+
+type muteUserHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewMuteUserHandler(logger *zap.Logger, mysqlclient mysql.Database) *muteUserHandler {
+	return &muteUserHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP mutes user_id, hiding their forum comments from the signed in
+// user. Unlike a block, the muted user can still reach them and is not told.
+func (handler *muteUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	muteres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		muteres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, status, msg := otherUserID(r.Context(), handler.mysqlclient, r, userInfo.Id)
+	if status != 0 {
+		muteres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), status)
+		return
+	}
+	muted, err := handler.mysqlclient.MuteUser(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		muteres["err"] = "unable to mute user, please try again"
+		handler.logger.Error("err muting user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !muted {
+		muteres["err"] = "you have already muted this user"
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusConflict)
+		return
+	}
+	muteres["message"] = "user muted"
+	apiResponse(w, GetSuccessResponse(muteres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &unmuteUserHandler{}
+
+type unmuteUserHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUnmuteUserHandler(logger *zap.Logger, mysqlclient mysql.Database) *unmuteUserHandler {
+	return &unmuteUserHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP unmutes /users/mutes/{id}.
+func (handler *unmuteUserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	muteres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		muteres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	unmuted, err := handler.mysqlclient.UnmuteUser(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		muteres["err"] = "unable to unmute user, please try again"
+		handler.logger.Error("err unmuting user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !unmuted {
+		muteres["err"] = "you have not muted this user"
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	muteres["message"] = "user unmuted"
+	apiResponse(w, GetSuccessResponse(muteres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &mutedUsersHandler{}
+
+type mutedUsersHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewMutedUsersHandler(logger *zap.Logger, mysqlclient mysql.Database) *mutedUsersHandler {
+	return &mutedUsersHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+func (handler *mutedUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	muteres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		muteres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	muted, err := handler.mysqlclient.GetMutedUsers(r.Context(), userInfo.Id)
+	if err != nil {
+		muteres["err"] = "unable to fetch muted users, please try again"
+		handler.logger.Error("err fetching muted users", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(muteres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	muteres["muted"] = muted
+	apiResponse(w, GetSuccessResponse(muteres, 30), http.StatusOK)
+}
@@ -0,0 +1,526 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	maxCareerEntries   = 50 // experiences, and separately educations, per user
+	maxDescription     = 2000
+	earliestCareerYear = 1950
+	expectedGradYears  = 7 // how far ahead a current student's end_year can be
+)
+
+var monthPattern = regexp.MustCompile(`^[0-9]{4}-(0[1-9]|1[0-2])$`)
+
+// getCareer loads the work history, education and skills shown on a user's
+// profile, marking the skills viewerID endorsed.
+func getCareer(ctx context.Context, db mysql.Database, userID int, viewerID int) (*model.Career, error) {
+	experiences, err := db.GetUserExperiences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	educations, err := db.GetUserEducations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	skills, err := db.GetUserSkills(ctx, userID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Career{Experiences: experiences, Educations: educations, Skills: skills}, nil
+}
+
+// checkMonth validates a YYYY-MM month that is not in the future.
+func checkMonth(name string, value string) error {
+	if !monthPattern.MatchString(value) || value < fmt.Sprintf("%d-01", earliestCareerYear) || value > time.Now().Format("2006-01") {
+		return fmt.Errorf("%s must be a month written YYYY-MM, not in the future", name)
+	}
+	return nil
+}
+
+// checkYear validates a YYYY year up to maxYear.
+func checkYear(name string, value string, maxYear int) error {
+	year, err := strconv.Atoi(value)
+	if !yearPattern.MatchString(value) || err != nil || year < earliestCareerYear || year > maxYear {
+		return fmt.Errorf("%s must be a year between %d and %d", name, earliestCareerYear, maxYear)
+	}
+	return nil
+}
+
+// checkText validates free text of at most max characters, required or not.
+func checkText(name string, value string, max int, required bool) error {
+	if required && value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	if len(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", name, max)
+	}
+	return nil
+}
+
+// experienceField validates one experience field and stores the value on
+// the entry, like profileField.
+type experienceField struct {
+	name  string
+	apply func(experience *model.Experience, value string) error
+}
+
+var experienceFields = []experienceField{
+	{"company", func(experience *model.Experience, value string) error {
+		experience.Company = value
+		return checkText("company", value, 255, true)
+	}},
+	{"title", func(experience *model.Experience, value string) error {
+		experience.Title = value
+		return checkText("title", value, 255, true)
+	}},
+	{"location", func(experience *model.Experience, value string) error {
+		experience.Location = value
+		return checkText("location", value, 255, false)
+	}},
+	{"start", func(experience *model.Experience, value string) error {
+		experience.Start = value
+		return checkMonth("start", value)
+	}},
+	{"end", func(experience *model.Experience, value string) error {
+		experience.End = value
+		if value == "" {
+			return nil
+		}
+		return checkMonth("end", value)
+	}},
+	{"description", func(experience *model.Experience, value string) error {
+		experience.Description = value
+		return checkText("description", value, maxDescription, false)
+	}},
+}
+
+// applyExperienceFields sets the fields in the request body on experience.
+// With partial only the fields present are applied, otherwise missing
+// fields count as empty so required ones are reported.
+func applyExperienceFields(r *http.Request, experience *model.Experience, partial bool) (int, map[string]string) {
+	changed, fieldErrors := 0, map[string]string{}
+	for _, field := range experienceFields {
+		values, ok := r.PostForm[field.name]
+		if !ok && partial {
+			continue
+		}
+		value := ""
+		if ok {
+			value = strings.TrimSpace(values[0])
+		}
+		changed++
+		if err := field.apply(experience, value); err != nil {
+			fieldErrors[field.name] = err.Error()
+		}
+	}
+	if len(fieldErrors) == 0 && experience.End != "" && experience.End < experience.Start {
+		fieldErrors["end"] = "end must not be before start"
+	}
+	return changed, fieldErrors
+}
+
+type educationField struct {
+	name  string
+	apply func(education *model.Education, value string) error
+}
+
+var educationFields = []educationField{
+	{"institution", func(education *model.Education, value string) error {
+		education.Institution = value
+		return checkText("institution", value, 255, true)
+	}},
+	{"degree", func(education *model.Education, value string) error {
+		education.Degree = value
+		return checkText("degree", value, 255, false)
+	}},
+	{"field_of_study", func(education *model.Education, value string) error {
+		education.FieldOfStudy = value
+		return checkText("field of study", value, 255, false)
+	}},
+	{"start_year", func(education *model.Education, value string) error {
+		education.StartYear = value
+		if value == "" {
+			return nil
+		}
+		return checkYear("start year", value, time.Now().Year())
+	}},
+	{"end_year", func(education *model.Education, value string) error {
+		education.EndYear = value
+		if value == "" {
+			return nil
+		}
+		return checkYear("end year", value, time.Now().Year()+expectedGradYears)
+	}},
+}
+
+// applyEducationFields is applyExperienceFields for educations.
+func applyEducationFields(r *http.Request, education *model.Education, partial bool) (int, map[string]string) {
+	changed, fieldErrors := 0, map[string]string{}
+	for _, field := range educationFields {
+		values, ok := r.PostForm[field.name]
+		if !ok && partial {
+			continue
+		}
+		value := ""
+		if ok {
+			value = strings.TrimSpace(values[0])
+		}
+		changed++
+		if err := field.apply(education, value); err != nil {
+			fieldErrors[field.name] = err.Error()
+		}
+	}
+	if len(fieldErrors) == 0 && education.StartYear != "" && education.EndYear != "" && education.EndYear < education.StartYear {
+		fieldErrors["end_year"] = "end year must not be before start year"
+	}
+	return changed, fieldErrors
+}
+
+var _ http.Handler = &createExperienceHandler{}
+
+type createExperienceHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewCreateExperienceHandler(logger *zap.Logger, mysqlclient mysql.Database) *createExperienceHandler {
+	return &createExperienceHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP adds a job to the user's work history. Leave end out for the
+// current job.
+func (handler *createExperienceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		careerres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	experience := &model.Experience{UserID: userInfo.Id}
+	if _, fieldErrors := applyExperienceFields(r, experience, false); len(fieldErrors) > 0 {
+		careerres["err"] = "some fields are invalid"
+		careerres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(careerres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	existing, err := handler.mysqlclient.GetUserExperiences(r.Context(), userInfo.Id)
+	if err != nil {
+		careerres["err"] = "unable to add experience, please try again"
+		handler.logger.Error("err fetching experiences", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxCareerEntries {
+		careerres["err"] = "you can have at most " + strconv.Itoa(maxCareerEntries) + " experiences"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusConflict)
+		return
+	}
+	if experience.Id, err = handler.mysqlclient.CreateExperience(r.Context(), experience); err != nil {
+		careerres["err"] = "unable to add experience, please try again"
+		handler.logger.Error("err creating experience", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	careerres["experience"] = experience
+	careerres["message"] = "experience added"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusCreated)
+}
+
+var _ http.Handler = &updateExperienceHandler{}
+
+type updateExperienceHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUpdateExperienceHandler(logger *zap.Logger, mysqlclient mysql.Database) *updateExperienceHandler {
+	return &updateExperienceHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP changes the fields present in the request of
+// /users/profile/experiences/{id}. An empty end marks the current job.
+func (handler *updateExperienceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		careerres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	experienceID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	experience, err := handler.mysqlclient.GetExperience(r.Context(), userInfo.Id, experienceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		careerres["err"] = "experience not found"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		careerres["err"] = "unable to update experience, please try again"
+		handler.logger.Error("err fetching experience", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	changed, fieldErrors := applyExperienceFields(r, experience, true)
+	if changed == 0 {
+		careerres["err"] = "no experience fields provided"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		careerres["err"] = "some fields are invalid"
+		careerres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(careerres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if err := handler.mysqlclient.UpdateExperience(r.Context(), experience); err != nil {
+		careerres["err"] = "unable to update experience, please try again"
+		handler.logger.Error("err updating experience", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	careerres["experience"] = experience
+	careerres["message"] = "experience updated"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &deleteExperienceHandler{}
+
+type deleteExperienceHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewDeleteExperienceHandler(logger *zap.Logger, mysqlclient mysql.Database) *deleteExperienceHandler {
+	return &deleteExperienceHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP removes /users/profile/experiences/{id} from the work history.
+func (handler *deleteExperienceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	experienceID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	deleted, err := handler.mysqlclient.DeleteExperience(r.Context(), userInfo.Id, experienceID)
+	if err != nil {
+		careerres["err"] = "unable to delete experience, please try again"
+		handler.logger.Error("err deleting experience", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		careerres["err"] = "experience not found"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	careerres["message"] = "experience deleted"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &createEducationHandler{}
+
+type createEducationHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewCreateEducationHandler(logger *zap.Logger, mysqlclient mysql.Database) *createEducationHandler {
+	return &createEducationHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP adds an education, at LASU or elsewhere, to the user's profile.
+func (handler *createEducationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		careerres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	education := &model.Education{UserID: userInfo.Id}
+	if _, fieldErrors := applyEducationFields(r, education, false); len(fieldErrors) > 0 {
+		careerres["err"] = "some fields are invalid"
+		careerres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(careerres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	existing, err := handler.mysqlclient.GetUserEducations(r.Context(), userInfo.Id)
+	if err != nil {
+		careerres["err"] = "unable to add education, please try again"
+		handler.logger.Error("err fetching educations", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxCareerEntries {
+		careerres["err"] = "you can have at most " + strconv.Itoa(maxCareerEntries) + " educations"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusConflict)
+		return
+	}
+	if education.Id, err = handler.mysqlclient.CreateEducation(r.Context(), education); err != nil {
+		careerres["err"] = "unable to add education, please try again"
+		handler.logger.Error("err creating education", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	careerres["education"] = education
+	careerres["message"] = "education added"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusCreated)
+}
+
+var _ http.Handler = &updateEducationHandler{}
+
+type updateEducationHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUpdateEducationHandler(logger *zap.Logger, mysqlclient mysql.Database) *updateEducationHandler {
+	return &updateEducationHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP changes the fields present in the request of
+// /users/profile/educations/{id}.
+func (handler *updateEducationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		careerres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	educationID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	education, err := handler.mysqlclient.GetEducation(r.Context(), userInfo.Id, educationID)
+	if errors.Is(err, sql.ErrNoRows) {
+		careerres["err"] = "education not found"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		careerres["err"] = "unable to update education, please try again"
+		handler.logger.Error("err fetching education", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	changed, fieldErrors := applyEducationFields(r, education, true)
+	if changed == 0 {
+		careerres["err"] = "no education fields provided"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		careerres["err"] = "some fields are invalid"
+		careerres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(careerres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if err := handler.mysqlclient.UpdateEducation(r.Context(), education); err != nil {
+		careerres["err"] = "unable to update education, please try again"
+		handler.logger.Error("err updating education", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	careerres["education"] = education
+	careerres["message"] = "education updated"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &deleteEducationHandler{}
+
+type deleteEducationHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewDeleteEducationHandler(logger *zap.Logger, mysqlclient mysql.Database) *deleteEducationHandler {
+	return &deleteEducationHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP removes /users/profile/educations/{id} from the profile.
+func (handler *deleteEducationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	careerres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		careerres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	educationID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	deleted, err := handler.mysqlclient.DeleteEducation(r.Context(), userInfo.Id, educationID)
+	if err != nil {
+		careerres["err"] = "unable to delete education, please try again"
+		handler.logger.Error("err deleting education", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		careerres["err"] = "education not found"
+		apiResponse(w, GetErrorResponseBytes(careerres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	careerres["message"] = "education deleted"
+	apiResponse(w, GetSuccessResponse(careerres, profileTTL), http.StatusOK)
+}
@@ -32,13 +32,13 @@ func (cs *ichatStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		msg_resp  = map[string]interface{}{}
 		log       log.Logger
 	)
-	current_user, err := utils.AuthenticateUser(r.Context(), cs.logger, cs.DB)
+	current_user, err := utils.AuthenticateVerifiedUser(r.Context(), cs.logger, cs.DB)
 	if err != nil {
-		msg_resp["error"] = "please sign in to access this page"
+		msg_resp["error"] = err.Error()
 		msg_resp["usr"] = "failed to get user"
 		msg_resp["db_error"] = "authentication failed: unable to get user data"
 		cs.logger.Error("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(msg_resp, 30, fmt.Errorf("'%s'", "please try authenticating again")), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(msg_resp, 30, fmt.Errorf("'%s'", "please try authenticating again")), authErrorStatus(err))
 		return
 	}
 	if recipient == "" || message == "" {
@@ -84,4 +84,7 @@ func (cs *ichatStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		apiResponse(w, GetSuccessResponse(chatresp, 30), http.StatusOK)
 		return
 	}
+	// nothing was stored, one of the users has blocked the other
+	msg_resp["err"] = "you cannot message this user"
+	apiResponse(w, GetErrorResponseBytes(msg_resp["err"], 30, nil), http.StatusForbidden)
 }
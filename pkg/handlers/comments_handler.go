@@ -1 +1,229 @@
 package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// repliesShown is how many replies of each comment a post shows before
+// "load more replies".
+const repliesShown = 3
+
+const (
+	defaultReplyLimit = 20
+	maxReplyLimit     = 50
+)
+
+// commentTree nests the replies in comments under their parents. comments
+// holds the first repliesShown replies of each comment, with ReplyCount
+// counting all of them, see mysql.Database.GetCommentsByForumID. Replies to
+// comments that were left out, e.g. by a muted user, are left out with them.
+func commentTree(comments []model.Comment) []model.Comment {
+	roots := []model.Comment{}
+	replies := map[int][]model.Comment{}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			roots = append(roots, comment)
+		} else {
+			replies[*comment.ParentID] = append(replies[*comment.ParentID], comment)
+		}
+	}
+	var nest func(comment model.Comment) model.Comment
+	nest = func(comment model.Comment) model.Comment {
+		children := replies[comment.ID]
+		if len(children) > repliesShown {
+			children = children[:repliesShown]
+		}
+		if len(children) > 0 && comment.ReplyCount > len(children) {
+			last := children[len(children)-1]
+			comment.MoreReplies = true
+			comment.RepliesCursor = encodeCursor(model.CommentCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		}
+		for _, child := range children {
+			comment.Replies = append(comment.Replies, nest(child))
+		}
+		return comment
+	}
+	for i := range roots {
+		roots[i] = nest(roots[i])
+	}
+	return roots
+}
+
+func decodeCommentCursor(value string) (*model.CommentCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	cursor := &model.CommentCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil || cursor.ID <= 0 {
+		return nil, false
+	}
+	return cursor, true
+}
+
+var _ http.Handler = &replyHandler{}
+
+type replyHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	mailer      mailer.Mailer
+	appURL      string
+}
+
+func NewReplyHandler(logger *zap.Logger, mysqlclient mysql.Database, mailer mailer.Mailer, appURL string) *replyHandler {
+	return &replyHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		mailer:      mailer,
+		appURL:      appURL,
+	}
+}
+
+// ServeHTTP replies to /forums/comments/{id} and emails its author, unless
+// they wrote the reply or muted the one who did.
+func (handler *replyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	replyres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		replyres["err"] = err.Error()
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+	comment := r.FormValue("comment")
+	if comment == "" {
+		replyres["err"] = "comment cannot be empty"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	parentID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	parent, err := handler.mysqlclient.GetCommentParent(r.Context(), parentID)
+	if err != nil {
+		replyres["err"] = "comment not found"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	if parent.Depth >= model.MaxCommentDepth {
+		replyres["err"] = "replies cannot nest any deeper, reply to an earlier comment instead"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	if blocked, err := handler.mysqlclient.IsBlocked(r.Context(), userInfo.Id, parent.UserID); err != nil || blocked {
+		replyres["err"] = "you cannot reply to this comment"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusForbidden)
+		return
+	}
+	added, err := handler.mysqlclient.AddReply(r.Context(), userInfo.Id, parent.ID, comment)
+	if err != nil {
+		replyres["err"] = "failed to reply"
+		handler.logger.Error("err adding reply", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		replyres["err"] = "comment not found"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	handler.notify(r, userInfo, parent)
+	replyres["message"] = "reply added successfully"
+	apiResponse(w, GetSuccessResponse(replyres, 30), http.StatusCreated)
+}
+
+// notify emails the author of parent about the reply by replier. Failures
+// are only logged, the reply is saved either way.
+func (handler *replyHandler) notify(r *http.Request, replier *model.User, parent *model.CommentParent) {
+	if parent.UserID == 0 || parent.UserID == replier.Id {
+		return
+	}
+	if muted, err := handler.mysqlclient.IsMuted(r.Context(), parent.UserID, replier.Id); err != nil || muted {
+		return
+	}
+	author, err := handler.mysqlclient.GetUserByID(r.Context(), parent.UserID)
+	if err != nil || author.DeletedAt != nil {
+		return
+	}
+	msg := mailer.Message{
+		To:      author.Email,
+		Subject: replier.Username + " replied to your comment",
+		Body: fmt.Sprintf("Hello %s,\n\n%s replied to your comment. Read the conversation at %s/forums/post/%s\n",
+			author.Username, replier.Username, handler.appURL, parent.ForumSlug),
+	}
+	if err := handler.mailer.Send(r.Context(), msg); err != nil {
+		handler.logger.Error("err sending reply notification", zap.Int("user_id", author.Id), zap.Error(err))
+	}
+}
+
+var _ http.Handler = &commentRepliesHandler{}
+
+type commentRepliesHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewCommentRepliesHandler(logger *zap.Logger, mysqlclient mysql.Database) *commentRepliesHandler {
+	return &commentRepliesHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP loads more replies to /forums/comments/{id}, oldest first,
+// continuing at cursor. Their own replies are counted but not included.
+func (handler *commentRepliesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	replyres := map[string]interface{}{}
+	query := r.URL.Query()
+	limit := defaultReplyLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxReplyLimit {
+			replyres["err"] = "limit must be between 1 and " + strconv.Itoa(maxReplyLimit)
+			apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusBadRequest)
+			return
+		}
+	}
+	var after *model.CommentCursor
+	if value := query.Get("cursor"); value != "" {
+		var ok bool
+		if after, ok = decodeCommentCursor(value); !ok {
+			replyres["err"] = "invalid cursor"
+			apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusBadRequest)
+			return
+		}
+	}
+	parentID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if _, err := handler.mysqlclient.GetCommentParent(r.Context(), parentID); err != nil {
+		replyres["err"] = "comment not found"
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	// signed in readers don't see replies by users they muted or blocked
+	viewerID, _ := r.Context().Value(utils.UserIDKey).(int)
+	replies, err := handler.mysqlclient.GetCommentReplies(r.Context(), parentID, viewerID, after, limit+1)
+	if err != nil {
+		replyres["err"] = "unable to fetch replies, please try again"
+		handler.logger.Error("err fetching comment replies", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(replyres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	replyres["next_cursor"] = nil
+	if len(replies) > limit {
+		replies = replies[:limit]
+		last := replies[limit-1]
+		replyres["next_cursor"] = encodeCursor(model.CommentCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	replyres["replies"] = replies
+	replyres["count"] = len(replies)
+	apiResponse(w, GetSuccessResponse(replyres, 30), http.StatusOK)
+}
@@ -1,32 +1,211 @@
 package handlers
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrConnectionSelf       = errors.New("you cannot connect with yourself")
+	ErrConnectionExists     = errors.New("you are already connected with this user")
+	ErrConnectionPending    = errors.New("a connection request is already pending between you and this user")
+	ErrConnectionNotFound   = errors.New("connection request not found")
+	ErrConnectionNotAllowed = errors.New("you are not allowed to act on this connection request")
+	ErrConnectionNotPending = errors.New("connection request is no longer pending")
+	ErrConnectionBlocked    = errors.New("you cannot connect with this user")
+)
 
 type Connection interface {
-	InitiateConnectionRequest(ctx context.Context, requester string, accepter string) (bool, error)
-	AcceptConnectionRequest(ctx context.Context, accepter string) (bool, error)
-	RejectConnectionRequest(ctx context.Context, accepter string) (bool, error)
-	ConnectionDetails(ctx context.Context)
+	InitiateConnectionRequest(ctx context.Context, requester int, accepter int) (int, error)
+	AcceptConnectionRequest(ctx context.Context, requestID int, accepter int) (bool, error)
+	RejectConnectionRequest(ctx context.Context, requestID int, accepter int) (bool, error)
+	WithdrawConnectionRequest(ctx context.Context, requestID int, requester int) (bool, error)
+	ConnectionRequests(ctx context.Context, userID int, incoming bool) ([]model.ConnectionRequest, error)
+	ConnectionDetails(ctx context.Context, userID int) ([]model.Connection, error)
 }
 
 var _ Connection = &connectionStruct{}
 
 type connectionStruct struct {
-	Requester string `json:"requester"`
-	Accepter  string `json:"accepter"`
-	Connected bool   `json:"connected"`
+	db mysql.Database
+}
+
+func NewConnection(db mysql.Database) *connectionStruct {
+	return &connectionStruct{
+		db: db,
+	}
+}
+
+func (connstruct *connectionStruct) InitiateConnectionRequest(ctx context.Context, requester int, accepter int) (int, error) {
+	if requester == accepter {
+		return 0, ErrConnectionSelf
+	}
+	blocked, err := connstruct.db.IsBlocked(ctx, requester, accepter)
+	if err != nil {
+		return 0, err
+	}
+	if blocked {
+		return 0, ErrConnectionBlocked
+	}
+	requestID, existing, err := connstruct.db.CreateConnectionRequest(ctx, requester, accepter)
+	if err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		if existing.Status == model.ConnectionAccepted {
+			return 0, ErrConnectionExists
+		}
+		return 0, ErrConnectionPending
+	}
+	return requestID, nil
+}
+
+func (connstruct *connectionStruct) AcceptConnectionRequest(ctx context.Context, requestID int, accepter int) (bool, error) {
+	return connstruct.respond(ctx, requestID, accepter, model.ConnectionAccepted)
+}
+
+func (connstruct *connectionStruct) RejectConnectionRequest(ctx context.Context, requestID int, accepter int) (bool, error) {
+	return connstruct.respond(ctx, requestID, accepter, model.ConnectionRejected)
+}
+
+func (connstruct *connectionStruct) WithdrawConnectionRequest(ctx context.Context, requestID int, requester int) (bool, error) {
+	request, err := connstruct.pendingRequest(ctx, requestID)
+	if err != nil {
+		return false, err
+	}
+	if request.RequesterID != requester {
+		return false, ErrConnectionNotAllowed
+	}
+	return connstruct.transition(ctx, requestID, model.ConnectionWithdrawn)
+}
+
+func (connstruct *connectionStruct) ConnectionRequests(ctx context.Context, userID int, incoming bool) ([]model.ConnectionRequest, error) {
+	if incoming {
+		return connstruct.db.GetIncomingConnectionRequests(ctx, userID)
+	}
+	return connstruct.db.GetOutgoingConnectionRequests(ctx, userID)
 }
 
-func (connstruct *connectionStruct) InitiateConnectionRequest(ctx context.Context, requester string, accepter string) (bool, error) {
-	return false, nil
+func (connstruct *connectionStruct) ConnectionDetails(ctx context.Context, userID int) ([]model.Connection, error) {
+	return connstruct.db.GetUserConnections(ctx, userID)
 }
 
-func (connstruct *connectionStruct) AcceptConnectionRequest(ctx context.Context, accepter string) (bool, error) {
-	return false, nil
+// respond lets the recipient of a pending request accept or reject it.
+func (connstruct *connectionStruct) respond(ctx context.Context, requestID int, accepter int, status string) (bool, error) {
+	request, err := connstruct.pendingRequest(ctx, requestID)
+	if err != nil {
+		return false, err
+	}
+	if request.RecipientID != accepter {
+		return false, ErrConnectionNotAllowed
+	}
+	return connstruct.transition(ctx, requestID, status)
 }
 
-func (connstruct *connectionStruct) RejectConnectionRequest(ctx context.Context, accepter string) (bool, error) {
-	return false, nil
+func (connstruct *connectionStruct) pendingRequest(ctx context.Context, requestID int) (*model.ConnectionRequest, error) {
+	request, err := connstruct.db.GetConnectionRequest(ctx, requestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrConnectionNotFound
+		}
+		return nil, err
+	}
+	if request.Status != model.ConnectionPending {
+		return nil, ErrConnectionNotPending
+	}
+	return request, nil
 }
 
-func (connstruct *connectionStruct) ConnectionDetails(ctx context.Context) {}
+func (connstruct *connectionStruct) transition(ctx context.Context, requestID int, status string) (bool, error) {
+	updated, err := connstruct.db.UpdateConnectionRequestStatus(ctx, requestID, model.ConnectionPending, status)
+	if err != nil {
+		return false, err
+	}
+	if !updated {
+		// another request changed the status between the read and the update
+		return false, ErrConnectionNotPending
+	}
+	return true, nil
+}
+
+// connectionErrorResponse maps connection lifecycle errors to an http status
+// code and the error that is safe to show to the user.
+func connectionErrorResponse(err error) (int, error) {
+	switch {
+	case errors.Is(err, ErrConnectionSelf):
+		return http.StatusBadRequest, err
+	case errors.Is(err, ErrConnectionExists), errors.Is(err, ErrConnectionPending), errors.Is(err, ErrConnectionNotPending):
+		return http.StatusConflict, err
+	case errors.Is(err, ErrConnectionNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, ErrConnectionNotAllowed), errors.Is(err, ErrConnectionBlocked):
+		return http.StatusForbidden, err
+	default:
+		return http.StatusInternalServerError, nil
+	}
+}
+
+var _ http.Handler = &connectHandler{}
+
+type connectHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewConnectHandler(logger *zap.Logger, db mysql.Database) *connectHandler {
+	return &connectHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+func (ch *connectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), ch.logger, ch.db)
+	if err != nil {
+		conn_resp["err"] = err.Error()
+		ch.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(conn_resp["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+
+	recipient := r.FormValue("recv_email")
+	if recipient == "" {
+		conn_resp["err"] = "recipient email is required"
+		ch.logger.Error("recipient email is missing")
+		apiResponse(w, GetErrorResponseBytes(conn_resp, 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	recipientUser, err := ch.db.GetUserByEmail(r.Context(), recipient)
+	if err != nil {
+		conn_resp["err"] = "recipient not found"
+		ch.logger.Error("failed to fetch recipient", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(conn_resp, 30, nil), http.StatusNotFound)
+		return
+	}
+
+	requestID, err := ch.conn.InitiateConnectionRequest(r.Context(), userInfo.Id, recipientUser.Id)
+	if err != nil {
+		conn_resp["err"] = "unable to send connection request"
+		ch.logger.Error("err initiating connection request", zap.Error(err))
+		status, publicErr := connectionErrorResponse(err)
+		apiResponse(w, GetErrorResponseBytes(conn_resp, 30, publicErr), status)
+		return
+	}
+
+	conn_resp["request_id"] = requestID
+	conn_resp["recipient"] = recipientUser.Username
+	conn_resp["status"] = model.ConnectionPending
+	conn_resp["message"] = "connection request sent"
+	apiResponse(w, GetSuccessResponse(conn_resp, 30), http.StatusCreated)
+}
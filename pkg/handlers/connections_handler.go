@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &connectionsHandler{}
+
+type connectionsHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewConnectionsHandler(logger *zap.Logger, db mysql.Database) *connectionsHandler {
+	return &connectionsHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+// ServeHTTP lists the accepted connections of the signed in user.
+func (ch *connectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conns_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), ch.logger, ch.db)
+	if err != nil {
+		conns_resp["err"] = "please sign in to access this page"
+		ch.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(conns_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	connections, err := ch.conn.ConnectionDetails(r.Context(), userInfo.Id)
+	if err != nil {
+		conns_resp["err"] = "unable to fetch connections"
+		ch.logger.Error("err fetching connections", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(conns_resp, 30, nil), http.StatusInternalServerError)
+		return
+	}
+
+	conns_resp["connections"] = connections
+	conns_resp["count"] = len(connections)
+	apiResponse(w, GetSuccessResponse(conns_resp, 30), http.StatusOK)
+}
+
+var _ http.Handler = &connectionRequestsHandler{}
+
+type connectionRequestsHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewConnectionRequestsHandler(logger *zap.Logger, db mysql.Database) *connectionRequestsHandler {
+	return &connectionRequestsHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+// ServeHTTP lists pending requests sent to (direction=incoming, the default)
+// or by (direction=outgoing) the signed in user.
+func (crh *connectionRequestsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requests_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), crh.logger, crh.db)
+	if err != nil {
+		requests_resp["err"] = "please sign in to access this page"
+		crh.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(requests_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	direction := r.URL.Query().Get("direction")
+	if direction == "" {
+		direction = "incoming"
+	}
+	if direction != "incoming" && direction != "outgoing" {
+		requests_resp["err"] = "direction must be incoming or outgoing"
+		crh.logger.Error("invalid connection request direction", zap.String("direction", direction))
+		apiResponse(w, GetErrorResponseBytes(requests_resp, 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	requests, err := crh.conn.ConnectionRequests(r.Context(), userInfo.Id, direction == "incoming")
+	if err != nil {
+		requests_resp["err"] = "unable to fetch connection requests"
+		crh.logger.Error("err fetching connection requests", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(requests_resp, 30, nil), http.StatusInternalServerError)
+		return
+	}
+
+	requests_resp["direction"] = direction
+	requests_resp["requests"] = requests
+	apiResponse(w, GetSuccessResponse(requests_resp, 30), http.StatusOK)
+}
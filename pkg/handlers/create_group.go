@@ -22,11 +22,11 @@ func NewCreateGroupHandler(logger *zap.Logger, db mysql.Database) *createGroupHa
 
 func (cgh *createGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	cg_resp := map[string]interface{}{}
-	userInfo, err := utils.AuthenticateUser(r.Context(), cgh.logger, cgh.db)
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), cgh.logger, cgh.db)
 	if err != nil {
-		cg_resp["err"] = "please sign in to access this page"
+		cg_resp["err"] = err.Error()
 		cgh.logger.Debug("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(cg_resp["err"], 30, nil), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(cg_resp["err"], 30, nil), authErrorStatus(err))
 		return
 	}
 
@@ -1 +1,59 @@
 package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &declineHandler{}
+
+type declineHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewDeclineHandler(logger *zap.Logger, db mysql.Database) *declineHandler {
+	return &declineHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+func (dh *declineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	decline_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), dh.logger, dh.db)
+	if err != nil {
+		decline_resp["err"] = "please sign in to access this page"
+		dh.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(decline_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.Atoi(r.FormValue("request_id"))
+	if err != nil {
+		decline_resp["err"] = "invalid connection request id"
+		dh.logger.Error("err parsing connection request id", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(decline_resp, 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	if _, err = dh.conn.RejectConnectionRequest(r.Context(), requestID, userInfo.Id); err != nil {
+		decline_resp["err"] = "unable to decline connection request"
+		dh.logger.Error("err declining connection request", zap.Int("request_id", requestID), zap.Error(err))
+		status, publicErr := connectionErrorResponse(err)
+		apiResponse(w, GetErrorResponseBytes(decline_resp, 30, publicErr), status)
+		return
+	}
+
+	decline_resp["request_id"] = requestID
+	decline_resp["status"] = model.ConnectionRejected
+	decline_resp["message"] = "connection request declined"
+	apiResponse(w, GetSuccessResponse(decline_resp, 30), http.StatusOK)
+}
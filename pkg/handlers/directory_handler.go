@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchTerm      = 100
+	maxSearchSkills    = 5
+)
+
+// searches allowed per user, enough to browse but slow to scrape the directory
+const (
+	searchLimit  = 60
+	searchWindow = time.Minute
+)
+
+var yearPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// encodeCursor makes the cursor handed to clients for the next page of a
+// list. It is opaque to them, but not secret.
+func encodeCursor(cursor interface{}) string {
+	b, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(value string, sort string) (*model.DirectoryCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	cursor := &model.DirectoryCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil || cursor.Sort != sort || cursor.Id <= 0 {
+		return nil, false
+	}
+	return cursor, true
+}
+
+var _ http.Handler = &searchUsersHandler{}
+
+type searchUsersHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	limiter     *ratelimit.SlidingWindow
+}
+
+func NewSearchUsersHandler(logger *zap.Logger, mysqlclient mysql.Database) *searchUsersHandler {
+	return &searchUsersHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		limiter:     ratelimit.NewSlidingWindow(searchLimit, searchWindow),
+	}
+}
+
+// ServeHTTP searches the alumni directory. Filters are name (username
+// prefix), degree, grad_year_from, grad_year_to, job (keyword in the
+// current job), company (any job in the work history), institution (any
+// education) and skill, repeated for users with every one of them; sort is
+// name, grad_year or recent. Pass next_cursor from a
+// response as cursor, with the same filters and sort, for the next page.
+func (handler *searchUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	searchres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		searchres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(searchres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	if ok, wait := handler.limiter.Allow(strconv.Itoa(userInfo.Id)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		searchres["err"] = "too many searches, please try again later"
+		apiResponse(w, GetErrorResponseBytes(searchres["err"], 30, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	query := r.URL.Query()
+	search := model.DirectorySearch{
+		NamePrefix:   strings.TrimSpace(query.Get("name")),
+		Degree:       strings.TrimSpace(query.Get("degree")),
+		GradYearFrom: strings.TrimSpace(query.Get("grad_year_from")),
+		GradYearTo:   strings.TrimSpace(query.Get("grad_year_to")),
+		JobKeyword:   strings.TrimSpace(query.Get("job")),
+		Company:      strings.TrimSpace(query.Get("company")),
+		Institution:  strings.TrimSpace(query.Get("institution")),
+		Sort:         query.Get("sort"),
+		Limit:        defaultSearchLimit,
+		ViewerID:     userInfo.Id,
+	}
+	badRequest := func(msg string) {
+		searchres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(searchres["err"], 30, nil), http.StatusBadRequest)
+	}
+	for _, term := range []string{search.NamePrefix, search.Degree, search.JobKeyword, search.Company, search.Institution} {
+		if len(term) > maxSearchTerm {
+			badRequest("search terms must be at most " + strconv.Itoa(maxSearchTerm) + " characters")
+			return
+		}
+	}
+	for _, year := range []string{search.GradYearFrom, search.GradYearTo} {
+		if year != "" && !yearPattern.MatchString(year) {
+			badRequest("graduation years must be four digit years")
+			return
+		}
+	}
+	if search.GradYearFrom != "" && search.GradYearTo != "" && search.GradYearFrom > search.GradYearTo {
+		badRequest("grad_year_from must not be after grad_year_to")
+		return
+	}
+	if len(query["skill"]) > maxSearchSkills {
+		badRequest("search at most " + strconv.Itoa(maxSearchSkills) + " skills at once")
+		return
+	}
+	for _, name := range query["skill"] {
+		_, key, ok := model.NormalizeSkill(name)
+		if !ok {
+			badRequest("invalid skill " + strconv.Quote(name))
+			return
+		}
+		search.Skills = append(search.Skills, key)
+	}
+	switch search.Sort {
+	case "":
+		search.Sort = model.DirectorySortName
+	case model.DirectorySortName, model.DirectorySortGradYear, model.DirectorySortRecent:
+	default:
+		badRequest("sort must be name, grad_year or recent")
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if search.Limit, err = strconv.Atoi(limit); err != nil || search.Limit < 1 || search.Limit > maxSearchLimit {
+			badRequest("limit must be between 1 and " + strconv.Itoa(maxSearchLimit))
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		var ok bool
+		if search.After, ok = decodeCursor(cursor, search.Sort); !ok {
+			badRequest("invalid cursor")
+			return
+		}
+	}
+
+	// one extra row tells whether there is another page
+	pageSize := search.Limit
+	search.Limit++
+	entries, err := handler.mysqlclient.SearchUsers(r.Context(), search)
+	if err != nil {
+		searchres["err"] = "unable to search the directory, please try again"
+		handler.logger.Error("err searching users", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(searchres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	searchres["next_cursor"] = nil
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		last := entries[pageSize-1]
+		next := model.DirectoryCursor{Sort: search.Sort, Id: last.Id}
+		switch search.Sort {
+		case model.DirectorySortName:
+			next.Key = last.Username
+		case model.DirectorySortGradYear:
+			next.Key = last.GradYear
+		}
+		searchres["next_cursor"] = encodeCursor(next)
+	}
+	searchres["users"] = entries
+	searchres["count"] = len(entries)
+	apiResponse(w, GetSuccessResponse(searchres, 30), http.StatusOK)
+}
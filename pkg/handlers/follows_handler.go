@@ -0,0 +1,419 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFollowLimit = 20
+	maxFollowLimit     = 100
+)
+
+func decodeFollowCursor(value string) (*model.FollowCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	cursor := &model.FollowCursor{}
+	if err := json.Unmarshal(b, cursor); err != nil || cursor.UserID <= 0 {
+		return nil, false
+	}
+	return cursor, true
+}
+
+// followPage reads the cursor and limit of a follow list request. msg is set
+// when they are invalid.
+func followPage(r *http.Request) (after *model.FollowCursor, limit int, msg string) {
+	query := r.URL.Query()
+	limit = defaultFollowLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxFollowLimit {
+			return nil, 0, "limit must be between 1 and " + strconv.Itoa(maxFollowLimit)
+		}
+	}
+	if value := query.Get("cursor"); value != "" {
+		var ok bool
+		if after, ok = decodeFollowCursor(value); !ok {
+			return nil, 0, "invalid cursor"
+		}
+	}
+	return after, limit, ""
+}
+
+// writeFollowPage responds with a page of a follow list fetched with one
+// extra entry, which tells whether there is a next page.
+func writeFollowPage(w http.ResponseWriter, followres map[string]interface{}, entries []model.FollowEntry, limit int) {
+	followres["next_cursor"] = nil
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		followres["next_cursor"] = encodeCursor(model.FollowCursor{Since: last.Since, UserID: last.UserID})
+	}
+	followres["users"] = entries
+	followres["count"] = len(entries)
+	apiResponse(w, GetSuccessResponse(followres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &followHandler{}
+
+type followHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewFollowHandler(logger *zap.Logger, mysqlclient mysql.Database) *followHandler {
+	return &followHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP follows user_id. Users who approve their followers get a
+// request instead, see the status in the response.
+func (handler *followHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = err.Error()
+		handler.logger.Debug("unauthorized user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+	userID, status, msg := otherUserID(r.Context(), handler.mysqlclient, r, userInfo.Id)
+	if status != 0 {
+		followres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), status)
+		return
+	}
+	blocked, err := handler.mysqlclient.IsBlocked(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		followres["err"] = "unable to follow user, please try again"
+		handler.logger.Error("err checking block", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		followres["err"] = "you cannot follow this user"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusForbidden)
+		return
+	}
+	followStatus, created, err := handler.mysqlclient.Follow(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		followres["err"] = "unable to follow user, please try again"
+		handler.logger.Error("err following user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		followres["err"] = "you already follow this user"
+		if followStatus == model.FollowPending {
+			followres["err"] = "your follow request is waiting for approval"
+		}
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusConflict)
+		return
+	}
+	followres["status"] = followStatus
+	followres["message"] = "user followed"
+	if followStatus == model.FollowPending {
+		followres["message"] = "follow request sent"
+	}
+	apiResponse(w, GetSuccessResponse(followres, 30), http.StatusCreated)
+}
+
+var _ http.Handler = &unfollowHandler{}
+
+type unfollowHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUnfollowHandler(logger *zap.Logger, mysqlclient mysql.Database) *unfollowHandler {
+	return &unfollowHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP unfollows /users/follows/{id}, or withdraws the request to.
+func (handler *unfollowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	removed, err := handler.mysqlclient.RemoveFollow(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		followres["err"] = "unable to unfollow user, please try again"
+		handler.logger.Error("err unfollowing user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		followres["err"] = "you do not follow this user"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	followres["message"] = "user unfollowed"
+	apiResponse(w, GetSuccessResponse(followres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &followRequestsHandler{}
+
+type followRequestsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewFollowRequestsHandler(logger *zap.Logger, mysqlclient mysql.Database) *followRequestsHandler {
+	return &followRequestsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP lists the follow requests waiting for the signed in user's
+// approval, latest first, a page at a time like the follower lists.
+func (handler *followRequestsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	after, limit, msg := followPage(r)
+	if msg != "" {
+		followres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	entries, err := handler.mysqlclient.GetFollowers(r.Context(), userInfo.Id, model.FollowPending, after, limit+1)
+	if err != nil {
+		followres["err"] = "unable to fetch follow requests, please try again"
+		handler.logger.Error("err fetching follow requests", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	writeFollowPage(w, followres, entries, limit)
+}
+
+var _ http.Handler = &approveFollowerHandler{}
+
+type approveFollowerHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewApproveFollowerHandler(logger *zap.Logger, mysqlclient mysql.Database) *approveFollowerHandler {
+	return &approveFollowerHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP accepts the follow request of /users/followers/{id}.
+func (handler *approveFollowerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	approved, err := handler.mysqlclient.ApproveFollow(r.Context(), userID, userInfo.Id)
+	if err != nil {
+		followres["err"] = "unable to approve follower, please try again"
+		handler.logger.Error("err approving follower", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !approved {
+		followres["err"] = "follow request not found"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	followres["message"] = "follower approved"
+	apiResponse(w, GetSuccessResponse(followres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &removeFollowerHandler{}
+
+type removeFollowerHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRemoveFollowerHandler(logger *zap.Logger, mysqlclient mysql.Database) *removeFollowerHandler {
+	return &removeFollowerHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP removes /users/followers/{id} from the signed in user's
+// followers, or declines their request.
+func (handler *removeFollowerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	removed, err := handler.mysqlclient.RemoveFollow(r.Context(), userID, userInfo.Id)
+	if err != nil {
+		followres["err"] = "unable to remove follower, please try again"
+		handler.logger.Error("err removing follower", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		followres["err"] = "this user does not follow you"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	followres["message"] = "follower removed"
+	apiResponse(w, GetSuccessResponse(followres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &followListHandler{}
+
+type followListHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	following   bool // list who the user follows rather than their followers
+}
+
+func NewFollowersHandler(logger *zap.Logger, mysqlclient mysql.Database) *followListHandler {
+	return &followListHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+func NewFollowingHandler(logger *zap.Logger, mysqlclient mysql.Database) *followListHandler {
+	return &followListHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		following:   true,
+	}
+}
+
+// ServeHTTP lists the followers of /users/{username}, or who they follow,
+// latest first. Pass next_cursor from a response as cursor for the next
+// page.
+func (handler *followListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	user, err := handler.mysqlclient.GetUserByUsername(r.Context(), mux.Vars(r)["username"])
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		followres["err"] = "unable to fetch follows, please try again"
+		handler.logger.Error("err fetching user by username", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if err != nil || user.DeletedAt != nil || (user.DeletionScheduled != nil && user.Id != userInfo.Id) {
+		followres["err"] = "user not found"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	blocked, err := handler.mysqlclient.IsBlocked(r.Context(), userInfo.Id, user.Id)
+	if err != nil {
+		followres["err"] = "unable to fetch follows, please try again"
+		handler.logger.Error("err checking block", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		followres["err"] = "user not found"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	after, limit, msg := followPage(r)
+	if msg != "" {
+		followres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	list := handler.mysqlclient.GetFollowers
+	if handler.following {
+		list = handler.mysqlclient.GetFollowing
+	}
+	entries, err := list(r.Context(), user.Id, model.FollowAccepted, after, limit+1)
+	if err != nil {
+		followres["err"] = "unable to fetch follows, please try again"
+		handler.logger.Error("err fetching follows", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	writeFollowPage(w, followres, entries, limit)
+}
+
+var _ http.Handler = &followSettingsHandler{}
+
+type followSettingsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewFollowSettingsHandler(logger *zap.Logger, mysqlclient mysql.Database) *followSettingsHandler {
+	return &followSettingsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP sets follow_approval, whether new followers need the user's
+// approval. Turning it off accepts the waiting requests.
+func (handler *followSettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	followres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		followres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(followres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	required, err := strconv.ParseBool(r.FormValue("follow_approval"))
+	if err != nil {
+		followres["err"] = "follow_approval must be true or false"
+		apiResponse(w, GetErrorResponseBytes(followres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if err := handler.mysqlclient.SetFollowApproval(r.Context(), userInfo.Id, required); err != nil {
+		followres["err"] = "unable to update follow settings, please try again"
+		handler.logger.Error("err updating follow settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(followres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	followres["follow_approval"] = required
+	followres["message"] = "follow settings updated"
+	apiResponse(w, GetSuccessResponse(followres, profileTTL), http.StatusOK)
+}
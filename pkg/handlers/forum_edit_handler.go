@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/slug"
+	"github.com/jim-nnamdi/jinx/pkg/textdiff"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// editableForumPost loads the post at /forums/post/{slug} if user wrote it
+// or may moderate forums. It returns a status and message to send otherwise.
+func editableForumPost(ctx context.Context, db mysql.Database, r *http.Request, user *model.User) (*model.Forum, int, string) {
+	forum, err := db.GetSingleForumPost(ctx, mux.Vars(r)["slug"])
+	if err != nil || forum.DeletedAt != nil {
+		return nil, http.StatusNotFound, "forum post not found"
+	}
+	if forum.Author != user.Email && !utils.HasPermission(ctx, model.PermissionModerateForums) {
+		return nil, http.StatusForbidden, "only the author or a moderator can change this post"
+	}
+	return forum, 0, ""
+}
+
+var _ http.Handler = &updateForumHandler{}
+
+type updateForumHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	slugs       *slug.Generator
+}
+
+func NewUpdateForumHandler(logger *zap.Logger, mysqlclient mysql.Database, slugs *slug.Generator) *updateForumHandler {
+	return &updateForumHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		slugs:       slugs,
+	}
+}
+
+// ServeHTTP edits the title and description of /forums/post/{slug}, keeping
+// the ones left out. A new title gets a new slug, the old one redirects.
+func (handler *updateForumHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	forumres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		forumres["err"] = err.Error()
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+	forum, status, msg := editableForumPost(r.Context(), handler.mysqlclient, r, userInfo)
+	if status != 0 {
+		forumres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), status)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		forumres["err"] = "unable to process request"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	title, description := forum.Title, forum.Description
+	if _, ok := r.Form["title"]; ok {
+		title = r.FormValue("title")
+	}
+	if _, ok := r.Form["description"]; ok {
+		description = r.FormValue("description")
+	}
+	if len(title) < 5 || len(title) > 255 {
+		forumres["err"] = "title must be between 5 and 255 characters"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	if len(description) < 50 || len(description) > 200 {
+		forumres["err"] = "description must be between 50 and 200 characters"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	if title == forum.Title && description == forum.Description {
+		forumres["err"] = "nothing to update"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	var (
+		newSlug = forum.Slug
+		updated bool
+	)
+	for attempt := 0; attempt < slugAttempts; attempt++ {
+		if title != forum.Title {
+			if newSlug, err = handler.slugs.ForumSlug(r.Context(), title, forum.Id); err != nil {
+				break
+			}
+		}
+		updated, err = handler.mysqlclient.UpdateForumPost(r.Context(), forum.Id, userInfo.Id, title, description, newSlug,
+			textdiff.Words(forum.Title, title), textdiff.Words(forum.Description, description))
+		if err != mysql.ErrSlugTaken {
+			break
+		}
+	}
+	if err != nil {
+		forumres["err"] = "unable to update forum post, please try again"
+		handler.logger.Error("err updating forum post", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		forumres["err"] = "forum post not found"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	handler.logger.Info("forum post updated", zap.Int("forum_id", forum.Id), zap.Int("editor_id", userInfo.Id))
+	forumres["id"] = forum.Id
+	forumres["title"] = title
+	forumres["description"] = description
+	forumres["slug"] = newSlug
+	forumres["message"] = "forum post updated"
+	apiResponse(w, GetSuccessResponse(forumres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &deleteForumHandler{}
+
+type deleteForumHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewDeleteForumHandler(logger *zap.Logger, mysqlclient mysql.Database) *deleteForumHandler {
+	return &deleteForumHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP deletes /forums/post/{slug}. The post is only hidden, so its
+// comments and revisions stay for moderators.
+func (handler *deleteForumHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	forumres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		forumres["err"] = err.Error()
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+	forum, status, msg := editableForumPost(r.Context(), handler.mysqlclient, r, userInfo)
+	if status != 0 {
+		forumres["err"] = msg
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), status)
+		return
+	}
+	deleted, err := handler.mysqlclient.DeleteForumPost(r.Context(), forum.Id, userInfo.Id)
+	if err != nil {
+		forumres["err"] = "unable to delete forum post, please try again"
+		handler.logger.Error("err deleting forum post", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		forumres["err"] = "forum post not found"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	handler.logger.Info("forum post deleted", zap.Int("forum_id", forum.Id), zap.Int("editor_id", userInfo.Id))
+	forumres["message"] = "forum post deleted"
+	apiResponse(w, GetSuccessResponse(forumres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &forumRevisionsHandler{}
+
+type forumRevisionsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewForumRevisionsHandler(logger *zap.Logger, mysqlclient mysql.Database) *forumRevisionsHandler {
+	return &forumRevisionsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP shows moderators /forums/post/{slug} as it is now, deleted or
+// not, with its revisions, latest first.
+func (handler *forumRevisionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	forumres := map[string]interface{}{}
+	forum, err := handler.mysqlclient.GetSingleForumPost(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		forumres["err"] = "forum post not found"
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	revisions, err := handler.mysqlclient.GetForumRevisions(r.Context(), forum.Id)
+	if err != nil {
+		forumres["err"] = "unable to fetch revisions, please try again"
+		handler.logger.Error("err fetching forum revisions", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(forumres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	forumres["forum"] = model.NewForumPost(forum)
+	forumres["revisions"] = revisions
+	apiResponse(w, GetSuccessResponse(forumres, 30), http.StatusOK)
+}
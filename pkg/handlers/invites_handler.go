@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	maxInviteUses = 1000
+	maxInviteDays = 365
+)
+
+// generateInviteCode returns a code in the form XXXX-XXXX-XXXX-XXXX, easy to
+// read out or type from a flyer.
+func generateInviteCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	return code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+var _ http.Handler = &invitesHandler{}
+
+type invitesHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+}
+
+func NewInvitesHandler(logger *zap.Logger, db mysql.Database) *invitesHandler {
+	return &invitesHandler{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// ServeHTTP lists the invite codes that can still be redeemed.
+func (ih *invitesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	invites_resp := map[string]interface{}{}
+	invites, err := ih.db.GetInviteCodes(r.Context())
+	if err != nil {
+		invites_resp["err"] = "unable to fetch invite codes"
+		ih.logger.Error("err fetching invite codes", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(invites_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	invites_resp["invites"] = invites
+	invites_resp["registration_policy"] = utils.RegistrationPolicy
+	apiResponse(w, GetSuccessResponse(invites_resp, 30), http.StatusOK)
+}
+
+var _ http.Handler = &createInviteHandler{}
+
+type createInviteHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+}
+
+func NewCreateInviteHandler(logger *zap.Logger, db mysql.Database) *createInviteHandler {
+	return &createInviteHandler{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// ServeHTTP creates an invite code. Like access tokens, the code is only
+// shown once and the database keeps its hash.
+func (cih *createInviteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	invite_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), cih.logger, cih.db)
+	if err != nil {
+		invite_resp["err"] = "please sign in to access this page"
+		cih.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	note := strings.TrimSpace(r.FormValue("note"))
+	if len(note) > 255 {
+		invite_resp["err"] = "note must be at most 255 characters"
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	maxUses := 1
+	if uses := r.FormValue("max_uses"); uses != "" {
+		if maxUses, err = strconv.Atoi(uses); err != nil || maxUses < 1 || maxUses > maxInviteUses {
+			invite_resp["err"] = "max_uses must be between 1 and " + strconv.Itoa(maxInviteUses)
+			apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusBadRequest)
+			return
+		}
+	}
+	var expiresAt *time.Time
+	if days := r.FormValue("expires_in_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 || n > maxInviteDays {
+			invite_resp["err"] = "expires_in_days must be between 1 and " + strconv.Itoa(maxInviteDays)
+			apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusBadRequest)
+			return
+		}
+		expiry := time.Now().AddDate(0, 0, n)
+		expiresAt = &expiry
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		invite_resp["err"] = "unable to create invite code, please try again"
+		cih.logger.Error("err generating invite code", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	normalized := normalizeInviteCode(code)
+	inviteID, err := cih.db.CreateInviteCode(r.Context(), utils.HashToken(normalized), normalized[:4], note, maxUses, expiresAt, userInfo.Id)
+	if err != nil {
+		invite_resp["err"] = "unable to create invite code, please try again"
+		cih.logger.Error("err storing invite code", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	cih.logger.Info("invite code created", zap.Int("invite_id", inviteID), zap.Int("created_by", userInfo.Id), zap.Int("max_uses", maxUses))
+
+	invite_resp["id"] = inviteID
+	invite_resp["code"] = code
+	invite_resp["max_uses"] = maxUses
+	invite_resp["expires_at"] = expiresAt
+	invite_resp["message"] = "copy the invite code now, it will not be shown again"
+	apiResponse(w, GetSuccessResponse(invite_resp, 30), http.StatusCreated)
+}
+
+var _ http.Handler = &revokeInviteHandler{}
+
+type revokeInviteHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+}
+
+func NewRevokeInviteHandler(logger *zap.Logger, db mysql.Database) *revokeInviteHandler {
+	return &revokeInviteHandler{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// ServeHTTP stops an invite code from being redeemed again.
+func (rih *revokeInviteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	invite_resp := map[string]interface{}{}
+	inviteID, err := strconv.Atoi(r.FormValue("invite_id"))
+	if err != nil || inviteID <= 0 {
+		invite_resp["err"] = "invite id not provided"
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	revoked, err := rih.db.RevokeInviteCode(r.Context(), inviteID)
+	if err != nil {
+		invite_resp["err"] = "unable to revoke invite code, please try again"
+		rih.logger.Error("err revoking invite code", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		invite_resp["err"] = "invite code not found"
+		apiResponse(w, GetErrorResponseBytes(invite_resp["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	invite_resp["message"] = "invite code revoked successfully"
+	apiResponse(w, GetSuccessResponse(invite_resp, 30), http.StatusOK)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const loginBackoffAfter = 3 // failures allowed before backoff kicks in
+
+var maxLoginBackoff = 5 * time.Minute
+
+// loginGuard slows down password and code guessing: each email backs off
+// exponentially after a few failures and is locked after
+// utils.MaxLoginFailures, and each ip is held to utils.LoginIPLimit attempts
+// per utils.LoginIPWindow.
+type loginGuard struct {
+	logger    *zap.Logger
+	db        mysql.Database
+	ipLimiter *ratelimit.SlidingWindow
+}
+
+func newLoginGuard(logger *zap.Logger, db mysql.Database, ipLimiter *ratelimit.SlidingWindow) *loginGuard {
+	return &loginGuard{
+		logger:    logger,
+		db:        db,
+		ipLimiter: ipLimiter,
+	}
+}
+
+// loginDelay is how long an email has to wait after failures in a row.
+func loginDelay(failures int) time.Duration {
+	if failures >= utils.MaxLoginFailures {
+		return utils.LoginLockout
+	}
+	if failures < loginBackoffAfter {
+		return 0
+	}
+	delay := time.Second << (failures - loginBackoffAfter)
+	if delay > maxLoginBackoff || delay <= 0 {
+		return maxLoginBackoff
+	}
+	return delay
+}
+
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// check counts an attempt from ip and returns how long the caller has to wait
+// before trying email again, zero if it may go ahead.
+func (g *loginGuard) check(ctx context.Context, email string, ip string) (time.Duration, error) {
+	if allowed, wait := g.ipLimiter.Allow(ip); !allowed {
+		g.logger.Warn("login rate limited", zap.String("ip", ip), zap.Duration("retry_after", wait))
+		return wait, nil
+	}
+	attempt, err := g.db.GetLoginAttempt(ctx, loginKey(email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if attempt.LockedUntil != nil && attempt.LockedUntil.After(time.Now()) {
+		g.logger.Info("login attempt while locked", zap.String("email", attempt.Email), zap.String("ip", ip), zap.Time("locked_until", *attempt.LockedUntil))
+		return time.Until(*attempt.LockedUntil), nil
+	}
+	return 0, nil
+}
+
+// fail records a failed attempt and locks the email for the backoff delay.
+func (g *loginGuard) fail(ctx context.Context, email string, ip string) {
+	attempt, err := g.db.RecordLoginFailure(ctx, loginKey(email))
+	if err != nil {
+		g.logger.Error("err recording login failure", zap.Error(err))
+		return
+	}
+	delay := loginDelay(attempt.Failures)
+	if delay == 0 {
+		return
+	}
+	lockedUntil := time.Now().Add(delay)
+	if err := g.db.LockLogin(ctx, attempt.Email, lockedUntil); err != nil {
+		g.logger.Error("err locking login", zap.Error(err))
+		return
+	}
+	if attempt.Failures >= utils.MaxLoginFailures {
+		g.logger.Warn("login lockout", zap.String("email", attempt.Email), zap.String("ip", ip), zap.Int("failures", attempt.Failures), zap.Time("locked_until", lockedUntil))
+		return
+	}
+	g.logger.Info("login backoff", zap.String("email", attempt.Email), zap.String("ip", ip), zap.Int("failures", attempt.Failures), zap.Duration("delay", delay))
+}
+
+// succeed clears the failures of email.
+func (g *loginGuard) succeed(ctx context.Context, email string) {
+	if err := g.db.ResetLoginAttempts(ctx, loginKey(email)); err != nil {
+		g.logger.Error("err resetting login attempts", zap.Error(err))
+	}
+}
+
+// tooManyAttempts writes the 429 response for a caller that has to wait.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	msg := fmt.Sprintf("too many failed sign in attempts, please try again in %s", (time.Duration(seconds) * time.Second).String())
+	apiResponse(w, GetErrorResponseBytes(msg, loginTTL, nil), http.StatusTooManyRequests)
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -15,12 +19,14 @@ var loginTTL = 30
 type loginHandler struct {
 	logger      *zap.Logger
 	mysqlclient mysql.Database
+	guard       *loginGuard
 }
 
-func NewLoginHandler(logger *zap.Logger, mysqlclient mysql.Database) *loginHandler {
+func NewLoginHandler(logger *zap.Logger, mysqlclient mysql.Database, ipLimiter *ratelimit.SlidingWindow) *loginHandler {
 	return &loginHandler{
 		logger:      logger,
 		mysqlclient: mysqlclient,
+		guard:       newLoginGuard(logger, mysqlclient, ipLimiter),
 	}
 }
 
@@ -37,8 +43,21 @@ func (handler *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusNotFound)
 		return
 	}
+	ip := utils.ClientIP(r)
+	wait, err := handler.guard.check(r.Context(), email, ip)
+	if err != nil {
+		loginres["err"] = "unable to authenticate user"
+		handler.logger.Error("err checking login attempts", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
 	checkuser, err := handler.mysqlclient.GetUserByEmail(r.Context(), email)
 	if err != nil {
+		handler.guard.fail(r.Context(), email, ip)
 		loginres["err"] = "user does not exist"
 		handler.logger.Error("user does not exist", zap.Any("checkuser", err))
 		apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusNotFound)
@@ -47,7 +66,13 @@ func (handler *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if checkuser != nil {
 		if checkuser.Id > 0 {
 			handler.logger.Debug("found user", zap.Bool("user found", true))
-			_ = CheckPasswordHash(password, checkuser.Password)
+			if !CheckPasswordHash(password, checkuser.Password) {
+				handler.guard.fail(r.Context(), email, ip)
+				loginres["err"] = "email or password incorrect"
+				handler.logger.Error("email or password incorrect", zap.Any("login response", "email or password incorrect"))
+				apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusUnauthorized)
+				return
+			}
 			loginnow, err := handler.mysqlclient.CheckUser(r.Context(), email, checkuser.Password)
 			if err != nil {
 				loginres["err"] = "email or password incorrect"
@@ -55,26 +80,82 @@ func (handler *loginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusUnauthorized)
 				return
 			}
+			if loginnow != nil && loginnow.EmailVerifiedAt == nil && utils.UnverifiedPolicy == utils.UnverifiedReject {
+				loginres["err"] = utils.ErrEmailNotVerified.Error()
+				handler.logger.Debug("unverified account tried to sign in", zap.Int("user_id", loginnow.Id))
+				apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusForbidden)
+				return
+			}
 			if loginnow != nil {
-				jwt, err := utils.GenerateToken(loginnow, 2*time.Hour, utils.JWTISSUER, utils.MYSTIC)
+				twoFactor, err := handler.mysqlclient.GetTwoFactor(r.Context(), loginnow.Id)
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					loginres["err"] = "unable to authenticate user"
+					handler.logger.Error("err fetching two factor settings", zap.Error(err))
+					apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusInternalServerError)
+					return
+				}
+				// with two factor enabled the password only earns a short lived
+				// mfa_token, exchanged for the jwt at /auth/2fa/verify
+				if twoFactor != nil && twoFactor.EnabledAt != nil {
+					loginres["mfa_required"] = true
+					loginres["mfa_token"] = utils.NewSignedToken(mfaLoginPurpose, strconv.Itoa(loginnow.Id), mfaLoginExpiry, utils.LinkSecret)
+					apiResponse(w, GetSuccessResponse(loginres, loginTTL), http.StatusOK)
+					return
+				}
+				required, err := twoFactorRequired(r.Context(), handler.mysqlclient, loginnow)
+				if err != nil {
+					loginres["err"] = "unable to authenticate user"
+					handler.logger.Error("err checking two factor requirement", zap.Error(err))
+					apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusInternalServerError)
+					return
+				}
+				if required {
+					jwt, err := enrollmentToken(r, handler.mysqlclient, loginnow)
+					if err != nil {
+						loginres["err"] = "unable to authenticate user"
+						handler.logger.Error("err generating enrollment token", zap.Error(err))
+						apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusInternalServerError)
+						return
+					}
+					loginres["mfa_enrollment_required"] = true
+					loginres["jwt_token"] = jwt
+					apiResponse(w, GetSuccessResponse(loginres, loginTTL), http.StatusOK)
+					return
+				}
+				jwt, refreshToken, err := signIn(r, handler.mysqlclient, loginnow)
 				if err != nil {
 					loginres["err"] = "unable to authenticate user"
 					handler.logger.Error("err generating auth token")
 					apiResponse(w, GetErrorResponseBytes(loginres["err"], loginTTL, nil), http.StatusInternalServerError)
 					return
 				}
-				loginres["username"] = loginnow.Username
-				loginres["email"] = loginnow.Email
-				loginres["phone"] = loginnow.Phone
-				loginres["degree"] = loginnow.Degree
-				loginres["grad_year"] = loginnow.GradYear
-				loginres["current_job"] = loginnow.CurrentJob
-				loginres["profile_picture"] = loginnow.ProfilePicture
-				loginres["linkedin_profile"] = loginnow.LinkedinProfile
-				loginres["twitter_profile"] = loginnow.TwitterProfile
-				loginres["jwt_token"] = jwt
+				// accounts with two factor are only cleared once the code is verified
+				handler.guard.succeed(r.Context(), email)
+				setLoginResponse(loginres, loginnow, jwt, refreshToken)
 				apiResponse(w, GetSuccessResponse(loginres, loginTTL), http.StatusOK)
 			}
 		}
 	}
 }
+
+// setLoginResponse fills in the profile and tokens returned once a user is
+// fully signed in.
+func setLoginResponse(loginres map[string]interface{}, user *model.User, jwt string, refreshToken string) {
+	loginres["username"] = user.Username
+	loginres["email"] = user.Email
+	loginres["phone"] = user.Phone
+	loginres["degree"] = user.Degree
+	loginres["grad_year"] = user.GradYear
+	loginres["current_job"] = user.CurrentJob
+	loginres["profile_picture"] = user.ProfilePicture
+	loginres["linkedin_profile"] = user.LinkedinProfile
+	loginres["twitter_profile"] = user.TwitterProfile
+	loginres["email_verified"] = user.EmailVerifiedAt != nil
+	loginres["role"] = user.Role
+	loginres["verified_alumnus"] = user.AlumniVerifiedAt != nil
+	if user.DeletionScheduled != nil {
+		loginres["deletion_scheduled_at"] = user.DeletionScheduled
+	}
+	loginres["jwt_token"] = jwt
+	loginres["refresh_token"] = refreshToken
+}
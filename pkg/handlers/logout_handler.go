@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &logoutHandler{}
+
+type logoutHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewLogoutHandler(logger *zap.Logger, mysqlclient mysql.Database) *logoutHandler {
+	return &logoutHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP ends the current session: the access token used for the request
+// is denylisted and the session is revoked. With all=true every session of the
+// user is revoked, signing out all devices.
+func (handler *logoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logoutres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		logoutres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(logoutres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	if jti, ok := r.Context().Value(utils.TokenIDKey).(string); ok && jti != "" {
+		expiresAt, ok := r.Context().Value(utils.TokenExpiryKey).(time.Time)
+		if !ok {
+			expiresAt = time.Now().Add(utils.AccessTokenTTL)
+		}
+		if err := handler.mysqlclient.RevokeAccessToken(r.Context(), jti, expiresAt); err != nil {
+			logoutres["err"] = "unable to sign out, please try again"
+			handler.logger.Error("err revoking access token", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(logoutres["err"], loginTTL, nil), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// signing out the session also stops its refresh tokens, signing out
+	// everywhere also revokes personal access tokens
+	if r.FormValue("all") == "true" {
+		err = handler.mysqlclient.RevokeUserSessions(r.Context(), userInfo.Id, "")
+		if err == nil {
+			err = handler.mysqlclient.RevokeUserAccessTokens(r.Context(), userInfo.Id)
+		}
+	} else if sessionID, ok := r.Context().Value(utils.SessionIDKey).(string); ok && sessionID != "" {
+		err = handler.mysqlclient.RevokeSession(r.Context(), sessionID)
+	}
+	if err != nil {
+		logoutres["err"] = "unable to sign out, please try again"
+		handler.logger.Error("err revoking sessions", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(logoutres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+
+	logoutres["message"] = "signed out successfully"
+	apiResponse(w, GetSuccessResponse(logoutres, loginTTL), http.StatusOK)
+}
@@ -1 +1,230 @@
 package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/avatar"
+	"github.com/jim-nnamdi/jinx/pkg/blobstore"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// uploads allowed per user, each one decodes and resizes a picture
+const (
+	uploadLimit  = 10
+	uploadWindow = time.Hour
+)
+
+// multipartOverhead is room for the multipart boundaries and headers on top
+// of the file itself.
+const multipartOverhead = 64 << 10
+
+var _ http.Handler = &uploadPictureHandler{}
+
+type uploadPictureHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	store       blobstore.BlobStore
+	limiter     *ratelimit.SlidingWindow
+}
+
+func NewUploadPictureHandler(logger *zap.Logger, mysqlclient mysql.Database, store blobstore.BlobStore) *uploadPictureHandler {
+	return &uploadPictureHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		store:       store,
+		limiter:     ratelimit.NewSlidingWindow(uploadLimit, uploadWindow),
+	}
+}
+
+// avatarURLs maps each stored size of an avatar to its url.
+func avatarURLs(store blobstore.BlobStore, userID int, version string) map[string]string {
+	urls := map[string]string{}
+	for _, size := range avatar.Sizes {
+		urls[strconv.Itoa(size)] = store.URL(avatar.Key(userID, version, size))
+	}
+	return urls
+}
+
+// deleteAvatar removes the stored sizes of the user's current profile
+// picture. Pictures that were not uploaded here are left alone.
+func deleteAvatar(ctx context.Context, logger *zap.Logger, store blobstore.BlobStore, user *model.User) {
+	version, ok := avatar.Version(user.ProfilePicture, store.URL(avatar.Dir(user.Id)))
+	if !ok {
+		return
+	}
+	for _, key := range avatar.Keys(user.Id, version) {
+		if err := store.Delete(ctx, key); err != nil {
+			logger.Error("err deleting avatar", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
+
+// ServeHTTP takes a multipart "picture" field with a JPEG, PNG or GIF, and
+// stores it at every avatar size. The type is sniffed from the content, the
+// file name and declared type are ignored.
+func (handler *uploadPictureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uploadres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		uploadres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if ok, wait := handler.limiter.Allow(strconv.Itoa(userInfo.Id)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		uploadres["err"] = "too many uploads, please try again later"
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	tooLarge := "picture must be at most " + strconv.FormatInt(utils.MaxUploadBytes>>20, 10) + "MB"
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxUploadBytes+multipartOverhead)
+	file, _, err := r.FormFile("picture")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			uploadres["err"] = tooLarge
+			apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusRequestEntityTooLarge)
+			return
+		}
+		uploadres["err"] = "picture not provided"
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxUploadBytes+1))
+	if err != nil {
+		uploadres["err"] = "unable to read picture, please try again"
+		handler.logger.Error("err reading upload", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if int64(len(data)) > utils.MaxUploadBytes {
+		uploadres["err"] = tooLarge
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sizes, err := avatar.Process(data)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupportedType):
+		uploadres["err"] = "picture must be a JPEG, PNG or GIF image"
+		handler.logger.Debug("unsupported upload", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, avatar.ErrTooLarge):
+		uploadres["err"] = "picture dimensions are too large"
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	case errors.Is(err, avatar.ErrTooSmall):
+		uploadres["err"] = "picture must be at least " + strconv.Itoa(avatar.Sizes[0]) + " pixels wide and high"
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	case err != nil:
+		uploadres["err"] = "unable to process picture, please try again"
+		handler.logger.Error("err processing upload", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+	stored := []string{}
+	removeStored := func() {
+		for _, key := range stored {
+			if err := handler.store.Delete(r.Context(), key); err != nil {
+				handler.logger.Error("err deleting avatar", zap.String("key", key), zap.Error(err))
+			}
+		}
+	}
+	for _, size := range avatar.Sizes {
+		key := avatar.Key(userInfo.Id, version, size)
+		if err := handler.store.Put(r.Context(), key, bytes.NewReader(sizes[size]), "image/jpeg"); err != nil {
+			removeStored()
+			uploadres["err"] = "unable to save picture, please try again"
+			handler.logger.Error("err storing avatar", zap.String("key", key), zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusInternalServerError)
+			return
+		}
+		stored = append(stored, key)
+	}
+	pictureURL := handler.store.URL(avatar.Key(userInfo.Id, version, avatar.DefaultSize))
+	if err := handler.mysqlclient.SetProfilePicture(r.Context(), userInfo.Id, pictureURL); err != nil {
+		removeStored()
+		uploadres["err"] = "unable to save picture, please try again"
+		handler.logger.Error("err setting profile picture", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(uploadres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	// the same picture uploaded twice keeps its keys, which must not be deleted
+	if previous, ok := avatar.Version(userInfo.ProfilePicture, handler.store.URL(avatar.Dir(userInfo.Id))); !ok || previous != version {
+		deleteAvatar(r.Context(), handler.logger, handler.store, userInfo)
+	}
+	handler.logger.Info("profile picture uploaded", zap.Int("user_id", userInfo.Id), zap.String("version", version))
+
+	uploadres["profile_picture"] = pictureURL
+	uploadres["sizes"] = avatarURLs(handler.store, userInfo.Id, version)
+	uploadres["message"] = "profile picture updated"
+	apiResponse(w, GetSuccessResponse(uploadres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &removePictureHandler{}
+
+type removePictureHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	store       blobstore.BlobStore
+}
+
+func NewRemovePictureHandler(logger *zap.Logger, mysqlclient mysql.Database, store blobstore.BlobStore) *removePictureHandler {
+	return &removePictureHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		store:       store,
+	}
+}
+
+// ServeHTTP clears the profile picture and deletes the stored sizes.
+func (handler *removePictureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	removeres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		removeres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(removeres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if userInfo.ProfilePicture == "" {
+		removeres["err"] = "you have no profile picture"
+		apiResponse(w, GetErrorResponseBytes(removeres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	if err := handler.mysqlclient.SetProfilePicture(r.Context(), userInfo.Id, ""); err != nil {
+		removeres["err"] = "unable to remove picture, please try again"
+		handler.logger.Error("err clearing profile picture", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(removeres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	deleteAvatar(r.Context(), handler.logger, handler.store, userInfo)
+	handler.logger.Info("profile picture removed", zap.Int("user_id", userInfo.Id))
+
+	removeres["message"] = "profile picture removed"
+	apiResponse(w, GetSuccessResponse(removeres, profileTTL), http.StatusOK)
+}
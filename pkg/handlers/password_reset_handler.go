@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var passwordResetExpiry = time.Hour
+
+// reset requests allowed per email and per ip, so inboxes can't be flooded
+// and the endpoint can't be used to probe many addresses
+const (
+	passwordResetEmailLimit = 3
+	passwordResetIPLimit    = 10
+	passwordResetWindow     = time.Hour
+
+	// how long the background work for one request may take
+	passwordResetSendTimeout = time.Minute
+)
+
+var _ http.Handler = &forgotPasswordHandler{}
+
+type forgotPasswordHandler struct {
+	logger       *zap.Logger
+	mysqlclient  mysql.Database
+	mailer       mailer.Mailer
+	appURL       string
+	emailLimiter *ratelimit.SlidingWindow
+	ipLimiter    *ratelimit.SlidingWindow
+}
+
+func NewForgotPasswordHandler(logger *zap.Logger, mysqlclient mysql.Database, mailer mailer.Mailer, appURL string) *forgotPasswordHandler {
+	return &forgotPasswordHandler{
+		logger:       logger,
+		mysqlclient:  mysqlclient,
+		mailer:       mailer,
+		appURL:       strings.TrimRight(appURL, "/"),
+		emailLimiter: ratelimit.NewSlidingWindow(passwordResetEmailLimit, passwordResetWindow),
+		ipLimiter:    ratelimit.NewSlidingWindow(passwordResetIPLimit, passwordResetWindow),
+	}
+}
+
+// ServeHTTP emails a reset link to the account owner. The response is the
+// same whether or not the email is registered so accounts can't be probed,
+// and the link is created and mailed in the background so the response time
+// doesn't tell either.
+func (handler *forgotPasswordHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	forgotres := map[string]interface{}{}
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email == "" {
+		forgotres["err"] = "email not provided"
+		handler.logger.Error("email not provided")
+		apiResponse(w, GetErrorResponseBytes(forgotres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+	ok, wait := handler.ipLimiter.Allow(utils.ClientIP(r))
+	if ok {
+		ok, wait = handler.emailLimiter.Allow(strings.ToLower(email))
+	}
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		forgotres["err"] = "too many password reset requests, please try again later"
+		apiResponse(w, GetErrorResponseBytes(forgotres["err"], loginTTL, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	forgotres["message"] = "if the email is registered, a password reset link has been sent to it"
+	user, err := handler.mysqlclient.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		handler.logger.Debug("password reset requested for unknown email", zap.Error(err))
+		apiResponse(w, GetSuccessResponse(forgotres, loginTTL), http.StatusOK)
+		return
+	}
+	// the request context ends with the response
+	go handler.sendReset(user)
+	apiResponse(w, GetSuccessResponse(forgotres, loginTTL), http.StatusOK)
+}
+
+// sendReset replaces the user's reset links with a new one and mails it.
+// Failures are only logged, the user can ask again.
+func (handler *forgotPasswordHandler) sendReset(user *model.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+	defer cancel()
+
+	token, err := utils.NewRandomToken()
+	if err != nil {
+		handler.logger.Error("err generating password reset token", zap.Error(err))
+		return
+	}
+	// only the newest link should work
+	if err := handler.mysqlclient.InvalidatePasswordResets(ctx, user.Id); err != nil {
+		handler.logger.Error("err invalidating old password resets", zap.Error(err))
+	}
+	if _, err := handler.mysqlclient.CreatePasswordReset(ctx, user.Id, utils.HashToken(token), time.Now().Add(passwordResetExpiry)); err != nil {
+		handler.logger.Error("err storing password reset", zap.Int("user_id", user.Id), zap.Error(err))
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your alumni network password",
+		Body: fmt.Sprintf("Hello %s,\n\nWe received a request to reset your password. Use the link below within %s to choose a new one:\n\n%s/reset-password?token=%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Username, passwordResetExpiry, handler.appURL, token),
+	}
+	if err := handler.mailer.Send(ctx, msg); err != nil {
+		handler.logger.Error("err sending password reset email", zap.Int("user_id", user.Id), zap.Error(err))
+	}
+}
+
+var _ http.Handler = &resetPasswordHandler{}
+
+type resetPasswordHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewResetPasswordHandler(logger *zap.Logger, mysqlclient mysql.Database) *resetPasswordHandler {
+	return &resetPasswordHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP sets a new password using a token from the reset email. The token
+// is consumed and every session of the user is signed out.
+func (handler *resetPasswordHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		token    = r.FormValue("token")
+		password = r.FormValue("password")
+		resetres = map[string]interface{}{}
+	)
+	if token == "" || password == "" {
+		resetres["err"] = "token or password not provided"
+		handler.logger.Error("token or password not provided")
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	reset, err := handler.mysqlclient.GetPasswordReset(r.Context(), utils.HashToken(token))
+	if err != nil || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		resetres["err"] = "reset link is invalid or has expired"
+		handler.logger.Error("invalid password reset token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	if err := validatePassword(password); err != nil {
+		resetres["err"] = err.Error()
+		handler.logger.Error(err.Error())
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	hashed_password, err := hashPassword(password)
+	if err != nil {
+		resetres["err"] = "unable to reset password, please try again"
+		handler.logger.Error("cannot hash password", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+
+	// the token is only consumed if the password is updated with it
+	used, err := handler.mysqlclient.UsePasswordReset(r.Context(), reset.Id, reset.UserID, hashed_password)
+	if err != nil {
+		resetres["err"] = "unable to reset password, please try again"
+		handler.logger.Error("err updating password", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !used {
+		resetres["err"] = "reset link is invalid or has expired"
+		handler.logger.Error("password reset token already consumed")
+		apiResponse(w, GetErrorResponseBytes(resetres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if err := handler.mysqlclient.RevokeUserSessions(r.Context(), reset.UserID, ""); err != nil {
+		handler.logger.Error("err revoking sessions after password reset", zap.Error(err))
+	}
+	if err := handler.mysqlclient.RevokeUserAccessTokens(r.Context(), reset.UserID); err != nil {
+		handler.logger.Error("err revoking access tokens after password reset", zap.Error(err))
+	}
+	// proving ownership of the mailbox lifts any lockout
+	if user, err := handler.mysqlclient.GetUserByID(r.Context(), reset.UserID); err == nil {
+		if err := handler.mysqlclient.ResetLoginAttempts(r.Context(), loginKey(user.Email)); err != nil {
+			handler.logger.Error("err clearing login attempts after password reset", zap.Error(err))
+		}
+	}
+
+	resetres["message"] = "password reset successfully, please sign in"
+	apiResponse(w, GetSuccessResponse(resetres, loginTTL), http.StatusOK)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -32,13 +33,26 @@ func (handler *profileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusUnauthorized)
 		return
 	}
-	profileres["id"] = userInfo.Id
-	profileres["username"] = userInfo.Username
-	profileres["email"] = userInfo.Email
-	profileres["phone"] = userInfo.Phone
-	profileres["degree"] = userInfo.Degree
-	profileres["current_job"] = userInfo.CurrentJob
-	profileres["linkedin_profile"] = userInfo.LinkedinProfile
-	profileres["twitter_profile"] = userInfo.TwitterProfile
-	apiResponse(w, GetSuccessResponse(profileres, profileTTL), http.StatusOK)
+	settings, err := handler.mysqlclient.GetPrivacySettings(r.Context(), userInfo.Id)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching privacy settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	career, err := getCareer(r.Context(), handler.mysqlclient, userInfo.Id, userInfo.Id)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching work history", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	follows, err := handler.mysqlclient.GetFollowStats(r.Context(), userInfo.Id, userInfo.Id)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching follow counts", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	apiResponse(w, GetSuccessResponse(model.NewOwnProfile(userInfo, settings, career, follows), profileTTL), http.StatusOK)
 }
@@ -0,0 +1,400 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const earliestGradYear = 1983 // the year the university was founded
+
+var (
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,30}$`)
+	phonePattern    = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`) // E.164
+	linkedinPattern = regexp.MustCompile(`^https?://([a-z]{2,3}\.)?linkedin\.com/in/[A-Za-z0-9_-]{3,100}/?$`)
+	twitterPattern  = regexp.MustCompile(`^https?://(www\.)?(twitter|x)\.com/[A-Za-z0-9_]{1,15}/?$`)
+)
+
+// reservedUsernames would clash with the routes next to /users/{username}.
+var reservedUsernames = map[string]bool{
+	"profile":         true,
+	"search":          true,
+	"me":              true,
+	"chat":            true,
+	"chat-history":    true,
+	"alumni":          true,
+	"recommendations": true,
+	"blocks":          true,
+	"mutes":           true,
+	"follows":         true,
+	"followers":       true,
+}
+
+func validateUsername(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username must be 3 to 30 letters, digits, dots, dashes or underscores")
+	}
+	// deleted accounts are renamed deleted-user-<id>
+	lower := strings.ToLower(username)
+	if reservedUsernames[lower] || strings.HasPrefix(lower, "deleted-user-") {
+		return fmt.Errorf("username %q is not available", username)
+	}
+	return nil
+}
+
+// usernameTaken reports whether an account other than userID uses username.
+// Usernames are compared without case, like the unique index does.
+func usernameTaken(ctx context.Context, db mysql.Database, username string, userID int) (bool, error) {
+	user, err := db.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Id != userID, nil
+}
+
+// profileField validates one editable profile field and stores the value
+// on the user. Empty values clear optional fields.
+type profileField struct {
+	name  string
+	apply func(user *model.User, value string) error
+}
+
+var profileFields = []profileField{
+	{"username", func(user *model.User, value string) error {
+		if err := validateUsername(value); err != nil {
+			return err
+		}
+		user.Username = value
+		return nil
+	}},
+	{"degree", func(user *model.User, value string) error {
+		if user.AlumniVerifiedAt != nil {
+			return fmt.Errorf("degree comes from the graduate registry for verified alumni")
+		}
+		if value == "" || len(value) > 255 {
+			return fmt.Errorf("degree must be between 1 and 255 characters")
+		}
+		user.Degree = value
+		return nil
+	}},
+	{"grad_year", func(user *model.User, value string) error {
+		if user.AlumniVerifiedAt != nil {
+			return fmt.Errorf("graduation year comes from the graduate registry for verified alumni")
+		}
+		year, err := strconv.Atoi(value)
+		if err != nil || len(value) != 4 || year < earliestGradYear || year > time.Now().Year() {
+			return fmt.Errorf("graduation year must be a year between %d and %d", earliestGradYear, time.Now().Year())
+		}
+		user.GradYear = value
+		return nil
+	}},
+	{"current_job", func(user *model.User, value string) error {
+		if len(value) > 255 {
+			return fmt.Errorf("current job must be at most 255 characters")
+		}
+		user.CurrentJob = value
+		return nil
+	}},
+	{"phone", func(user *model.User, value string) error {
+		value = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(value)
+		if value != "" && !phonePattern.MatchString(value) {
+			return fmt.Errorf("phone must be in international format, e.g. +2348012345678")
+		}
+		user.Phone = value
+		return nil
+	}},
+	{"linkedin_profile", func(user *model.User, value string) error {
+		if value != "" && !linkedinPattern.MatchString(value) {
+			return fmt.Errorf("linkedin profile must look like https://www.linkedin.com/in/your-name")
+		}
+		user.LinkedinProfile = value
+		return nil
+	}},
+	{"twitter_profile", func(user *model.User, value string) error {
+		if value != "" && !twitterPattern.MatchString(value) {
+			return fmt.Errorf("twitter profile must look like https://x.com/yourhandle")
+		}
+		user.TwitterProfile = value
+		return nil
+	}},
+}
+
+var _ http.Handler = &updateProfileHandler{}
+
+type updateProfileHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUpdateProfileHandler(logger *zap.Logger, mysqlclient mysql.Database) *updateProfileHandler {
+	return &updateProfileHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP updates the profile fields present in the request and leaves the
+// others alone. Nothing is saved if any field is invalid.
+func (handler *updateProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	profileres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		profileres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		profileres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		updated     = *userInfo
+		fieldErrors = map[string]string{}
+		changed     = 0
+	)
+	for _, field := range profileFields {
+		values, ok := r.PostForm[field.name]
+		if !ok {
+			continue
+		}
+		changed++
+		if err := field.apply(&updated, strings.TrimSpace(values[0])); err != nil {
+			fieldErrors[field.name] = err.Error()
+		}
+	}
+	if changed == 0 {
+		profileres["err"] = "no profile fields provided"
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if _, invalid := fieldErrors["username"]; !invalid && updated.Username != userInfo.Username {
+		taken, err := usernameTaken(r.Context(), handler.mysqlclient, updated.Username, userInfo.Id)
+		if err != nil {
+			profileres["err"] = "unable to update profile, please try again"
+			handler.logger.Error("err checking username", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusInternalServerError)
+			return
+		}
+		if taken {
+			fieldErrors["username"] = "username is already taken"
+		}
+	}
+	if len(fieldErrors) > 0 {
+		profileres["err"] = "some fields are invalid"
+		profileres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(profileres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := handler.mysqlclient.UpdateUserProfile(r.Context(), &updated); err != nil {
+		profileres["err"] = "unable to update profile, please try again"
+		handler.logger.Error("err updating profile", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	// the update went through, so lookup errors only leave parts of the response empty
+	settings, err := handler.mysqlclient.GetPrivacySettings(r.Context(), userInfo.Id)
+	if err != nil {
+		handler.logger.Error("err fetching privacy settings", zap.Error(err))
+	}
+	career, err := getCareer(r.Context(), handler.mysqlclient, userInfo.Id, userInfo.Id)
+	if err != nil {
+		handler.logger.Error("err fetching work history", zap.Error(err))
+		career = &model.Career{}
+	}
+	follows, err := handler.mysqlclient.GetFollowStats(r.Context(), userInfo.Id, userInfo.Id)
+	if err != nil {
+		handler.logger.Error("err fetching follow counts", zap.Error(err))
+		follows = &model.FollowStats{}
+	}
+	profileres["profile"] = model.NewOwnProfile(&updated, settings, career, follows)
+	profileres["message"] = "profile updated successfully"
+	apiResponse(w, GetSuccessResponse(profileres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &changeEmailHandler{}
+
+type changeEmailHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	mailer      mailer.Mailer
+	appURL      string
+}
+
+func NewChangeEmailHandler(logger *zap.Logger, mysqlclient mysql.Database, mailer mailer.Mailer, appURL string) *changeEmailHandler {
+	return &changeEmailHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		mailer:      mailer,
+		appURL:      appURL,
+	}
+}
+
+// ServeHTTP starts an email change. The account keeps its current email
+// until the link sent to the new one is opened, see verifyEmailHandler.
+func (handler *changeEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	emailres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		emailres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if !CheckPasswordHash(r.FormValue("password"), userInfo.Password) {
+		emailres["err"] = "password incorrect"
+		handler.logger.Debug("wrong password changing email", zap.Int("user_id", userInfo.Id))
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if valid, err := validateEmail(email); err != nil || !valid {
+		emailres["err"] = "email is malformed"
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if strings.EqualFold(email, userInfo.Email) {
+		emailres["err"] = "this is already your email"
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if err := checkEmailDomain(email); err != nil {
+		emailres["err"] = err.Error()
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusForbidden)
+		return
+	}
+	if _, err := handler.mysqlclient.GetUserByEmail(r.Context(), email); err == nil {
+		emailres["err"] = "this email is already used by another account"
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusConflict)
+		return
+	}
+	allowed, err := handler.mysqlclient.MarkVerificationSent(r.Context(), userInfo.Id, time.Now().Add(-verificationResendInterval))
+	if err != nil {
+		emailres["err"] = "unable to change email, please try again"
+		handler.logger.Error("err recording verification email", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		emailres["err"] = fmt.Sprintf("a verification email was sent recently, please wait %s before asking again", verificationResendInterval)
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	if err := handler.mysqlclient.SetPendingEmail(r.Context(), userInfo.Id, email); err != nil {
+		emailres["err"] = "unable to change email, please try again"
+		handler.logger.Error("err saving pending email", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(emailres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if err := sendVerificationEmail(r.Context(), handler.mailer, handler.appURL, userInfo, email); err != nil {
+		handler.logger.Error("err sending email change verification", zap.Int("user_id", userInfo.Id), zap.Error(err))
+	}
+	// the current address hears about the change in case the account was taken over
+	notice := mailer.Message{
+		To:      userInfo.Email,
+		Subject: "Your alumni network email is being changed",
+		Body: fmt.Sprintf("Hello %s,\n\nA change of your account email to %s was requested. It takes effect once the new address is verified.\n\nIf you did not request this, please reset your password and sign out of all devices.\n",
+			userInfo.Username, email),
+	}
+	if err := handler.mailer.Send(r.Context(), notice); err != nil {
+		handler.logger.Error("err sending email change notice", zap.Int("user_id", userInfo.Id), zap.Error(err))
+	}
+
+	emailres["pending_email"] = email
+	emailres["message"] = "please open the link sent to your new email to finish the change"
+	apiResponse(w, GetSuccessResponse(emailres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &changePasswordHandler{}
+
+type changePasswordHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewChangePasswordHandler(logger *zap.Logger, mysqlclient mysql.Database) *changePasswordHandler {
+	return &changePasswordHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP sets a new password after checking the current one, and signs
+// out every other device.
+func (handler *changePasswordHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	passwordres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		passwordres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	var (
+		currentPassword = r.FormValue("current_password")
+		newPassword     = r.FormValue("new_password")
+	)
+	if !CheckPasswordHash(currentPassword, userInfo.Password) {
+		passwordres["err"] = "current password incorrect"
+		handler.logger.Debug("wrong password changing password", zap.Int("user_id", userInfo.Id))
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := validatePassword(newPassword); err != nil {
+		passwordres["err"] = err.Error()
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if newPassword == currentPassword {
+		passwordres["err"] = "new password must be different from the current one"
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	hashed_password, err := hashPassword(newPassword)
+	if err != nil {
+		passwordres["err"] = "unable to change password, please try again"
+		handler.logger.Error("cannot hash password", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if _, err := handler.mysqlclient.UpdateUserPassword(r.Context(), userInfo.Id, hashed_password); err != nil {
+		passwordres["err"] = "unable to change password, please try again"
+		handler.logger.Error("err updating password", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(passwordres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	current, _ := r.Context().Value(utils.SessionIDKey).(string)
+	if err := handler.mysqlclient.RevokeUserSessions(r.Context(), userInfo.Id, current); err != nil {
+		handler.logger.Error("err revoking sessions after password change", zap.Error(err))
+	}
+	if err := handler.mysqlclient.RevokeUserAccessTokens(r.Context(), userInfo.Id); err != nil {
+		handler.logger.Error("err revoking access tokens after password change", zap.Error(err))
+	}
+	if err := handler.mysqlclient.InvalidatePasswordResets(r.Context(), userInfo.Id); err != nil {
+		handler.logger.Error("err invalidating password resets after password change", zap.Error(err))
+	}
+	handler.logger.Info("password changed", zap.Int("user_id", userInfo.Id))
+	passwordres["message"] = "password changed successfully, other devices have been signed out and access tokens revoked"
+	apiResponse(w, GetSuccessResponse(passwordres, profileTTL), http.StatusOK)
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// profile views allowed per viewer, signed out viewers are counted by ip
+const (
+	profileViewLimit  = 120
+	profileViewWindow = time.Minute
+)
+
+var _ http.Handler = &publicProfileHandler{}
+
+type publicProfileHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	limiter     *ratelimit.SlidingWindow
+}
+
+func NewPublicProfileHandler(logger *zap.Logger, mysqlclient mysql.Database) *publicProfileHandler {
+	return &publicProfileHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		limiter:     ratelimit.NewSlidingWindow(profileViewLimit, profileViewWindow),
+	}
+}
+
+// ServeHTTP shows the profile of /users/{username} with the fields the
+// owner's privacy settings allow the viewer to see. Anyone can view it,
+// signing in may reveal more, unless either user blocked the other.
+func (handler *publicProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	profileres := map[string]interface{}{}
+	viewerID, _ := r.Context().Value(utils.UserIDKey).(int)
+	limiterKey := "ip:" + utils.ClientIP(r)
+	if viewerID > 0 {
+		limiterKey = "user:" + strconv.Itoa(viewerID)
+	}
+	if ok, wait := handler.limiter.Allow(limiterKey); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		profileres["err"] = "too many requests, please try again later"
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := handler.mysqlclient.GetUserByUsername(r.Context(), mux.Vars(r)["username"])
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching user by username", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	// accounts on their way out are hidden from everyone but themselves
+	if err != nil || user.DeletedAt != nil || (user.DeletionScheduled != nil && user.Id != viewerID) {
+		profileres["err"] = "user not found"
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+
+	viewer := model.ViewerAnonymous
+	switch {
+	case viewerID == user.Id:
+		viewer = model.ViewerSelf
+	case viewerID > 0:
+		viewer = model.ViewerMember
+		blocked, err := handler.mysqlclient.IsBlocked(r.Context(), viewerID, user.Id)
+		if err != nil {
+			profileres["err"] = "unable to fetch profile, please try again"
+			handler.logger.Error("err checking block", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+			return
+		}
+		if blocked {
+			profileres["err"] = "user not found"
+			apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusNotFound)
+			return
+		}
+		connected, err := handler.mysqlclient.CheckConnection(r.Context(), viewerID, user.Id)
+		if err != nil {
+			profileres["err"] = "unable to fetch profile, please try again"
+			handler.logger.Error("err checking connection", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+			return
+		}
+		if connected {
+			viewer = model.ViewerConnection
+		}
+	}
+	settings, err := handler.mysqlclient.GetPrivacySettings(r.Context(), user.Id)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching privacy settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	career, err := getCareer(r.Context(), handler.mysqlclient, user.Id, viewerID)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching work history", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	follows, err := handler.mysqlclient.GetFollowStats(r.Context(), user.Id, viewerID)
+	if err != nil {
+		profileres["err"] = "unable to fetch profile, please try again"
+		handler.logger.Error("err fetching follow counts", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(profileres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	apiResponse(w, GetSuccessResponse(model.NewPublicProfile(user, settings, career, follows, viewer), 30), http.StatusOK)
+}
+
+var _ http.Handler = &updatePrivacyHandler{}
+
+type updatePrivacyHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewUpdatePrivacyHandler(logger *zap.Logger, mysqlclient mysql.Database) *updatePrivacyHandler {
+	return &updatePrivacyHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP sets who can see profile fields, e.g. phone=connections. Fields
+// left out of the request keep their setting.
+func (handler *updatePrivacyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	privacyres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		privacyres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(privacyres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		privacyres["err"] = "malformed request body"
+		apiResponse(w, GetErrorResponseBytes(privacyres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	changes := model.PrivacySettings{}
+	fieldErrors := map[string]string{}
+	for field := range model.PrivacyDefaults {
+		values, ok := r.PostForm[field]
+		if !ok {
+			continue
+		}
+		switch visibility := strings.ToLower(strings.TrimSpace(values[0])); visibility {
+		case model.VisibilityPublic, model.VisibilityConnections, model.VisibilityPrivate:
+			changes[field] = visibility
+		default:
+			fieldErrors[field] = "must be public, connections or private"
+		}
+	}
+	if len(fieldErrors) > 0 {
+		privacyres["err"] = "some fields are invalid"
+		privacyres["fields"] = fieldErrors
+		apiResponse(w, GetErrorResponseBytes(privacyres, profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	if len(changes) == 0 {
+		privacyres["err"] = "no privacy settings provided"
+		apiResponse(w, GetErrorResponseBytes(privacyres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	if err := handler.mysqlclient.SetPrivacySettings(r.Context(), userInfo.Id, changes); err != nil {
+		privacyres["err"] = "unable to update privacy settings, please try again"
+		handler.logger.Error("err saving privacy settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(privacyres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	settings, err := handler.mysqlclient.GetPrivacySettings(r.Context(), userInfo.Id)
+	if err != nil {
+		// the update went through, fall back to showing what was sent
+		handler.logger.Error("err fetching privacy settings", zap.Error(err))
+		settings = changes
+	}
+	privacyres["privacy"] = settings.All()
+	privacyres["message"] = "privacy settings updated"
+	apiResponse(w, GetSuccessResponse(privacyres, profileTTL), http.StatusOK)
+}
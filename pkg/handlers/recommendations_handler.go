@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/recommend"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const defaultRecommendations = 20
+
+var _ http.Handler = &recommendationsHandler{}
+
+type recommendationsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	recommender *recommend.Recommender
+}
+
+func NewRecommendationsHandler(logger *zap.Logger, mysqlclient mysql.Database, recommender *recommend.Recommender) *recommendationsHandler {
+	return &recommendationsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		recommender: recommender,
+	}
+}
+
+// ServeHTTP lists "people you may know" for the signed in user, best match
+// first, with what they have in common. limit defaults to 20.
+func (handler *recommendationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	recres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		recres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(recres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	limit := defaultRecommendations
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > recommend.MaxResults {
+			recres["err"] = "limit must be between 1 and " + strconv.Itoa(recommend.MaxResults)
+			apiResponse(w, GetErrorResponseBytes(recres["err"], 30, nil), http.StatusBadRequest)
+			return
+		}
+	}
+	recommendations, err := handler.recommender.Get(r.Context(), userInfo, limit)
+	if err != nil {
+		recres["err"] = "unable to fetch recommendations, please try again"
+		handler.logger.Error("err fetching recommendations", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(recres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	recres["users"] = recommendations
+	recres["count"] = len(recommendations)
+	apiResponse(w, GetSuccessResponse(recres, 30), http.StatusOK)
+}
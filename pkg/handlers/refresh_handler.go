@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &refreshHandler{}
+
+type refreshHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRefreshHandler(logger *zap.Logger, mysqlclient mysql.Database) *refreshHandler {
+	return &refreshHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP exchanges a refresh token for a new access and refresh token.
+// Every refresh token can be used once; presenting a used token again means
+// it was stolen, so its session is revoked and the device signed out.
+func (handler *refreshHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	refreshres := map[string]interface{}{}
+	refreshToken := r.FormValue("refresh_token")
+	if refreshToken == "" {
+		refreshres["err"] = "refresh token not provided"
+		handler.logger.Error("refresh token not provided")
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := handler.mysqlclient.GetRefreshToken(r.Context(), utils.HashToken(refreshToken))
+	if err != nil {
+		refreshres["err"] = "invalid refresh token, please sign in"
+		handler.logger.Error("unknown refresh token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	if stored.UsedAt != nil || stored.RevokedAt != nil {
+		handler.revokeSession(r, stored.SessionID, stored.UserID)
+		refreshres["err"] = "invalid refresh token, please sign in"
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		refreshres["err"] = "session expired, please sign in"
+		handler.logger.Debug("expired refresh token", zap.Int("user_id", stored.UserID))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	rotated, err := handler.mysqlclient.MarkRefreshTokenUsed(r.Context(), stored.Id)
+	if err != nil {
+		refreshres["err"] = "unable to refresh session"
+		handler.logger.Error("err rotating refresh token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !rotated {
+		// a concurrent request rotated the same token first
+		handler.revokeSession(r, stored.SessionID, stored.UserID)
+		refreshres["err"] = "invalid refresh token, please sign in"
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	user, err := handler.mysqlclient.GetUserByID(r.Context(), stored.UserID)
+	if err != nil {
+		refreshres["err"] = "unable to refresh session"
+		handler.logger.Error("err fetching refresh token owner", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	jwt, newRefreshToken, err := issueTokenPair(r.Context(), handler.mysqlclient, user, stored.SessionID)
+	if errors.Is(err, ErrTwoFactorEnrollment) {
+		if err := handler.mysqlclient.RevokeSession(r.Context(), stored.SessionID); err != nil {
+			handler.logger.Error("err revoking session", zap.Error(err))
+		}
+		refreshres["err"] = err.Error()
+		handler.logger.Debug("refresh refused until two factor is set up", zap.Int("user_id", user.Id))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		refreshres["err"] = "unable to refresh session"
+		handler.logger.Error("err issuing token pair", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(refreshres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	refreshres["jwt_token"] = jwt
+	refreshres["refresh_token"] = newRefreshToken
+	apiResponse(w, GetSuccessResponse(refreshres, loginTTL), http.StatusOK)
+}
+
+func (handler *refreshHandler) revokeSession(r *http.Request, sessionID string, userID int) {
+	handler.logger.Warn("refresh token reuse detected, revoking session", zap.String("session_id", sessionID), zap.Int("user_id", userID), zap.String("ip", utils.ClientIP(r)))
+	if err := handler.mysqlclient.RevokeSession(r.Context(), sessionID); err != nil {
+		handler.logger.Error("err revoking session", zap.Error(err))
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -18,12 +20,16 @@ var registerTTL = 60
 type registerHandler struct {
 	logger      *zap.Logger
 	mysqlclient mysql.Database
+	mailer      mailer.Mailer
+	appURL      string
 }
 
-func NewRegisterHandler(logger *zap.Logger, mysqlclient mysql.Database) *registerHandler {
+func NewRegisterHandler(logger *zap.Logger, mysqlclient mysql.Database, mailer mailer.Mailer, appURL string) *registerHandler {
 	return &registerHandler{
 		logger:      logger,
 		mysqlclient: mysqlclient,
+		mailer:      mailer,
+		appURL:      appURL,
 	}
 }
 
@@ -40,6 +46,18 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
+// validatePassword ensures the password is greater than 7 values
+// and also ensures that it has special characters
+func validatePassword(password string) error {
+	if !strings.ContainsAny(password, "$ % @ !") {
+		return fmt.Errorf("password must contain special characters")
+	}
+	if len(password) <= 7 {
+		return fmt.Errorf("password must contain at least 8 characters")
+	}
+	return nil
+}
+
 func validateEmail(email string) (bool, error) {
 	if len(email) > 50 {
 		return false, fmt.Errorf("email exceeds required length")
@@ -71,6 +89,30 @@ func validateEmail(email string) (bool, error) {
 	return true, nil
 }
 
+// domainListed reports whether domain or one of its parent domains is in
+// domains.
+func domainListed(domain string, domains []string) bool {
+	for _, listed := range domains {
+		if domain == listed || strings.HasSuffix(domain, "."+listed) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEmailDomain applies the blocked domain list and, under the domain
+// registration policy, the allowed domain list to an email address.
+func checkEmailDomain(email string) error {
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	if domainListed(domain, utils.BlockedEmailDomains) {
+		return fmt.Errorf("disposable email addresses are not allowed, please use a permanent email address")
+	}
+	if utils.RegistrationPolicy == utils.RegistrationDomain && !domainListed(domain, utils.RegistrationDomains) {
+		return fmt.Errorf("registration is limited to email addresses at %s", strings.Join(utils.RegistrationDomains, ", "))
+	}
+	return nil
+}
+
 func (handler *registerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	var (
 		username        = r.FormValue("username")
@@ -82,6 +124,7 @@ func (handler *registerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		currentjob      = r.FormValue("current_job")
 		linkedinprofile = r.FormValue("linkedin_profile")
 		twitterprofile  = r.FormValue("twitter_profile")
+		inviteCode      = normalizeInviteCode(r.FormValue("invite_code"))
 		dataresp        = map[string]interface{}{}
 	)
 
@@ -95,22 +138,49 @@ func (handler *registerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// ensure the password is greater than 7 values
-	// also ensure that it has special characters
-	specialchars := strings.ContainsAny(password, "$ % @ !")
-	passwdcount := len(password)
-	if !specialchars {
-		handler.logger.Error("password must contain special characters")
-		dataresp["err"] = "password must contain special characters"
+	if err := validateUsername(username); err != nil {
+		dataresp["err"] = err.Error()
+		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusBadRequest)
+		return
+	}
+	taken, err := usernameTaken(r.Context(), handler.mysqlclient, username, 0)
+	if err != nil {
+		dataresp["err"] = "cannot register user, try again"
+		handler.logger.Error("could not check username", zap.Error(err))
+		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusInternalServerError)
+		return
+	}
+	if taken {
+		dataresp["err"] = "username is already taken"
+		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusConflict)
+		return
+	}
+
+	if err := validatePassword(password); err != nil {
+		handler.logger.Error(err.Error())
+		dataresp["err"] = err.Error()
 		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusBadRequest)
 		return
 	}
-	if passwdcount <= 7 {
-		handler.logger.Error("password must contain at least 8 characters")
-		dataresp["err"] = "password must contain at least 8 characters"
+
+	sanitize_email, err := validateEmail(email)
+	if err != nil || !sanitize_email {
+		handler.logger.Error("email was malformed!", zap.Error(err))
+		dataresp["err"] = "email is malformed"
 		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusBadRequest)
 		return
 	}
+	if err := checkEmailDomain(email); err != nil {
+		handler.logger.Debug("registration refused for email domain", zap.String("email", email))
+		dataresp["err"] = err.Error()
+		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusForbidden)
+		return
+	}
+	if utils.RegistrationPolicy == utils.RegistrationInvite && inviteCode == "" {
+		dataresp["err"] = "registration is by invitation only, please provide an invite code"
+		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusForbidden)
+		return
+	}
 
 	// we need to hash the password to avoid security issues
 	// and then re-hash it when the user wants to login
@@ -120,24 +190,53 @@ func (handler *registerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 		fmt.Printf("cannot hash password(%s)", password)
 		return
 	}
-	newsessionkey := createSessionKey(email, time.Now())
-	sanitize_email, err := validateEmail(email)
-	if err != nil || !sanitize_email {
-		handler.logger.Error("email was malformed!", zap.Error(err))
-		return
+
+	// the code is taken before the account exists and given back if that fails
+	if utils.RegistrationPolicy == utils.RegistrationInvite {
+		redeemed, err := handler.mysqlclient.RedeemInviteCode(r.Context(), utils.HashToken(inviteCode))
+		if err != nil {
+			dataresp["err"] = "cannot register user, try again"
+			handler.logger.Error("could not redeem invite code", zap.Error(err))
+			apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusInternalServerError)
+			return
+		}
+		if !redeemed {
+			dataresp["err"] = "invite code is invalid, expired or used up"
+			apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusForbidden)
+			return
+		}
 	}
-	createUser, err := handler.mysqlclient.CreateUser(r.Context(), username, hashed_password, email, degree, gradyear, currentjob, phone, newsessionkey, "", linkedinprofile, twitterprofile)
+	createUser, err := handler.mysqlclient.CreateUser(r.Context(), username, hashed_password, email, degree, gradyear, currentjob, phone, "", linkedinprofile, twitterprofile)
 	if err != nil || !createUser {
+		if utils.RegistrationPolicy == utils.RegistrationInvite {
+			if err := handler.mysqlclient.ReleaseInviteCode(r.Context(), utils.HashToken(inviteCode)); err != nil {
+				handler.logger.Error("could not release invite code", zap.Error(err))
+			}
+		}
 		dataresp["err"] = "cannot register user, try again"
 		handler.logger.Error("could not create user", zap.Any("error", err))
 		apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusInternalServerError)
 		return
 	}
+
+	// the account stays unverified until the emailed link is opened
+	if newUser, err := handler.mysqlclient.GetUserByEmail(r.Context(), email); err != nil {
+		handler.logger.Error("could not fetch new user for verification", zap.Error(err))
+	} else {
+		if _, err := handler.mysqlclient.MarkVerificationSent(r.Context(), newUser.Id, time.Now()); err != nil {
+			handler.logger.Error("could not record verification email", zap.Error(err))
+		}
+		if err := sendVerificationEmail(r.Context(), handler.mailer, handler.appURL, newUser, newUser.Email); err != nil {
+			handler.logger.Error("could not send verification email", zap.Error(err))
+		}
+	}
 	dataresp["username"] = username
 	dataresp["email"] = email
 	dataresp["degree"] = degree
 	dataresp["phone"] = phone
-	dataresp["session_key"] = newsessionkey
+	dataresp["email_verified"] = false
+	dataresp["verified_alumnus"] = false
+	dataresp["message"] = "registration successful, please check your email to verify your account"
 	handler.logger.Error("user successfully created", zap.Bool("registration success", createUser))
 	apiResponse(w, GetSuccessResponse(dataresp, registerTTL), http.StatusOK)
 }
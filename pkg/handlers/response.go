@@ -1,13 +1,12 @@
 package handlers
 
 import (
-	"crypto/md5"
 	"encoding/json"
-	"fmt"
-	"io"
+	"errors"
 	"net/http"
 	"reflect"
-	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/utils"
 )
 
 type response struct {
@@ -51,19 +50,14 @@ func GetErrorResponseBytes(data interface{}, ttl int, err error) []byte {
 
 	return responseBytes
 }
-func createSessionKey(patronID string, t time.Time) string {
-	var (
-		hash = md5.New()
-		err  error
-	)
-	if _, err = io.WriteString(hash, patronID); err != nil {
-		return err.Error()
-	}
-	if _, err = io.WriteString(hash, t.Format(time.RFC3339)); err != nil {
-		return err.Error()
-	}
 
-	return fmt.Sprintf("%x", hash.Sum(nil))
+// authErrorStatus is 403 for signed in users who may not perform an action
+// yet, e.g. before verifying their email, and 401 otherwise.
+func authErrorStatus(err error) int {
+	if errors.Is(err, utils.ErrEmailNotVerified) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
 }
 
 func apiResponse(w http.ResponseWriter, responseByte []byte, statusCode int) {
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &rolesHandler{}
+
+type rolesHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+}
+
+func NewRolesHandler(logger *zap.Logger, db mysql.Database) *rolesHandler {
+	return &rolesHandler{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// ServeHTTP lists every role with the permissions it grants.
+func (rh *rolesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	roles_resp := map[string]interface{}{}
+	roles, err := rh.db.GetRoles(r.Context())
+	if err != nil {
+		roles_resp["err"] = "unable to fetch roles"
+		rh.logger.Error("err fetching roles", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(roles_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	roles_resp["roles"] = roles
+	apiResponse(w, GetSuccessResponse(roles_resp, 30), http.StatusOK)
+}
+
+var _ http.Handler = &setUserRoleHandler{}
+
+type setUserRoleHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+}
+
+func NewSetUserRoleHandler(logger *zap.Logger, db mysql.Database) *setUserRoleHandler {
+	return &setUserRoleHandler{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// ServeHTTP assigns a role to the user with the given email. The new
+// permissions are picked up the next time the user's token is refreshed.
+func (srh *setUserRoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	role_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), srh.logger, srh.db)
+	if err != nil {
+		role_resp["err"] = "please sign in to access this page"
+		srh.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	var (
+		email    = r.FormValue("email")
+		roleName = r.FormValue("role")
+	)
+	if email == "" || roleName == "" {
+		role_resp["err"] = "email and role are required"
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	role, err := srh.db.GetRole(r.Context(), roleName)
+	if errors.Is(err, sql.ErrNoRows) {
+		role_resp["err"] = "role does not exist"
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		role_resp["err"] = "unable to update role"
+		srh.logger.Error("err fetching role", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := srh.db.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		role_resp["err"] = "user does not exist"
+		srh.logger.Debug("role change for unknown user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	// keeps the last admin from locking everyone out by accident
+	if user.Id == userInfo.Id {
+		role_resp["err"] = "you cannot change your own role"
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusForbidden)
+		return
+	}
+
+	if err := srh.db.SetUserRole(r.Context(), user.Id, role.Name); err != nil {
+		role_resp["err"] = "unable to update role"
+		srh.logger.Error("err updating user role", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(role_resp["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	// sessions carry the old role's permissions and may predate a two factor
+	// requirement of the new one
+	if err := srh.db.RevokeUserSessions(r.Context(), user.Id, ""); err != nil {
+		srh.logger.Error("err revoking sessions after role change", zap.Int("user_id", user.Id), zap.Error(err))
+	}
+	srh.logger.Info("user role changed", zap.Int("by_user_id", userInfo.Id), zap.Int("user_id", user.Id), zap.String("from", user.Role), zap.String("to", role.Name))
+
+	role_resp["email"] = user.Email
+	role_resp["role"] = role.Name
+	role_resp["message"] = "role updated successfully"
+	apiResponse(w, GetSuccessResponse(role_resp, 30), http.StatusOK)
+}
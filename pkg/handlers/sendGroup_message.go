@@ -23,11 +23,11 @@ func NewSendGroupMessageHandler(logger *zap.Logger, db mysql.Database) *sendGrou
 
 func (sgm *sendGroupMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	sgm_resp := map[string]interface{}{}
-	userInfo, err := utils.AuthenticateUser(r.Context(), sgm.logger, sgm.db)
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), sgm.logger, sgm.db)
 	if err != nil {
-		sgm_resp["err"] = "please sign in to access this page"
+		sgm_resp["err"] = err.Error()
 		sgm.logger.Error("unauthorized user")
-		apiResponse(w, GetErrorResponseBytes(sgm_resp["err"], 30, nil), http.StatusUnauthorized)
+		apiResponse(w, GetErrorResponseBytes(sgm_resp["err"], 30, nil), authErrorStatus(err))
 		return
 	}
 	groupID, err := strconv.Atoi(r.FormValue("group_id"))
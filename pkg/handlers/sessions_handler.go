@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &sessionsHandler{}
+
+type sessionsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewSessionsHandler(logger *zap.Logger, mysqlclient mysql.Database) *sessionsHandler {
+	return &sessionsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP lists the devices the user is signed in on.
+func (handler *sessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sessionsres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		sessionsres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(sessionsres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := handler.mysqlclient.GetUserSessions(r.Context(), userInfo.Id)
+	if err != nil {
+		sessionsres["err"] = "unable to fetch sessions"
+		handler.logger.Error("err fetching sessions", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(sessionsres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	current, _ := r.Context().Value(utils.SessionIDKey).(string)
+	list := make([]map[string]interface{}, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, map[string]interface{}{
+			"id":           session.ID,
+			"device":       session.Device,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"current":      session.ID == current,
+		})
+	}
+	sessionsres["sessions"] = list
+	apiResponse(w, GetSuccessResponse(sessionsres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &revokeSessionHandler{}
+
+type revokeSessionHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRevokeSessionHandler(logger *zap.Logger, mysqlclient mysql.Database) *revokeSessionHandler {
+	return &revokeSessionHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP signs out one device by session id.
+func (handler *revokeSessionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	revokeres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		revokeres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	sessionID := r.FormValue("session_id")
+	if sessionID == "" {
+		revokeres["err"] = "session id not provided"
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	// other users' sessions look the same as unknown ones
+	session, err := handler.mysqlclient.GetSession(r.Context(), sessionID)
+	if err != nil || session.UserID != userInfo.Id {
+		revokeres["err"] = "session not found"
+		handler.logger.Debug("revoke for unknown session", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusNotFound)
+		return
+	}
+	if err := handler.mysqlclient.RevokeSession(r.Context(), session.ID); err != nil {
+		revokeres["err"] = "unable to sign out device, please try again"
+		handler.logger.Error("err revoking session", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	revokeres["message"] = "device signed out successfully"
+	apiResponse(w, GetSuccessResponse(revokeres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &revokeOtherSessionsHandler{}
+
+type revokeOtherSessionsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRevokeOtherSessionsHandler(logger *zap.Logger, mysqlclient mysql.Database) *revokeOtherSessionsHandler {
+	return &revokeOtherSessionsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP signs out every device except the one making the request.
+func (handler *revokeOtherSessionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	revokeres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		revokeres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	current, _ := r.Context().Value(utils.SessionIDKey).(string)
+	if err := handler.mysqlclient.RevokeUserSessions(r.Context(), userInfo.Id, current); err != nil {
+		revokeres["err"] = "unable to sign out devices, please try again"
+		handler.logger.Error("err revoking sessions", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(revokeres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	revokeres["message"] = "all other devices signed out successfully"
+	apiResponse(w, GetSuccessResponse(revokeres, loginTTL), http.StatusOK)
+}
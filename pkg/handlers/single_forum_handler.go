@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"go.uber.org/zap"
 )
 
@@ -38,12 +39,24 @@ func (fs *sforumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	get_single_forum_post, err := fs.Db.GetSingleForumPost(r.Context(), slug)
 	if err != nil {
+		// renamed posts keep answering on their old slugs
+		if target, terr := fs.Db.GetForumSlugTarget(r.Context(), slug); terr == nil && target != "" {
+			http.Redirect(w, r, "/forums/post/"+target, http.StatusMovedPermanently)
+			return
+		}
 		fs.logger.Error("err getting forum post", zap.Error(err))
 		sfp["error"] = "unable to get forum post"
 		apiResponse(w, GetErrorResponseBytes(sfp, 30, err), http.StatusInternalServerError)
 		return
 	}
-	comments, err := fs.Db.GetCommentsByForumID(r.Context(), get_single_forum_post.Id)
+	if get_single_forum_post.DeletedAt != nil {
+		sfp["err"] = "forum post not found"
+		apiResponse(w, GetErrorResponseBytes(sfp["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	// signed in readers don't see comments by users they muted or blocked
+	viewerID, _ := r.Context().Value(utils.UserIDKey).(int)
+	comments, err := fs.Db.GetCommentsByForumID(r.Context(), get_single_forum_post.Id, viewerID, repliesShown)
 	if err != nil {
 		sfp["err"] = "unable to post comments"
 		fs.logger.Error("err fetching post comments", zap.Error(err))
@@ -55,10 +68,11 @@ func (fs *sforumStruct) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		forum_resp["id"] = get_single_forum_post.Id
 		forum_resp["title"] = get_single_forum_post.Title
 		forum_resp["description"] = get_single_forum_post.Description
+		forum_resp["author"] = get_single_forum_post.AuthorUsername
 		forum_resp["slug"] = get_single_forum_post.Slug
 		forum_resp["created_at"] = get_single_forum_post.CreatedAt
 		forum_resp["updated_at"] = get_single_forum_post.UpdatedAt
-		forum_resp["comments"] = comments
+		forum_resp["comments"] = commentTree(comments)
 		apiResponse(w, GetSuccessResponse(forum_resp, 30), http.StatusOK)
 	} else {
 		sfp["err"] = "no post data"
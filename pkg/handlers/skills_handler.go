@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	maxUserSkills    = 50
+	skillSuggestions = 10
+)
+
+var _ http.Handler = &skillSuggestionsHandler{}
+
+type skillSuggestionsHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewSkillSuggestionsHandler(logger *zap.Logger, mysqlclient mysql.Database) *skillSuggestionsHandler {
+	return &skillSuggestionsHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP autocompletes skill names from the catalogue for ?q=, so users
+// pick existing skills rather than adding near duplicates.
+func (handler *skillSuggestionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	skillres := map[string]interface{}{}
+	_, key, ok := model.NormalizeSkill(r.URL.Query().Get("q"))
+	if !ok {
+		skillres["skills"] = []model.Skill{}
+		apiResponse(w, GetSuccessResponse(skillres, 60), http.StatusOK)
+		return
+	}
+	skills, err := handler.mysqlclient.SearchSkills(r.Context(), key, skillSuggestions)
+	if err != nil {
+		skillres["err"] = "unable to fetch skills, please try again"
+		handler.logger.Error("err searching skills", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], 60, nil), http.StatusInternalServerError)
+		return
+	}
+	skillres["skills"] = skills
+	apiResponse(w, GetSuccessResponse(skillres, 60), http.StatusOK)
+}
+
+var _ http.Handler = &addSkillHandler{}
+
+type addSkillHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewAddSkillHandler(logger *zap.Logger, mysqlclient mysql.Database) *addSkillHandler {
+	return &addSkillHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP lists a skill on the user's profile. Names are matched to the
+// catalogue without case, new ones are added to it.
+func (handler *addSkillHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	skillres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		skillres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	name, key, ok := model.NormalizeSkill(r.FormValue("name"))
+	if !ok {
+		skillres["err"] = "skill names are 1 to 50 letters, digits, spaces or . + # / & ' -"
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusBadRequest)
+		return
+	}
+	skills, err := handler.mysqlclient.GetUserSkills(r.Context(), userInfo.Id, userInfo.Id)
+	if err != nil {
+		skillres["err"] = "unable to add skill, please try again"
+		handler.logger.Error("err fetching skills", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if len(skills) >= maxUserSkills {
+		skillres["err"] = "you can list at most " + strconv.Itoa(maxUserSkills) + " skills"
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusConflict)
+		return
+	}
+	skillID, added, err := handler.mysqlclient.AddUserSkill(r.Context(), userInfo.Id, name, key)
+	if err != nil {
+		skillres["err"] = "unable to add skill, please try again"
+		handler.logger.Error("err adding skill", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		skillres["err"] = "skill is already on your profile"
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusConflict)
+		return
+	}
+	skillres["skill_id"] = skillID
+	skillres["message"] = "skill added"
+	apiResponse(w, GetSuccessResponse(skillres, profileTTL), http.StatusCreated)
+}
+
+var _ http.Handler = &removeSkillHandler{}
+
+type removeSkillHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRemoveSkillHandler(logger *zap.Logger, mysqlclient mysql.Database) *removeSkillHandler {
+	return &removeSkillHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP takes /users/profile/skills/{id} off the profile, its
+// endorsements go with it.
+func (handler *removeSkillHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	skillres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		skillres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	skillID, _ := strconv.Atoi(mux.Vars(r)["id"])
+	removed, err := handler.mysqlclient.RemoveUserSkill(r.Context(), userInfo.Id, skillID)
+	if err != nil {
+		skillres["err"] = "unable to remove skill, please try again"
+		handler.logger.Error("err removing skill", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		skillres["err"] = "skill is not on your profile"
+		apiResponse(w, GetErrorResponseBytes(skillres["err"], profileTTL, nil), http.StatusNotFound)
+		return
+	}
+	skillres["message"] = "skill removed"
+	apiResponse(w, GetSuccessResponse(skillres, profileTTL), http.StatusOK)
+}
+
+var _ http.Handler = &endorseSkillHandler{}
+
+type endorseSkillHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewEndorseSkillHandler(logger *zap.Logger, mysqlclient mysql.Database) *endorseSkillHandler {
+	return &endorseSkillHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP endorses skill_id on the profile of user_id. Only connections
+// can endorse, and each of them once per skill.
+func (handler *endorseSkillHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endorseres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateVerifiedUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		endorseres["err"] = err.Error()
+		handler.logger.Debug("unauthorized user", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), authErrorStatus(err))
+		return
+	}
+	userID, _ := strconv.Atoi(r.FormValue("user_id"))
+	skillID, _ := strconv.Atoi(r.FormValue("skill_id"))
+	if userID <= 0 || skillID <= 0 {
+		endorseres["err"] = "user id and skill id are required"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	if userID == userInfo.Id {
+		endorseres["err"] = "you cannot endorse your own skills"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	connected, err := handler.mysqlclient.CheckConnection(r.Context(), userInfo.Id, userID)
+	if err != nil {
+		endorseres["err"] = "unable to endorse skill, please try again"
+		handler.logger.Error("err checking connection", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !connected {
+		endorseres["err"] = "only connections can endorse skills"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusForbidden)
+		return
+	}
+	skills, err := handler.mysqlclient.GetUserSkills(r.Context(), userID, userInfo.Id)
+	if err != nil {
+		endorseres["err"] = "unable to endorse skill, please try again"
+		handler.logger.Error("err fetching skills", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	listed := false
+	for _, skill := range skills {
+		listed = listed || skill.SkillID == skillID
+	}
+	if !listed {
+		endorseres["err"] = "skill is not on this user's profile"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	endorsed, err := handler.mysqlclient.EndorseSkill(r.Context(), userInfo.Id, userID, skillID)
+	if err != nil {
+		endorseres["err"] = "unable to endorse skill, please try again"
+		handler.logger.Error("err endorsing skill", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !endorsed {
+		endorseres["err"] = "you have already endorsed this skill"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusConflict)
+		return
+	}
+	handler.logger.Info("skill endorsed", zap.Int("endorser_id", userInfo.Id), zap.Int("user_id", userID), zap.Int("skill_id", skillID))
+	endorseres["message"] = "skill endorsed"
+	apiResponse(w, GetSuccessResponse(endorseres, 30), http.StatusOK)
+}
+
+var _ http.Handler = &withdrawEndorsementHandler{}
+
+type withdrawEndorsementHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewWithdrawEndorsementHandler(logger *zap.Logger, mysqlclient mysql.Database) *withdrawEndorsementHandler {
+	return &withdrawEndorsementHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP takes back the signed in user's endorsement of skill_id on the
+// profile of user_id.
+func (handler *withdrawEndorsementHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endorseres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		endorseres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(r.FormValue("user_id"))
+	skillID, _ := strconv.Atoi(r.FormValue("skill_id"))
+	if userID <= 0 || skillID <= 0 {
+		endorseres["err"] = "user id and skill id are required"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusBadRequest)
+		return
+	}
+	withdrawn, err := handler.mysqlclient.WithdrawEndorsement(r.Context(), userInfo.Id, userID, skillID)
+	if err != nil {
+		endorseres["err"] = "unable to withdraw endorsement, please try again"
+		handler.logger.Error("err withdrawing endorsement", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusInternalServerError)
+		return
+	}
+	if !withdrawn {
+		endorseres["err"] = "you have not endorsed this skill"
+		apiResponse(w, GetErrorResponseBytes(endorseres["err"], 30, nil), http.StatusNotFound)
+		return
+	}
+	endorseres["message"] = "endorsement withdrawn"
+	apiResponse(w, GetSuccessResponse(endorseres, 30), http.StatusOK)
+}
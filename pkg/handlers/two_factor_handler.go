@@ -0,0 +1,472 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/totp"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	mfaLoginPurpose   = "mfa_login"
+	totpIssuer        = "LASU Alumni"
+	recoveryCodeCount = 10
+)
+
+var mfaLoginExpiry = 5 * time.Minute
+
+// generateRecoveryCodes returns fresh one time recovery codes and the hashes
+// to store for them.
+func generateRecoveryCodes() ([]string, []string, error) {
+	var (
+		codes  = make([]string, 0, recoveryCodeCount)
+		hashes = make([]string, 0, recoveryCodeCount)
+		enc    = base32.StdEncoding.WithPadding(base32.NoPadding)
+	)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// checkSecondFactor accepts either a current totp code or an unused recovery
+// code. Both are single use.
+func checkSecondFactor(ctx context.Context, db mysql.Database, twoFactor *model.TwoFactor, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		return db.UseRecoveryCode(ctx, twoFactor.UserID, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+	}
+	step, ok := totp.Validate(twoFactor.Secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return db.UseTwoFactorStep(ctx, twoFactor.UserID, step)
+}
+
+func getTwoFactor(ctx context.Context, db mysql.Database, userID int) (*model.TwoFactor, error) {
+	twoFactor, err := db.GetTwoFactor(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return twoFactor, err
+}
+
+// twoFactorRequired reports whether two factor authentication is mandatory
+// for the user, set on the account by an admin or through their role.
+func twoFactorRequired(ctx context.Context, db mysql.Database, user *model.User) (bool, error) {
+	if user.MFARequired {
+		return true, nil
+	}
+	role, err := db.GetRole(ctx, user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role.Require2FA, nil
+}
+
+var _ http.Handler = &twoFactorEnrollHandler{}
+
+type twoFactorEnrollHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewTwoFactorEnrollHandler(logger *zap.Logger, mysqlclient mysql.Database) *twoFactorEnrollHandler {
+	return &twoFactorEnrollHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP creates a new totp secret. Two factor authentication is only
+// switched on once a code for it is confirmed.
+func (handler *twoFactorEnrollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	enrollres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		enrollres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(enrollres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, userInfo.Id)
+	if err != nil {
+		enrollres["err"] = "unable to set up two factor authentication"
+		handler.logger.Error("err fetching two factor settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(enrollres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if twoFactor != nil && twoFactor.EnabledAt != nil {
+		enrollres["err"] = "two factor authentication is already enabled"
+		apiResponse(w, GetErrorResponseBytes(enrollres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		enrollres["err"] = "unable to set up two factor authentication"
+		handler.logger.Error("err generating totp secret", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(enrollres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if err := handler.mysqlclient.SaveTwoFactorSecret(r.Context(), userInfo.Id, secret); err != nil {
+		enrollres["err"] = "unable to set up two factor authentication"
+		handler.logger.Error("err saving totp secret", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(enrollres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+
+	enrollres["secret"] = secret
+	enrollres["otpauth_uri"] = totp.URI(totpIssuer, userInfo.Email, secret)
+	enrollres["message"] = "scan the uri with your authenticator app and confirm a code to finish"
+	apiResponse(w, GetSuccessResponse(enrollres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &twoFactorConfirmHandler{}
+
+type twoFactorConfirmHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewTwoFactorConfirmHandler(logger *zap.Logger, mysqlclient mysql.Database) *twoFactorConfirmHandler {
+	return &twoFactorConfirmHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP switches two factor authentication on with the first valid code
+// and hands out the recovery codes. Users who signed in with an enrollment
+// token get its session upgraded to a full one in exchange.
+func (handler *twoFactorConfirmHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	confirmres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		confirmres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	// enrollment tokens are only handed to accounts that must enroll, check
+	// it still holds before the token earns a session
+	enrolling, _ := r.Context().Value(utils.EnrollmentKey).(bool)
+	if enrolling {
+		required, err := twoFactorRequired(r.Context(), handler.mysqlclient, userInfo)
+		if err != nil {
+			confirmres["err"] = "unable to confirm two factor authentication"
+			handler.logger.Error("err checking two factor requirement", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusInternalServerError)
+			return
+		}
+		if !required {
+			confirmres["err"] = "sign in expired, please sign in again"
+			handler.logger.Warn("enrollment token used by account without two factor requirement", zap.Int("user_id", userInfo.Id))
+			apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, userInfo.Id)
+	if err != nil {
+		confirmres["err"] = "unable to confirm two factor authentication"
+		handler.logger.Error("err fetching two factor settings", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if twoFactor == nil || twoFactor.EnabledAt != nil {
+		confirmres["err"] = "no two factor enrollment in progress"
+		apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+
+	valid, err := checkSecondFactor(r.Context(), handler.mysqlclient, twoFactor, r.FormValue("code"), "")
+	if err != nil || !valid {
+		confirmres["err"] = "invalid authentication code"
+		handler.logger.Debug("invalid totp code during enrollment", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = handler.mysqlclient.ReplaceRecoveryCodes(r.Context(), userInfo.Id, hashes)
+	}
+	if err == nil {
+		_, err = handler.mysqlclient.EnableTwoFactor(r.Context(), userInfo.Id)
+	}
+	if err != nil {
+		confirmres["err"] = "unable to confirm two factor authentication"
+		handler.logger.Error("err enabling two factor authentication", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+
+	if enrolling {
+		if jti, ok := r.Context().Value(utils.TokenIDKey).(string); ok {
+			if err := handler.mysqlclient.RevokeAccessToken(r.Context(), jti, time.Now().Add(mfaLoginExpiry)); err != nil {
+				handler.logger.Error("err revoking enrollment token", zap.Error(err))
+			}
+		}
+		sessionID, _ := r.Context().Value(utils.SessionIDKey).(string)
+		jwt, refreshToken, err := issueTokenPair(r.Context(), handler.mysqlclient, userInfo, sessionID)
+		if err != nil {
+			confirmres["err"] = "two factor enabled, please sign in again"
+			handler.logger.Error("err issuing token pair after enrollment", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(confirmres["err"], loginTTL, nil), http.StatusInternalServerError)
+			return
+		}
+		setLoginResponse(confirmres, userInfo, jwt, refreshToken)
+	}
+
+	confirmres["recovery_codes"] = codes
+	confirmres["message"] = "two factor authentication enabled, store the recovery codes somewhere safe"
+	apiResponse(w, GetSuccessResponse(confirmres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &twoFactorVerifyHandler{}
+
+type twoFactorVerifyHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	guard       *loginGuard
+}
+
+func NewTwoFactorVerifyHandler(logger *zap.Logger, mysqlclient mysql.Database, ipLimiter *ratelimit.SlidingWindow) *twoFactorVerifyHandler {
+	return &twoFactorVerifyHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		guard:       newLoginGuard(logger, mysqlclient, ipLimiter),
+	}
+}
+
+// ServeHTTP is the second login step: the mfa_token from /login plus a totp
+// or recovery code are exchanged for the jwt and refresh token.
+func (handler *twoFactorVerifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		mfaToken     = r.FormValue("mfa_token")
+		code         = r.FormValue("code")
+		recoveryCode = r.FormValue("recovery_code")
+		verifyres    = map[string]interface{}{}
+	)
+	if mfaToken == "" || (code == "" && recoveryCode == "") {
+		verifyres["err"] = "mfa token and code are required"
+		handler.logger.Error("mfa token or code not provided")
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	subject, err := utils.VerifySignedToken(mfaToken, mfaLoginPurpose, utils.LinkSecret)
+	if err != nil {
+		verifyres["err"] = "sign in expired, please sign in again"
+		handler.logger.Debug("invalid mfa token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	userID, _ := strconv.Atoi(subject)
+	user, err := handler.mysqlclient.GetUserByID(r.Context(), userID)
+	if err != nil {
+		verifyres["err"] = "sign in expired, please sign in again"
+		handler.logger.Error("err fetching mfa token owner", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	// codes count against the same limits as passwords
+	ip := utils.ClientIP(r)
+	wait, err := handler.guard.check(r.Context(), user.Email, ip)
+	if err != nil {
+		verifyres["err"] = "unable to authenticate user"
+		handler.logger.Error("err checking login attempts", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, user.Id)
+	if err != nil || twoFactor == nil || twoFactor.EnabledAt == nil {
+		verifyres["err"] = "sign in expired, please sign in again"
+		handler.logger.Error("two factor not enabled for mfa token owner", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	valid, err := checkSecondFactor(r.Context(), handler.mysqlclient, twoFactor, code, recoveryCode)
+	if err != nil {
+		verifyres["err"] = "unable to authenticate user"
+		handler.logger.Error("err checking second factor", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		handler.guard.fail(r.Context(), user.Email, ip)
+		verifyres["err"] = "invalid authentication code"
+		handler.logger.Warn("invalid second factor", zap.Int("user_id", user.Id), zap.Bool("recovery_code", recoveryCode != ""))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	handler.guard.succeed(r.Context(), user.Email)
+	if recoveryCode != "" {
+		handler.logger.Info("recovery code used to sign in", zap.Int("user_id", user.Id))
+	}
+
+	jwt, refreshToken, err := signIn(r, handler.mysqlclient, user)
+	if err != nil {
+		verifyres["err"] = "unable to authenticate user"
+		handler.logger.Error("err generating auth token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	setLoginResponse(verifyres, user, jwt, refreshToken)
+	apiResponse(w, GetSuccessResponse(verifyres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &twoFactorDisableHandler{}
+
+type twoFactorDisableHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewTwoFactorDisableHandler(logger *zap.Logger, mysqlclient mysql.Database) *twoFactorDisableHandler {
+	return &twoFactorDisableHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP turns two factor authentication off. It needs the password and a
+// second factor, and is refused for accounts an admin requires it for.
+func (handler *twoFactorDisableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	disableres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		disableres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+	required, err := twoFactorRequired(r.Context(), handler.mysqlclient, userInfo)
+	if err != nil {
+		disableres["err"] = "unable to disable two factor authentication"
+		handler.logger.Error("err checking two factor requirement", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	if required {
+		disableres["err"] = "two factor authentication is required for your account"
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusForbidden)
+		return
+	}
+	if !CheckPasswordHash(r.FormValue("password"), userInfo.Password) {
+		disableres["err"] = "password incorrect"
+		handler.logger.Debug("wrong password disabling two factor", zap.Int("user_id", userInfo.Id))
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, userInfo.Id)
+	if err != nil || twoFactor == nil || twoFactor.EnabledAt == nil {
+		disableres["err"] = "two factor authentication is not enabled"
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+	valid, err := checkSecondFactor(r.Context(), handler.mysqlclient, twoFactor, r.FormValue("code"), r.FormValue("recovery_code"))
+	if err != nil || !valid {
+		disableres["err"] = "invalid authentication code"
+		handler.logger.Debug("invalid second factor disabling two factor", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	if err := handler.mysqlclient.DisableTwoFactor(r.Context(), userInfo.Id); err != nil {
+		disableres["err"] = "unable to disable two factor authentication"
+		handler.logger.Error("err disabling two factor", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(disableres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	disableres["message"] = "two factor authentication disabled"
+	apiResponse(w, GetSuccessResponse(disableres, loginTTL), http.StatusOK)
+}
+
+var _ http.Handler = &recoveryCodesHandler{}
+
+type recoveryCodesHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewRecoveryCodesHandler(logger *zap.Logger, mysqlclient mysql.Database) *recoveryCodesHandler {
+	return &recoveryCodesHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ServeHTTP replaces all recovery codes after checking a current totp code.
+func (handler *recoveryCodesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	codesres := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), handler.logger, handler.mysqlclient)
+	if err != nil {
+		codesres["err"] = "please sign in to access this page"
+		handler.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(codesres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	twoFactor, err := getTwoFactor(r.Context(), handler.mysqlclient, userInfo.Id)
+	if err != nil || twoFactor == nil || twoFactor.EnabledAt == nil {
+		codesres["err"] = "two factor authentication is not enabled"
+		apiResponse(w, GetErrorResponseBytes(codesres["err"], loginTTL, nil), http.StatusConflict)
+		return
+	}
+	valid, err := checkSecondFactor(r.Context(), handler.mysqlclient, twoFactor, r.FormValue("code"), "")
+	if err != nil || !valid {
+		codesres["err"] = "invalid authentication code"
+		handler.logger.Debug("invalid totp code regenerating recovery codes", zap.Int("user_id", userInfo.Id), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(codesres["err"], loginTTL, nil), http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err == nil {
+		err = handler.mysqlclient.ReplaceRecoveryCodes(r.Context(), userInfo.Id, hashes)
+	}
+	if err != nil {
+		codesres["err"] = "unable to generate recovery codes"
+		handler.logger.Error("err replacing recovery codes", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(codesres["err"], loginTTL, nil), http.StatusInternalServerError)
+		return
+	}
+	codesres["recovery_codes"] = codes
+	apiResponse(w, GetSuccessResponse(codesres, loginTTL), http.StatusOK)
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const verifyEmailPurpose = "verify_email"
+
+var (
+	verifyEmailExpiry          = 48 * time.Hour
+	verificationResendInterval = 5 * time.Minute
+	verificationSendTimeout    = time.Minute // how long sending a link in the background may take
+)
+
+// sendVerificationEmail mails a signed link proving the user owns email. The
+// link is bound to the address so it stops working if the email changes.
+func sendVerificationEmail(ctx context.Context, m mailer.Mailer, appURL string, user *model.User, email string) error {
+	token := utils.NewSignedToken(verifyEmailPurpose, fmt.Sprintf("%d:%s", user.Id, email), verifyEmailExpiry, utils.LinkSecret)
+	msg := mailer.Message{
+		To:      email,
+		Subject: "Verify your alumni network email",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm this email address by opening the link below within %s:\n\n%s/verify-email?token=%s\n\nIf you did not create an account, you can ignore this email.\n",
+			user.Username, verifyEmailExpiry, strings.TrimRight(appURL, "/"), url.QueryEscape(token)),
+	}
+	return m.Send(ctx, msg)
+}
+
+var _ http.Handler = &verifyEmailHandler{}
+
+type verifyEmailHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+}
+
+func NewVerifyEmailHandler(logger *zap.Logger, mysqlclient mysql.Database) *verifyEmailHandler {
+	return &verifyEmailHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+	}
+}
+
+func (handler *verifyEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	verifyres := map[string]interface{}{}
+	token := r.FormValue("token")
+	if token == "" {
+		verifyres["err"] = "verification token not provided"
+		handler.logger.Error("verification token not provided")
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	subject, err := utils.VerifySignedToken(token, verifyEmailPurpose, utils.LinkSecret)
+	if err != nil {
+		verifyres["err"] = err.Error()
+		handler.logger.Error("invalid verification token", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusBadRequest)
+		return
+	}
+	rawID, email, _ := strings.Cut(subject, ":")
+	userID, err := strconv.Atoi(rawID)
+	if err != nil {
+		verifyres["err"] = utils.ErrInvalidSignedToken.Error()
+		handler.logger.Error("malformed verification subject", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	user, err := handler.mysqlclient.GetUserByID(r.Context(), userID)
+	if err != nil || (user.Email != email && user.PendingEmail != email) {
+		verifyres["err"] = utils.ErrInvalidSignedToken.Error()
+		handler.logger.Error("verification link does not match the account", zap.Int("user_id", userID), zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	// links for the pending email finish an email change
+	if user.Email != email {
+		if owner, err := handler.mysqlclient.GetUserByEmail(r.Context(), email); err == nil && owner.Id != user.Id {
+			verifyres["err"] = "this email is already used by another account"
+			handler.logger.Debug("email change to a taken address", zap.Int("user_id", user.Id))
+			apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusConflict)
+			return
+		}
+		changed, err := handler.mysqlclient.ConfirmEmailChange(r.Context(), user.Id, email)
+		if err != nil {
+			verifyres["err"] = "unable to change email, please try again"
+			handler.logger.Error("err confirming email change", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusInternalServerError)
+			return
+		}
+		if !changed {
+			verifyres["err"] = utils.ErrInvalidSignedToken.Error()
+			apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusBadRequest)
+			return
+		}
+		handler.logger.Info("email changed", zap.Int("user_id", user.Id))
+		verifyres["email"] = email
+		verifyres["email_verified"] = true
+		verifyres["message"] = "email changed successfully"
+		apiResponse(w, GetSuccessResponse(verifyres, registerTTL), http.StatusOK)
+		return
+	}
+
+	if user.EmailVerifiedAt == nil {
+		if _, err := handler.mysqlclient.MarkEmailVerified(r.Context(), user.Id); err != nil {
+			verifyres["err"] = "unable to verify email, please try again"
+			handler.logger.Error("err marking email verified", zap.Error(err))
+			apiResponse(w, GetErrorResponseBytes(verifyres["err"], registerTTL, nil), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	verifyres["email"] = user.Email
+	verifyres["email_verified"] = true
+	verifyres["message"] = "email verified successfully"
+	apiResponse(w, GetSuccessResponse(verifyres, registerTTL), http.StatusOK)
+}
+
+var _ http.Handler = &resendVerificationHandler{}
+
+type resendVerificationHandler struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	mailer      mailer.Mailer
+	appURL      string
+}
+
+func NewResendVerificationHandler(logger *zap.Logger, mysqlclient mysql.Database, mailer mailer.Mailer, appURL string) *resendVerificationHandler {
+	return &resendVerificationHandler{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		mailer:      mailer,
+		appURL:      appURL,
+	}
+}
+
+// ServeHTTP sends a fresh verification link, at most once per
+// verificationResendInterval for each account. Unknown, already verified and
+// throttled emails get the same answer, and the link is sent in the
+// background, so accounts can't be probed.
+func (handler *resendVerificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resendres := map[string]interface{}{}
+	email := r.FormValue("email")
+	if email == "" {
+		resendres["err"] = "email not provided"
+		handler.logger.Error("email not provided")
+		apiResponse(w, GetErrorResponseBytes(resendres["err"], registerTTL, nil), http.StatusBadRequest)
+		return
+	}
+
+	resendres["message"] = "if the account exists and is not verified yet, a new verification link has been sent"
+	user, err := handler.mysqlclient.GetUserByEmail(r.Context(), email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		apiResponse(w, GetSuccessResponse(resendres, registerTTL), http.StatusOK)
+		return
+	}
+	// the request context ends with the response
+	go handler.resend(user)
+	apiResponse(w, GetSuccessResponse(resendres, registerTTL), http.StatusOK)
+}
+
+// resend mails a new verification link unless one was sent within
+// verificationResendInterval. Failures are only logged, the user can ask
+// again.
+func (handler *resendVerificationHandler) resend(user *model.User) {
+	ctx, cancel := context.WithTimeout(context.Background(), verificationSendTimeout)
+	defer cancel()
+
+	allowed, err := handler.mysqlclient.MarkVerificationSent(ctx, user.Id, time.Now().Add(-verificationResendInterval))
+	if err != nil {
+		handler.logger.Error("err recording verification email", zap.Int("user_id", user.Id), zap.Error(err))
+		return
+	}
+	if !allowed {
+		handler.logger.Debug("verification resend throttled", zap.Int("user_id", user.Id))
+		return
+	}
+	if err := sendVerificationEmail(ctx, handler.mailer, handler.appURL, user, user.Email); err != nil {
+		handler.logger.Error("err sending verification email", zap.Int("user_id", user.Id), zap.Error(err))
+	}
+}
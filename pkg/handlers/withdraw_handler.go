@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var _ http.Handler = &withdrawHandler{}
+
+type withdrawHandler struct {
+	logger *zap.Logger
+	db     mysql.Database
+	conn   Connection
+}
+
+func NewWithdrawHandler(logger *zap.Logger, db mysql.Database) *withdrawHandler {
+	return &withdrawHandler{
+		logger: logger,
+		db:     db,
+		conn:   NewConnection(db),
+	}
+}
+
+func (wh *withdrawHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	withdraw_resp := map[string]interface{}{}
+	userInfo, err := utils.AuthenticateUser(r.Context(), wh.logger, wh.db)
+	if err != nil {
+		withdraw_resp["err"] = "please sign in to access this page"
+		wh.logger.Debug("unauthorized user")
+		apiResponse(w, GetErrorResponseBytes(withdraw_resp["err"], 30, nil), http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := strconv.Atoi(r.FormValue("request_id"))
+	if err != nil {
+		withdraw_resp["err"] = "invalid connection request id"
+		wh.logger.Error("err parsing connection request id", zap.Error(err))
+		apiResponse(w, GetErrorResponseBytes(withdraw_resp, 30, nil), http.StatusBadRequest)
+		return
+	}
+
+	if _, err = wh.conn.WithdrawConnectionRequest(r.Context(), requestID, userInfo.Id); err != nil {
+		withdraw_resp["err"] = "unable to withdraw connection request"
+		wh.logger.Error("err withdrawing connection request", zap.Int("request_id", requestID), zap.Error(err))
+		status, publicErr := connectionErrorResponse(err)
+		apiResponse(w, GetErrorResponseBytes(withdraw_resp, 30, publicErr), status)
+		return
+	}
+
+	withdraw_resp["request_id"] = requestID
+	withdraw_resp["status"] = model.ConnectionWithdrawn
+	withdraw_resp["message"] = "connection request withdrawn"
+	apiResponse(w, GetSuccessResponse(withdraw_resp, 30), http.StatusOK)
+}
@@ -0,0 +1,283 @@
+// Package jwtkeys manages the keys access tokens are signed with. A keyset
+// holds one signing key and any number of keys that are only used to verify
+// tokens, so the signing key can be rotated without invalidating tokens that
+// are still in flight.
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// supported algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrUnknownKey    = errors.New("unknown signing key")
+	ErrNoSigningKey  = errors.New("keyset has no signing key")
+	ErrKeyAlgorithm  = errors.New("token algorithm does not match its key")
+	ErrRetireSigning = errors.New("the signing key cannot be retired, rotate first")
+	ErrKeyExpired    = errors.New("signing key is no longer accepted")
+)
+
+// Key is a single key as stored in the keyset file. HS256 keys carry a base64
+// secret, asymmetric keys a PKCS #8 private key in PEM.
+type Key struct {
+	ID         string    `json:"kid"`
+	Algorithm  string    `json:"alg"`
+	Secret     string    `json:"secret,omitempty"`
+	PrivateKey string    `json:"private_key,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	signKey   interface{}
+	verifyKey interface{}
+	notAfter  time.Time // tokens stop verifying with the key after this, zero for never
+}
+
+// KeySet is the content of the keyset file.
+type KeySet struct {
+	SigningKeyID string `json:"signing_kid"`
+	Keys         []*Key `json:"keys"`
+}
+
+// Generate creates a new key for alg with a random id.
+func Generate(alg string) (*Key, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	key := &Key{
+		ID:        fmt.Sprintf("%x", id),
+		Algorithm: alg,
+		CreatedAt: time.Now().UTC(),
+	}
+	switch alg {
+	case HS256:
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		key.Secret = base64.StdEncoding.EncodeToString(secret)
+	case RS256:
+		privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, err = encodePrivateKey(privateKey); err != nil {
+			return nil, err
+		}
+	case EdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, err = encodePrivateKey(privateKey); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use %s, %s or %s", alg, HS256, RS256, EdDSA)
+	}
+	return key, key.parse()
+}
+
+func encodePrivateKey(privateKey interface{}) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// parse decodes the stored key material into the types jwt-go expects.
+func (k *Key) parse() error {
+	switch k.Algorithm {
+	case HS256:
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		k.signKey, k.verifyKey = secret, secret
+		return nil
+	case RS256, EdDSA:
+		block, _ := pem.Decode([]byte(k.PrivateKey))
+		if block == nil {
+			return fmt.Errorf("key %s: private key is not PEM encoded", k.ID)
+		}
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		switch privateKey := privateKey.(type) {
+		case *rsa.PrivateKey:
+			if k.Algorithm == RS256 {
+				k.signKey, k.verifyKey = privateKey, &privateKey.PublicKey
+				return nil
+			}
+		case ed25519.PrivateKey:
+			if k.Algorithm == EdDSA {
+				k.signKey, k.verifyKey = privateKey, privateKey.Public()
+				return nil
+			}
+		}
+		return fmt.Errorf("key %s: private key does not match %s", k.ID, k.Algorithm)
+	}
+	return fmt.Errorf("key %s: unsupported algorithm %q", k.ID, k.Algorithm)
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Legacy returns a keyset with a single HS256 key without an id, the way
+// tokens were signed before keysets existed.
+func Legacy(secret string) *KeySet {
+	key := &Key{Algorithm: HS256, signKey: []byte(secret), verifyKey: []byte(secret)}
+	return &KeySet{Keys: []*Key{key}}
+}
+
+// Load reads the keyset file at path. With an empty path the legacy secret is
+// the only key and signs new tokens. Otherwise the legacy secret only
+// verifies tokens issued without a kid header, and only until legacyUntil,
+// so it can be retired once the tokens it signed have expired. A zero
+// legacyUntil drops it right away.
+func Load(path string, legacySecret string, legacyUntil time.Time) (*KeySet, error) {
+	if path == "" {
+		if legacySecret == "" {
+			return nil, errors.New("no jwt keyset or secret configured")
+		}
+		return Legacy(legacySecret), nil
+	}
+	ks, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ks.signingKey(); err != nil {
+		return nil, err
+	}
+	if legacySecret != "" && legacyUntil.After(time.Now()) {
+		legacy := Legacy(legacySecret).Keys[0]
+		legacy.notAfter = legacyUntil
+		ks.Keys = append(ks.Keys, legacy)
+	}
+	return ks, nil
+}
+
+// ReadFile reads a keyset file as written by WriteFile.
+func ReadFile(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ks := &KeySet{}
+	if err := json.Unmarshal(data, ks); err != nil {
+		return nil, fmt.Errorf("invalid keyset %s: %w", path, err)
+	}
+	for _, key := range ks.Keys {
+		if err := key.parse(); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// WriteFile stores the keyset readable by the owner only.
+func (ks *KeySet) WriteFile(path string) error {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Key returns the key with id kid.
+func (ks *KeySet) Key(kid string) (*Key, error) {
+	for _, key := range ks.Keys {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (ks *KeySet) signingKey() (*Key, error) {
+	key, err := ks.Key(ks.SigningKeyID)
+	if err != nil {
+		return nil, ErrNoSigningKey
+	}
+	return key, nil
+}
+
+// Add puts a new key in the set. It only verifies tokens until it is made the
+// signing key with SetSigningKey.
+func (ks *KeySet) Add(key *Key) {
+	ks.Keys = append(ks.Keys, key)
+}
+
+func (ks *KeySet) SetSigningKey(kid string) error {
+	if _, err := ks.Key(kid); err != nil {
+		return err
+	}
+	ks.SigningKeyID = kid
+	return nil
+}
+
+// Retire removes a key. Tokens signed with it stop verifying, so only retire
+// keys that have not signed anything for longer than the access token TTL.
+func (ks *KeySet) Retire(kid string) error {
+	if kid == ks.SigningKeyID {
+		return ErrRetireSigning
+	}
+	for i, key := range ks.Keys {
+		if key.ID == kid {
+			ks.Keys = append(ks.Keys[:i], ks.Keys[i+1:]...)
+			return nil
+		}
+	}
+	return ErrUnknownKey
+}
+
+// Sign signs claims with the signing key, naming it in the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
+}
+
+// Keyfunc finds the verification key for a token by its kid header and makes
+// sure the token uses the key's algorithm, so a public key can never be used
+// as an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := ks.Key(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrKeyAlgorithm
+	}
+	if !key.notAfter.IsZero() && time.Now().After(key.notAfter) {
+		return nil, ErrKeyExpired
+	}
+	return key.verifyKey, nil
+}
@@ -0,0 +1,173 @@
+package jwtkeys
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func testKeySet(t *testing.T, algs ...string) *KeySet {
+	t.Helper()
+	ks := &KeySet{}
+	for _, alg := range algs {
+		key, err := Generate(alg)
+		if err != nil {
+			t.Fatalf("Generate(%s): %v", alg, err)
+		}
+		ks.Add(key)
+	}
+	if err := ks.SetSigningKey(ks.Keys[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"sub": "1", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+// keyError unwraps the error Keyfunc returned from a jwt.Parse error.
+func keyError(err error) error {
+	var validation *jwt.ValidationError
+	if errors.As(err, &validation) && validation.Inner != nil {
+		return validation.Inner
+	}
+	return err
+}
+
+func TestKeyfuncKidLookup(t *testing.T) {
+	ks := testKeySet(t, HS256, RS256, EdDSA)
+	for _, key := range ks.Keys {
+		if err := ks.SetSigningKey(key.ID); err != nil {
+			t.Fatal(err)
+		}
+		signed, err := ks.Sign(testClaims())
+		if err != nil {
+			t.Fatalf("Sign with %s: %v", key.Algorithm, err)
+		}
+		token, err := jwt.Parse(signed, ks.Keyfunc)
+		if err != nil || !token.Valid {
+			t.Errorf("%s token rejected: %v", key.Algorithm, err)
+			continue
+		}
+		if kid := token.Header["kid"]; kid != key.ID {
+			t.Errorf("%s token has kid %v, want %s", key.Algorithm, kid, key.ID)
+		}
+	}
+}
+
+func TestKeyfuncRotation(t *testing.T) {
+	ks := testKeySet(t, HS256)
+	old, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := ks.SigningKeyID
+
+	next, err := Generate(EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Add(next)
+	if err := ks.SetSigningKey(next.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(old, ks.Keyfunc); err != nil {
+		t.Errorf("token of the previous signing key rejected after rotation: %v", err)
+	}
+
+	if err := ks.Retire(oldID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(old, ks.Keyfunc); !errors.Is(keyError(err), ErrUnknownKey) {
+		t.Errorf("token of a retired key: err = %v, want ErrUnknownKey", err)
+	}
+	if err := ks.Retire(next.ID); !errors.Is(err, ErrRetireSigning) {
+		t.Errorf("retiring the signing key: err = %v, want ErrRetireSigning", err)
+	}
+}
+
+func TestKeyfuncUnknownKid(t *testing.T) {
+	ks := testKeySet(t, HS256)
+	other := testKeySet(t, HS256)
+	signed, err := other.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, ks.Keyfunc); !errors.Is(keyError(err), ErrUnknownKey) {
+		t.Errorf("err = %v, want ErrUnknownKey", err)
+	}
+}
+
+// An attacker who knows a public key signs an HS256 token with it as the
+// HMAC secret and names the asymmetric key in the kid header.
+func TestKeyfuncRejectsAlgorithmConfusion(t *testing.T) {
+	ks := testKeySet(t, RS256, EdDSA)
+	for _, key := range ks.Keys {
+		der, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		for _, secret := range [][]byte{publicPEM, der} {
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+			forged.Header["kid"] = key.ID
+			signed, err := forged.SignedString(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := jwt.Parse(signed, ks.Keyfunc); !errors.Is(keyError(err), ErrKeyAlgorithm) {
+				t.Errorf("HS256 token signed with the %s public key: err = %v, want ErrKeyAlgorithm", key.Algorithm, err)
+			}
+		}
+	}
+}
+
+func TestKeyfuncLegacyKey(t *testing.T) {
+	legacy := Legacy("legacy-secret")
+	signed, err := legacy.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks := testKeySet(t, EdDSA)
+	ks.Keys = append(ks.Keys, Legacy("legacy-secret").Keys[0])
+	if _, err := jwt.Parse(signed, ks.Keyfunc); err != nil {
+		t.Errorf("token without kid rejected while the legacy key is accepted: %v", err)
+	}
+
+	ks.Keys[len(ks.Keys)-1].notAfter = time.Now().Add(-time.Second)
+	if _, err := jwt.Parse(signed, ks.Keyfunc); !errors.Is(keyError(err), ErrKeyExpired) {
+		t.Errorf("token of an expired legacy key: err = %v, want ErrKeyExpired", err)
+	}
+}
+
+func TestLoadLegacyUntil(t *testing.T) {
+	path := t.TempDir() + "/keyset.json"
+	if err := testKeySet(t, HS256).WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		until  time.Time
+		legacy bool
+	}{
+		{"zero", time.Time{}, false},
+		{"past", time.Now().Add(-time.Hour), false},
+		{"future", time.Now().Add(time.Hour), true},
+	}
+	for _, test := range tests {
+		ks, err := Load(path, "legacy-secret", test.until)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		_, err = ks.Key("")
+		if got := err == nil; got != test.legacy {
+			t.Errorf("%s: legacy key loaded = %v, want %v", test.name, got, test.legacy)
+		}
+	}
+}
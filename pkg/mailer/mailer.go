@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional emails such as password reset links.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	_ Mailer = &smtpMailer{}
+	_ Mailer = &outboxMailer{}
+)
+
+type smtpMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends mail through an SMTP relay. Authentication is skipped
+// when username is empty, e.g. for a local relay.
+func NewSMTPMailer(host string, port string, username string, password string, from string) *smtpMailer {
+	mailer := &smtpMailer{
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+	if username != "" {
+		mailer.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer
+}
+
+func (sm *smtpMailer) Send(ctx context.Context, msg Message) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- smtp.SendMail(sm.addr, sm.auth, sm.from, []string{msg.To}, formatMessage(sm.from, msg))
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type outboxMailer struct {
+	mu   sync.Mutex
+	from string
+	w    io.Writer
+}
+
+// NewOutboxMailer writes every message to w instead of delivering it. It is
+// meant for local development and tests, where w is stdout or a file.
+func NewOutboxMailer(w io.Writer, from string) *outboxMailer {
+	return &outboxMailer{
+		from: from,
+		w:    w,
+	}
+}
+
+func (om *outboxMailer) Send(ctx context.Context, msg Message) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	_, err := fmt.Fprintf(om.w, "%s\r\n.\r\n", formatMessage(om.from, msg))
+	return err
+}
+
+func formatMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -2,15 +2,18 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"net/http"
-	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
-	"github.com/jim-nnamdi/jinx/pkg/model"
+	"github.com/jim-nnamdi/jinx/pkg/jwtkeys"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"github.com/justinas/alice"
 	"go.uber.org/zap"
 )
 
@@ -26,60 +29,12 @@ func NewSessionMiddleware(logger *zap.Logger, mysqlclient mysql.Database) *Sessi
 	}
 }
 
-type response struct {
-	Data    interface{} `json:"data,omitempty"`
-	Err     string      `json:"err,omitempty"`
-	Success bool        `json:"success"`
-	TTL     int         `json:"ttl"`
-}
-
-func GetSuccessResponse(data interface{}, ttl int) []byte {
-	resp := &response{
-		Success: true,
-		TTL:     ttl,
-	}
-
-	if data == nil || (reflect.ValueOf(data).Kind() == reflect.Ptr && reflect.ValueOf(data).IsNil()) {
-		resp.Data = nil
-	} else {
-		resp.Data = data
-	}
-	responseBytes, _ := json.Marshal(resp)
-	return responseBytes
-}
-
-func (smw *SessionMiddleware) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var sessionkey = r.FormValue("session_key")
-		var sessionresp = map[string]interface{}{}
-		uInfo := &model.User{}
-		getBySession, err := smw.mysqlclient.GetBySessionKey(r.Context(), sessionkey)
-		if err != nil {
-			sessionresp["err"] = "no session key passed"
-			smw.logger.Debug("cannot fetch user by sessionkey", zap.Any("fetch user error", err))
-			w.Write(GetSuccessResponse(sessionresp["err"], 30))
-			return
-		}
-		uInfo.Username = getBySession.Username
-		uInfo.Degree = getBySession.Degree
-		uInfo.Email = getBySession.Email
-		uInfo.Phone = getBySession.Phone
-		uInfo.SessionKey = getBySession.SessionKey
-		uInfo.Id = getBySession.Id
-		uInfo.ProfilePicture = getBySession.ProfilePicture
-		uInfo.LinkedinProfile = getBySession.LinkedinProfile
-		uInfo.TwitterProfile = getBySession.TwitterProfile
-		ctx := model.NewContext(r.Context(), uInfo)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
 func middlewareResponse(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application-json")
 	w.WriteHeader(statusCode)
 	w.Write([]byte(message))
 }
-func JWTAuthRoutes(next http.Handler, secret string) http.Handler {
+func (smw *SessionMiddleware) JWTAuthRoutes(next http.Handler, keys *jwtkeys.KeySet) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		//fetch token from request
 		AuthToken := r.Header.Get("Authorization")
@@ -88,11 +43,22 @@ func JWTAuthRoutes(next http.Handler, secret string) http.Handler {
 			utils.Logger.Error("no auth token was provided")
 			return
 		}
-		jwToken := strings.Split(AuthToken, " ")[1]
+		authParts := strings.Fields(AuthToken)
+		if len(authParts) == 2 && strings.EqualFold(authParts[0], "Token") {
+			if ctx, ok := smw.personalAccessToken(w, r, authParts[1]); ok {
+				next.ServeHTTP(w, r.WithContext(ctx))
+			}
+			return
+		}
+		if len(authParts) != 2 || !strings.EqualFold(authParts[0], "Bearer") {
+			middlewareResponse(w, "please signIn to access resources", http.StatusUnauthorized)
+			utils.Logger.Error("malformed authorization header")
+			return
+		}
+		jwToken := authParts[1]
 
-		token, err := jwt.Parse(jwToken, func(t *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
+		//the kid header picks the verification key, see jwtkeys.KeySet.Keyfunc
+		token, err := jwt.Parse(jwToken, keys.Keyfunc)
 
 		//check err
 		if err != nil {
@@ -119,20 +85,167 @@ func JWTAuthRoutes(next http.Handler, secret string) http.Handler {
 			return
 		}
 
-		sessionKey, ok := tokenClaims["user"]
-		if !ok {
+		subject, _ := tokenClaims["sub"].(string)
+		userID, err := strconv.Atoi(subject)
+		if err != nil || userID <= 0 {
 			middlewareResponse(w, "please signIn to access resources", http.StatusUnauthorized)
 			utils.Logger.Error("user is not Authorized")
 			return
 		}
 
-		//store token(user.sessionKey)
-		ctx := context.WithValue(r.Context(), utils.UserIDKey, sessionKey)
+		ctx := r.Context()
+		//accounts that must enroll in two factor auth can only do that
+		if enroll, _ := tokenClaims[utils.EnrollmentClaim].(bool); enroll {
+			if !strings.HasPrefix(r.URL.Path, "/auth/2fa/") {
+				middlewareResponse(w, "please set up two factor authentication to continue", http.StatusForbidden)
+				utils.Logger.Debug("enrollment token used outside two factor setup", zap.String("path", r.URL.Path))
+				return
+			}
+			ctx = context.WithValue(ctx, utils.EnrollmentKey, true)
+		}
+		//every token, enrollment ones included, belongs to a session that can be revoked
+		sessionID, _ := tokenClaims["sid"].(string)
+		session, err := smw.mysqlclient.GetSession(ctx, sessionID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			middlewareResponse(w, "unable to verify session, please try again", http.StatusInternalServerError)
+			smw.logger.Error("err fetching session", zap.Error(err))
+			return
+		}
+		if err != nil || session.RevokedAt != nil || session.UserID != userID || time.Now().After(session.ExpiresAt) {
+			middlewareResponse(w, "session has ended, please sign in", http.StatusUnauthorized)
+			smw.logger.Debug("token for ended session presented", zap.String("sid", sessionID))
+			return
+		}
+		if err := smw.mysqlclient.TouchSession(ctx, sessionID, utils.ClientIP(r)); err != nil {
+			smw.logger.Error("err updating session activity", zap.Error(err))
+		}
+		ctx = context.WithValue(ctx, utils.SessionIDKey, sessionID)
+		//tokens issued before revocation support carry no jti
+		if jti, ok := tokenClaims["jti"].(string); ok && jti != "" {
+			revoked, err := smw.mysqlclient.IsAccessTokenRevoked(ctx, jti)
+			if err != nil {
+				middlewareResponse(w, "unable to verify session, please try again", http.StatusInternalServerError)
+				smw.logger.Error("err checking token revocation", zap.Error(err))
+				return
+			}
+			if revoked {
+				middlewareResponse(w, "session has ended, please sign in", http.StatusUnauthorized)
+				smw.logger.Warn("revoked token presented", zap.String("jti", jti))
+				return
+			}
+			ctx = context.WithValue(ctx, utils.TokenIDKey, jti)
+		}
+		if exp, ok := tokenClaims["exp"].(float64); ok {
+			ctx = context.WithValue(ctx, utils.TokenExpiryKey, time.Unix(int64(exp), 0))
+		}
+
+		//tokens issued before roles existed carry neither claim
+		role, _ := tokenClaims["role"].(string)
+		ctx = context.WithValue(ctx, utils.RoleKey, role)
+		permissions := []string{}
+		if perms, ok := tokenClaims["perms"].([]interface{}); ok {
+			for _, perm := range perms {
+				if name, ok := perm.(string); ok {
+					permissions = append(permissions, name)
+				}
+			}
+		}
+		ctx = context.WithValue(ctx, utils.PermissionsKey, permissions)
+
+		ctx = context.WithValue(ctx, utils.UserIDKey, userID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// auth routes
-func AuthRoute(next http.Handler) http.Handler {
-	return JWTAuthRoutes(next, utils.MYSTIC)
+// personalAccessToken authenticates the "Authorization: Token" scheme. It
+// writes the error response itself and reports false if the token is refused.
+func (smw *SessionMiddleware) personalAccessToken(w http.ResponseWriter, r *http.Request, token string) (context.Context, bool) {
+	pat, err := smw.mysqlclient.GetPersonalAccessToken(r.Context(), utils.HashToken(token))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		middlewareResponse(w, "unable to verify token, please try again", http.StatusInternalServerError)
+		smw.logger.Error("err fetching personal access token", zap.Error(err))
+		return nil, false
+	}
+	if err != nil || pat.RevokedAt != nil || (pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt)) {
+		middlewareResponse(w, "invalid or expired access token", http.StatusUnauthorized)
+		smw.logger.Debug("invalid personal access token presented")
+		return nil, false
+	}
+	if err := smw.mysqlclient.TouchPersonalAccessToken(r.Context(), pat.Id, utils.ClientIP(r)); err != nil {
+		smw.logger.Error("err updating personal access token usage", zap.Error(err))
+	}
+	//tokens act with their scopes only, never with the role's permissions
+	ctx := context.WithValue(r.Context(), utils.UserIDKey, pat.UserID)
+	ctx = context.WithValue(ctx, utils.PermissionsKey, []string{})
+	ctx = context.WithValue(ctx, utils.ScopesKey, pat.Scopes)
+	return ctx, true
+}
+
+// auth routes, personal access tokens are refused
+func (smw *SessionMiddleware) AuthRoute(next http.Handler) http.Handler {
+	return smw.JWTAuthRoutes(smw.requireScope("", next), utils.JWTKeys)
+}
+
+// RequireScope authenticates like AuthRoute, but also accepts personal access
+// tokens that carry scope. Use it in place of AuthRoute, e.g.
+// alice.New(smw.RequireScope(model.ScopeWriteForums)).
+func (smw *SessionMiddleware) RequireScope(scope string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return smw.JWTAuthRoutes(smw.requireScope(scope, next), utils.JWTKeys)
+	}
+}
+
+// OptionalScope is RequireScope for pages anyone may see. Requests without an
+// Authorization header go through signed out, the handler decides what to
+// show them.
+func (smw *SessionMiddleware) OptionalScope(scope string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		authed := smw.JWTAuthRoutes(smw.requireScope(scope, next), utils.JWTKeys)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authed.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (smw *SessionMiddleware) requireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scopes, ok := r.Context().Value(utils.ScopesKey).([]string)
+		if !ok {
+			//signed in users have every scope
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, granted := range scopes {
+			if scope != "" && granted == scope {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if scope == "" {
+			middlewareResponse(w, "personal access tokens cannot be used here", http.StatusForbidden)
+		} else {
+			middlewareResponse(w, "access token is missing the "+scope+" scope", http.StatusForbidden)
+		}
+		smw.logger.Debug("personal access token scope denied", zap.String("scope", scope), zap.String("path", r.URL.Path))
+	})
+}
+
+// RequirePermission only lets requests through whose access token grants
+// permission. It has to run after AuthRoute, e.g.
+// authRoute.Append(smw.RequirePermission(model.PermissionManageRoles)).
+func (smw *SessionMiddleware) RequirePermission(permission string) alice.Constructor {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !utils.HasPermission(r.Context(), permission) {
+				middlewareResponse(w, "you do not have permission to access this resource", http.StatusForbidden)
+				smw.logger.Warn("permission denied", zap.String("permission", permission), zap.String("path", r.URL.Path))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// scopes a personal access token can be limited to
+const (
+	ScopeReadForums      = "read:forums"
+	ScopeWriteForums     = "write:forums"
+	ScopeWriteGroups     = "write:groups"
+	ScopeReadChats       = "read:chats"
+	ScopeWriteChats      = "write:chats"
+	ScopeReadProfile     = "read:profile"
+	ScopeReadConnections = "read:connections"
+	ScopeReadDirectory   = "read:directory"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{
+	ScopeReadForums,
+	ScopeWriteForums,
+	ScopeWriteGroups,
+	ScopeReadChats,
+	ScopeWriteChats,
+	ScopeReadProfile,
+	ScopeReadConnections,
+	ScopeReadDirectory,
+}
+
+// PersonalAccessToken lets integrations act for a user within its scopes
+// without the user's password.
+type PersonalAccessToken struct {
+	Id         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"` // start of the token, to tell tokens apart
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
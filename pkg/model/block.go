@@ -0,0 +1,10 @@
+package model
+
+import "time"
+
+// RestrictedUser is an entry of a user's block or mute list.
+type RestrictedUser struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
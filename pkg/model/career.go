@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Experience is a job in a user's work history. Months are written YYYY-MM,
+// an empty End means the user still works there.
+type Experience struct {
+	Id          int       `json:"id"`
+	UserID      int       `json:"-"`
+	Company     string    `json:"company"`
+	Title       string    `json:"title"`
+	Location    string    `json:"location,omitempty"`
+	Start       string    `json:"start"`
+	End         string    `json:"end,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Education is a course of study, at LASU or elsewhere. Years are written
+// YYYY and may be left empty.
+type Education struct {
+	Id           int       `json:"id"`
+	UserID       int       `json:"-"`
+	Institution  string    `json:"institution"`
+	Degree       string    `json:"degree,omitempty"`
+	FieldOfStudy string    `json:"field_of_study,omitempty"`
+	StartYear    string    `json:"start_year,omitempty"`
+	EndYear      string    `json:"end_year,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Career is a user's work history, education and skills, as shown on
+// profiles.
+type Career struct {
+	Experiences []Experience
+	Educations  []Education
+	Skills      []UserSkill
+}
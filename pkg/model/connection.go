@@ -1,13 +1,30 @@
 package model
 
+import "time"
+
+const (
+	ConnectionPending   = "pending"
+	ConnectionAccepted  = "accepted"
+	ConnectionRejected  = "rejected"
+	ConnectionWithdrawn = "withdrawn"
+)
+
 type ConnectionRequest struct {
-	Id        string `json:"connectionid"` /* relative connection id */
-	Requester string `json:"requester"`    /* session key for request initiator */
-	Accepter  string `json:"accepter"`     /* session key for request receiver */
+	Id                int       `json:"connectionid"`       /* relative connection id */
+	RequesterID       int       `json:"requester_id"`       /* user who initiated the request */
+	RequesterUsername string    `json:"requester_username"` /* username of the initiator */
+	RecipientID       int       `json:"recipient_id"`       /* user who received the request */
+	RecipientUsername string    `json:"recipient_username"` /* username of the receiver */
+	Status            string    `json:"status"`             /* pending, accepted, rejected or withdrawn */
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 type Connection struct {
-	ConnectionRequestId string   `json:"connection_request_id"` /* connection initialized */
-	ConnectionExpired   bool     `json:"connection_expired"`    /* did connection expire */
-	ConnectedUsers      []string `json:"connected_users"`       /* which users are involved */
+	ConnectionRequestId int       `json:"connection_request_id"` /* accepted request backing the connection */
+	UserID              int       `json:"user_id"`               /* the other user in the connection */
+	Username            string    `json:"username"`
+	Degree              string    `json:"degree"`
+	GradYear            string    `json:"grad_year"`
+	ConnectedAt         time.Time `json:"connected_at"` /* when the request was accepted */
 }
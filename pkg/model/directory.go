@@ -0,0 +1,44 @@
+package model
+
+// orders directory search results can be sorted in
+const (
+	DirectorySortName     = "name"      // username, A to Z
+	DirectorySortGradYear = "grad_year" // most recent graduates first
+	DirectorySortRecent   = "recent"    // newest members first
+)
+
+// DirectorySearch filters the alumni directory. Empty fields do not filter.
+type DirectorySearch struct {
+	NamePrefix   string
+	Degree       string
+	GradYearFrom string
+	GradYearTo   string
+	JobKeyword   string   // matched anywhere in current_job
+	Company      string   // matched anywhere in a work history company
+	Institution  string   // matched anywhere in an education institution
+	Skills       []string // catalogue keys, users must list every one
+	Sort         string
+	After        *DirectoryCursor // continue after this entry
+	Limit        int
+	ViewerID     int // who is searching, for privacy settings
+}
+
+// DirectoryCursor marks the last entry of a page of results, so the next
+// page starts after it even when members join in between.
+type DirectoryCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"` // value of the sort column, unused for recent
+	Id   int    `json:"i"`
+}
+
+// DirectoryEntry is what members see of each other in search results.
+// CurrentJob is empty where the privacy settings hide it from the viewer.
+type DirectoryEntry struct {
+	Id              int    `json:"id"`
+	Username        string `json:"username"`
+	Degree          string `json:"degree"`
+	GradYear        string `json:"grad_year"`
+	CurrentJob      string `json:"current_job"`
+	ProfilePicture  string `json:"profile_picture,omitempty"`
+	VerifiedAlumnus bool   `json:"verified_alumnus"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// UserExport is everything the platform holds about a user, returned by the
+// personal data export.
+type UserExport struct {
+	ForumPosts    []Forum
+	Comments      []ExportedComment
+	Chats         []Chat
+	GroupMessages []ExportedGroupMessage
+	Transactions  []Transaction
+	Connections   []Connection
+	Experiences   []Experience
+	Educations    []Education
+	Skills        []UserSkill
+	Blocks        []RestrictedUser
+	Mutes         []RestrictedUser
+	Follows       []ExportedFollow
+}
+
+type ExportedComment struct {
+	ID        int       `json:"id"`
+	ForumSlug string    `json:"forum_slug"`
+	ParentID  *int      `json:"parent_id,omitempty"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportedGroupMessage struct {
+	ID        int       `json:"id"`
+	GroupID   int       `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// follow statuses, follows of users who approve their followers start out
+// pending
+const (
+	FollowPending  = "pending"
+	FollowAccepted = "accepted"
+)
+
+// FollowStats is what profiles show about a user's follows. ViewerStatus is
+// the status of the viewer's follow of the user, empty if they don't follow.
+type FollowStats struct {
+	Followers       int
+	Following       int
+	RequireApproval bool
+	ViewerStatus    string
+}
+
+// FollowEntry is a user in a follower, following or follow request list.
+// Since is when the follow was accepted, or requested while pending.
+type FollowEntry struct {
+	UserID         int       `json:"user_id"`
+	Username       string    `json:"username"`
+	ProfilePicture string    `json:"profile_picture,omitempty"`
+	Since          time.Time `json:"since"`
+}
+
+// FollowCursor marks the last entry of a page of a follow list.
+type FollowCursor struct {
+	Since  time.Time `json:"s"`
+	UserID int       `json:"i"`
+}
+
+// ExportedFollow is a follow in the personal data export, in either
+// direction.
+type ExportedFollow struct {
+	FollowerID int       `json:"follower_id"`
+	FolloweeID int       `json:"followee_id"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
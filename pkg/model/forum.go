@@ -2,7 +2,23 @@ package model
 
 import "time"
 
+// Forum is a forum post as stored. Author is the author's email, used to
+// check ownership and never serialized, show posts as ForumPost.
 type Forum struct {
+	Id             int        `json:"id"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	Author         string     `json:"-"`
+	AuthorUsername string     `json:"-"`
+	Slug           string     `json:"slug"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ForumPost is what readers see of a forum post. The author is named by
+// username, their email is only shown as their privacy settings allow.
+type ForumPost struct {
 	Id          int       `json:"id"`
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
@@ -12,9 +28,70 @@ type Forum struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+func NewForumPost(forum *Forum) ForumPost {
+	return ForumPost{
+		Id:          forum.Id,
+		Title:       forum.Title,
+		Description: forum.Description,
+		Author:      forum.AuthorUsername,
+		Slug:        forum.Slug,
+		CreatedAt:   forum.CreatedAt,
+		UpdatedAt:   forum.UpdatedAt,
+	}
+}
+
+// MaxCommentDepth is how deeply replies nest. Top level comments have depth
+// 0 and replies to comments at MaxCommentDepth are refused.
+const MaxCommentDepth = 5
+
+// Comment is a comment on a forum post. Replies holds the first of its
+// replies when comments are shown as a tree. MoreReplies tells whether the
+// rest need loading, starting at RepliesCursor if set.
 type Comment struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	ParentID      *int      `json:"parent_id"`
+	Depth         int       `json:"depth"`
+	Username      string    `json:"username"`
+	Comment       string    `json:"comment"`
+	CreatedAt     time.Time `json:"created_at"`
+	ReplyCount    int       `json:"reply_count"`
+	Replies       []Comment `json:"replies,omitempty"`
+	MoreReplies   bool      `json:"more_replies"`
+	RepliesCursor string    `json:"replies_cursor,omitempty"`
+}
+
+// CommentParent is the comment a reply answers, with what replying needs to
+// know about it.
+type CommentParent struct {
+	ID        int
+	UserID    int
+	ForumID   int
+	ForumSlug string
+	Depth     int
+}
+
+// CommentCursor marks the last reply of a page of replies.
+type CommentCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        int       `json:"i"`
+}
+
+// forum revision actions
+const (
+	ForumRevisionEdit   = "edit"
+	ForumRevisionDelete = "delete"
+)
+
+// ForumRevision records a post as it was before an edit or delete. The
+// diffs mark what the edit changed, see textdiff.Words.
+type ForumRevision struct {
+	ID              int       `json:"id"`
+	EditorID        int       `json:"editor_id"`
+	Editor          string    `json:"editor"`
+	Action          string    `json:"action"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	TitleDiff       string    `json:"title_diff,omitempty"`
+	DescriptionDiff string    `json:"description_diff,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
 }
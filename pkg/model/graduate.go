@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Graduate is a record of the graduate registry, imported from the
+// university's records. A user claims it to become a verified alumnus.
+type Graduate struct {
+	Id           int        `json:"id"`
+	MatricNumber string     `json:"matric_number"`
+	FullName     string     `json:"full_name"`
+	Faculty      string     `json:"faculty"`
+	Degree       string     `json:"degree"`
+	GradYear     string     `json:"grad_year"`
+	ClaimedBy    *int       `json:"-"`
+	ClaimedAt    *time.Time `json:"claimed_at"`
+}
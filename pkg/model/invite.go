@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// InviteCode lets a limited number of people register while registration is
+// invite only.
+type InviteCode struct {
+	Id        int        `json:"id"`
+	Prefix    string     `json:"prefix"` // start of the code, to tell codes apart
+	Note      string     `json:"note"`
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedBy *int       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// LoginAttempt tracks consecutive failed sign ins for an email, whether or not
+// an account exists for it.
+type LoginAttempt struct {
+	Email         string     `json:"email"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
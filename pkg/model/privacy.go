@@ -0,0 +1,73 @@
+package model
+
+// who can see a profile field
+const (
+	VisibilityPublic      = "public"      // anyone, signed in or not
+	VisibilityConnections = "connections" // the user's accepted connections
+	VisibilityPrivate     = "private"     // only the user
+)
+
+// profile fields with a privacy setting
+const (
+	PrivacyEmail      = "email"
+	PrivacyPhone      = "phone"
+	PrivacyCurrentJob = "current_job"
+	PrivacyLinkedin   = "linkedin_profile"
+	PrivacyTwitter    = "twitter_profile"
+	PrivacyExperience = "experience" // work history
+	PrivacyEducation  = "education"
+)
+
+// PrivacyDefaults is the visibility of each field until the user changes it.
+var PrivacyDefaults = map[string]string{
+	PrivacyEmail:      VisibilityConnections,
+	PrivacyPhone:      VisibilityPrivate,
+	PrivacyCurrentJob: VisibilityPublic,
+	PrivacyLinkedin:   VisibilityPublic,
+	PrivacyTwitter:    VisibilityPublic,
+	PrivacyExperience: VisibilityPublic,
+	PrivacyEducation:  VisibilityPublic,
+}
+
+// Viewer is how the person looking at a profile relates to its owner.
+type Viewer int
+
+const (
+	ViewerAnonymous Viewer = iota
+	ViewerMember
+	ViewerConnection
+	ViewerSelf
+)
+
+// PrivacySettings maps fields to their visibility. Fields without an entry
+// use PrivacyDefaults.
+type PrivacySettings map[string]string
+
+// Visibility returns who can see field.
+func (settings PrivacySettings) Visibility(field string) string {
+	if visibility, ok := settings[field]; ok {
+		return visibility
+	}
+	return PrivacyDefaults[field]
+}
+
+// Visible reports whether viewer may see field. Unknown fields are private.
+func (settings PrivacySettings) Visible(field string, viewer Viewer) bool {
+	switch settings.Visibility(field) {
+	case VisibilityPublic:
+		return true
+	case VisibilityConnections:
+		return viewer >= ViewerConnection
+	default:
+		return viewer == ViewerSelf
+	}
+}
+
+// All returns the visibility of every field, defaults included.
+func (settings PrivacySettings) All() map[string]string {
+	all := map[string]string{}
+	for field := range PrivacyDefaults {
+		all[field] = settings.Visibility(field)
+	}
+	return all
+}
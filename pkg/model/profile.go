@@ -0,0 +1,120 @@
+package model
+
+import "time"
+
+// OwnProfile is what users see of their own account.
+type OwnProfile struct {
+	Id                int               `json:"id"`
+	Username          string            `json:"username"`
+	Email             string            `json:"email"`
+	PendingEmail      string            `json:"pending_email,omitempty"`
+	Phone             string            `json:"phone"`
+	Degree            string            `json:"degree"`
+	GradYear          string            `json:"grad_year"`
+	CurrentJob        string            `json:"current_job"`
+	ProfilePicture    string            `json:"profile_picture"`
+	LinkedinProfile   string            `json:"linkedin_profile"`
+	TwitterProfile    string            `json:"twitter_profile"`
+	EmailVerified     bool              `json:"email_verified"`
+	Role              string            `json:"role"`
+	VerifiedAlumnus   bool              `json:"verified_alumnus"`
+	DeletionScheduled *time.Time        `json:"deletion_scheduled_at,omitempty"`
+	Experiences       []Experience      `json:"experiences"`
+	Educations        []Education       `json:"educations"`
+	Skills            []UserSkill       `json:"skills"`
+	Privacy           map[string]string `json:"privacy"`
+	Followers         int               `json:"followers"`
+	Following         int               `json:"following"`
+	FollowApproval    bool              `json:"follow_approval"`
+}
+
+func NewOwnProfile(user *User, settings PrivacySettings, career *Career, follows *FollowStats) *OwnProfile {
+	return &OwnProfile{
+		Id:                user.Id,
+		Username:          user.Username,
+		Email:             user.Email,
+		PendingEmail:      user.PendingEmail,
+		Phone:             user.Phone,
+		Degree:            user.Degree,
+		GradYear:          user.GradYear,
+		CurrentJob:        user.CurrentJob,
+		ProfilePicture:    user.ProfilePicture,
+		LinkedinProfile:   user.LinkedinProfile,
+		TwitterProfile:    user.TwitterProfile,
+		EmailVerified:     user.EmailVerifiedAt != nil,
+		Role:              user.Role,
+		VerifiedAlumnus:   user.AlumniVerifiedAt != nil,
+		DeletionScheduled: user.DeletionScheduled,
+		Experiences:       career.Experiences,
+		Educations:        career.Educations,
+		Skills:            career.Skills,
+		Privacy:           settings.All(),
+		Followers:         follows.Followers,
+		Following:         follows.Following,
+		FollowApproval:    follows.RequireApproval,
+	}
+}
+
+// PublicProfile is what others see of a user. Fields the viewer may not see
+// are left empty and omitted.
+type PublicProfile struct {
+	Id              int          `json:"id"`
+	Username        string       `json:"username"`
+	Degree          string       `json:"degree"`
+	GradYear        string       `json:"grad_year"`
+	ProfilePicture  string       `json:"profile_picture,omitempty"`
+	VerifiedAlumnus bool         `json:"verified_alumnus"`
+	Email           string       `json:"email,omitempty"`
+	Phone           string       `json:"phone,omitempty"`
+	CurrentJob      string       `json:"current_job,omitempty"`
+	LinkedinProfile string       `json:"linkedin_profile,omitempty"`
+	TwitterProfile  string       `json:"twitter_profile,omitempty"`
+	Experiences     []Experience `json:"experiences,omitempty"`
+	Educations      []Education  `json:"educations,omitempty"`
+	Skills          []UserSkill  `json:"skills"`
+	Connected       bool         `json:"connected"`
+	Followers       int          `json:"followers"`
+	Following       int          `json:"following"`
+	FollowStatus    string       `json:"follow_status,omitempty"` // of the viewer's follow
+	MemberSince     time.Time    `json:"member_since"`
+}
+
+func NewPublicProfile(user *User, settings PrivacySettings, career *Career, follows *FollowStats, viewer Viewer) *PublicProfile {
+	profile := &PublicProfile{
+		Id:              user.Id,
+		Username:        user.Username,
+		Degree:          user.Degree,
+		GradYear:        user.GradYear,
+		ProfilePicture:  user.ProfilePicture,
+		VerifiedAlumnus: user.AlumniVerifiedAt != nil,
+		Skills:          career.Skills,
+		Connected:       viewer == ViewerConnection,
+		Followers:       follows.Followers,
+		Following:       follows.Following,
+		FollowStatus:    follows.ViewerStatus,
+		MemberSince:     user.CreatedAt,
+	}
+	fields := []struct {
+		name  string
+		dst   *string
+		value string
+	}{
+		{PrivacyEmail, &profile.Email, user.Email},
+		{PrivacyPhone, &profile.Phone, user.Phone},
+		{PrivacyCurrentJob, &profile.CurrentJob, user.CurrentJob},
+		{PrivacyLinkedin, &profile.LinkedinProfile, user.LinkedinProfile},
+		{PrivacyTwitter, &profile.TwitterProfile, user.TwitterProfile},
+	}
+	for _, field := range fields {
+		if settings.Visible(field.name, viewer) {
+			*field.dst = field.value
+		}
+	}
+	if settings.Visible(PrivacyExperience, viewer) {
+		profile.Experiences = career.Experiences
+	}
+	if settings.Visible(PrivacyEducation, viewer) {
+		profile.Educations = career.Educations
+	}
+	return profile
+}
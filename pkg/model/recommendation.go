@@ -0,0 +1,23 @@
+package model
+
+// RecommendationSignals is what a user has in common with another member,
+// the input to ranking "people you may know".
+type RecommendationSignals struct {
+	CandidateID       int
+	Degree            string // the candidate's degree
+	GradYear          string // the candidate's graduation year
+	SharedGroups      int
+	SharedForums      int // forums both have commented on
+	MutualConnections int
+}
+
+// Recommendation is a ranked member in a user's "people you may know". The
+// counts say why they were suggested.
+type Recommendation struct {
+	DirectoryEntry
+	Score             float64 `json:"-"`
+	MutualConnections int     `json:"mutual_connections"`
+	SharedGroups      int     `json:"shared_groups"`
+	SharedForums      int     `json:"shared_forums"`
+	SameDegree        bool    `json:"same_degree"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// built in roles, see aln.sql
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// permissions checked by middleware.RequirePermission
+const (
+	PermissionModerateForums = "forums.moderate"
+	PermissionManageGroups   = "groups.manage"
+	PermissionManageUsers    = "users.manage"
+	PermissionManageRoles    = "roles.manage"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Require2FA  bool     `json:"require_2fa"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+)
+
+var skillPattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} .+#/&'-]{0,49}$`)
+
+// Skill is an entry of the skills catalogue. Users counts the people who
+// list it, autocomplete suggests popular skills first.
+type Skill struct {
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Users int    `json:"users"`
+}
+
+// UserSkill is a skill on a user's profile.
+type UserSkill struct {
+	SkillID          int    `json:"skill_id"`
+	Name             string `json:"name"`
+	Endorsements     int    `json:"endorsements"`
+	EndorsedByViewer bool   `json:"endorsed_by_you"`
+}
+
+// NormalizeSkill tidies the spacing of a skill name and returns it with the
+// catalogue key, so "Go", " go " and "GO" are the same skill. ok is false for
+// names that are empty, too long or contain unexpected characters.
+func NormalizeSkill(name string) (display string, key string, ok bool) {
+	display = strings.Join(strings.Fields(name), " ")
+	if !skillPattern.MatchString(display) {
+		return "", "", false
+	}
+	return display, strings.ToLower(display), true
+}
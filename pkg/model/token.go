@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	Id        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`          // sha256 of the token handed to the client
+	SessionID string     `json:"session_id"` // all rotations of one login share a session
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`    // set once the token has been rotated
+	RevokedAt *time.Time `json:"revoked_at"` // set on logout or reuse detection
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is one signed in device. Revoking it signs the device out: its
+// refresh tokens stop working and its access tokens are refused.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"` // pushed back on every refresh
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type PasswordReset struct {
+	Id        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type TwoFactor struct {
+	UserID       int        `json:"user_id"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at"` // nil until the first code is confirmed
+	LastUsedStep int64      `json:"-"`          // totp step of the last accepted code
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -8,18 +8,27 @@ import (
 type User struct {
 	Id              int       `json:"id"`
 	Username        string    `json:"username"`
-	Password        string    `json:"password"`
+	Password        string    `json:"-"`
 	Email           string    `json:"email"`
+	PendingEmail    string    `json:"pending_email,omitempty"` // new email waiting for verification
 	Degree          string    `json:"degree"`
 	GradYear        string    `json:"grad_year"`
 	CurrentJob      string    `json:"currentjob"`
 	Phone           string    `json:"phone"`
-	SessionKey      string    `json:"session_key"`
 	ProfilePicture  string    `json:"profilepicture,omitempty"`
 	LinkedinProfile string    `json:"linkedinprofile"`
 	TwitterProfile  string    `json:"twitterprofile"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+
+	EmailVerifiedAt    *time.Time `json:"email_verified_at"` // nil until the email link is followed
+	VerificationSentAt *time.Time `json:"-"`                 // last verification email, for throttling
+	MFARequired        bool       `json:"mfa_required"`      // set by admins, forces two factor enrollment
+	Role               string     `json:"role"`
+	Permissions        []string   `json:"permissions,omitempty"` // permissions of Role, loaded when tokens are issued
+	AlumniVerifiedAt   *time.Time `json:"alumni_verified_at"`    // set once a graduate registry record is claimed
+	DeletionScheduled  *time.Time `json:"deletion_scheduled_at"` // when a requested account deletion goes through
+	DeletedAt          *time.Time `json:"deleted_at"`            // set once the account is anonymized
 }
 
 // key is an unexported type for keys defined in this package.
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowLimit(t *testing.T) {
+	sw := NewSlidingWindow(3, time.Minute)
+	for i := 0; i < 3; i++ {
+		if ok, _ := sw.Allow("ip"); !ok {
+			t.Fatalf("hit %d refused within the limit", i+1)
+		}
+	}
+	ok, retry := sw.Allow("ip")
+	if ok {
+		t.Fatal("hit over the limit allowed")
+	}
+	if retry <= 0 || retry > time.Minute {
+		t.Errorf("retry after %v, want within (0, 1m]", retry)
+	}
+	if ok, _ := sw.Allow("other"); !ok {
+		t.Error("hit for another key refused")
+	}
+}
+
+func TestSlidingWindowRefusedHitsNotCounted(t *testing.T) {
+	sw := NewSlidingWindow(1, 50*time.Millisecond)
+	if ok, _ := sw.Allow("ip"); !ok {
+		t.Fatal("first hit refused")
+	}
+	for i := 0; i < 5; i++ {
+		sw.Allow("ip")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := sw.Allow("ip"); !ok {
+		t.Error("hit refused after the window passed, refused hits must not extend it")
+	}
+}
+
+func TestSlidingWindowSlides(t *testing.T) {
+	sw := NewSlidingWindow(2, 300*time.Millisecond)
+	sw.Allow("ip")
+	time.Sleep(150 * time.Millisecond)
+	sw.Allow("ip")
+	if ok, _ := sw.Allow("ip"); ok {
+		t.Fatal("third hit within the window allowed")
+	}
+	// the first hit leaves the window, the second is still in it
+	time.Sleep(170 * time.Millisecond)
+	if ok, _ := sw.Allow("ip"); !ok {
+		t.Fatal("hit refused after the oldest left the window")
+	}
+	if ok, _ := sw.Allow("ip"); ok {
+		t.Error("hit allowed while the window is full again")
+	}
+}
+
+func TestSlidingWindowSweep(t *testing.T) {
+	sw := NewSlidingWindow(1, 20*time.Millisecond)
+	sw.Allow("a")
+	sw.Allow("b")
+	time.Sleep(30 * time.Millisecond)
+	sw.Allow("c")
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if _, ok := sw.hits["a"]; ok {
+		t.Error("idle key a kept after sweep")
+	}
+	if _, ok := sw.hits["b"]; ok {
+		t.Error("idle key b kept after sweep")
+	}
+	if len(sw.hits["c"]) != 1 {
+		t.Errorf("key c has %d hits, want 1", len(sw.hits["c"]))
+	}
+}
+
+func TestSlidingWindowConcurrent(t *testing.T) {
+	sw := NewSlidingWindow(50, time.Minute)
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := sw.Allow("ip"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 50 {
+		t.Errorf("%d hits allowed, want 50", allowed)
+	}
+}
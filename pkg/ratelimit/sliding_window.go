@@ -0,0 +1,69 @@
+// Package ratelimit holds in-memory request limiters. State is per process,
+// so limits apply to each server instance separately.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// SlidingWindow allows at most limit hits per key within any window long
+// period.
+type SlidingWindow struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{
+		limit:     limit,
+		window:    window,
+		hits:      map[string][]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow records a hit for key if it is within the limit. Otherwise it
+// returns false and how long until the oldest hit leaves the window.
+func (sw *SlidingWindow) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if now.Sub(sw.lastSweep) > sw.window {
+		sw.sweep(now)
+	}
+	hits := prune(sw.hits[key], now.Add(-sw.window))
+	if len(hits) >= sw.limit {
+		sw.hits[key] = hits
+		return false, hits[0].Add(sw.window).Sub(now)
+	}
+	sw.hits[key] = append(hits, now)
+	return true, 0
+}
+
+// sweep drops keys without hits in the window so idle clients don't pile up.
+func (sw *SlidingWindow) sweep(now time.Time) {
+	cutoff := now.Add(-sw.window)
+	for key, hits := range sw.hits {
+		if hits = prune(hits, cutoff); len(hits) == 0 {
+			delete(sw.hits, key)
+		} else {
+			sw.hits[key] = hits
+		}
+	}
+	sw.lastSweep = now
+}
+
+// prune drops the hits at or before cutoff, hits are in ascending order.
+func prune(hits []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
@@ -0,0 +1,192 @@
+// Package recommend ranks "people you may know". Results are cached in the
+// database and recomputed in the background once they get old, so reading
+// them costs one indexed query.
+package recommend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/model"
+	"go.uber.org/zap"
+)
+
+// MaxResults is how many recommendations are kept per user.
+const MaxResults = 50
+
+// CohortYears is how many years apart members with the same degree may have
+// graduated and still be suggested to each other.
+const CohortYears = 2
+
+// weights of each signal. Counts stop adding up after maxCounted, so one busy
+// group or forum does not outweigh everything else.
+const (
+	mutualConnectionWeight = 3.0
+	sharedGroupWeight      = 2.0
+	sharedForumWeight      = 1.0
+	sameDegreeWeight       = 4.0
+	gradYearWeight         = 1.0 // per year closer than CohortYears+1
+	maxCounted             = 10
+)
+
+// refreshBatch is how many users RefreshStale recomputes per query.
+const refreshBatch = 100
+
+// Score rates a candidate for user, higher is a better match.
+func Score(user *model.User, signals model.RecommendationSignals) float64 {
+	score := mutualConnectionWeight*counted(signals.MutualConnections) +
+		sharedGroupWeight*counted(signals.SharedGroups) +
+		sharedForumWeight*counted(signals.SharedForums)
+	if sameDegree(user, signals) {
+		score += sameDegreeWeight
+	}
+	if gap, ok := yearGap(user.GradYear, signals.GradYear); ok && gap <= CohortYears {
+		score += gradYearWeight * float64(CohortYears+1-gap)
+	}
+	return score
+}
+
+// Rank scores the candidates and returns the best limit of them.
+func Rank(user *model.User, candidates []model.RecommendationSignals, limit int) []model.Recommendation {
+	ranked := make([]model.Recommendation, 0, len(candidates))
+	for _, candidate := range candidates {
+		score := Score(user, candidate)
+		if score <= 0 {
+			continue
+		}
+		ranked = append(ranked, model.Recommendation{
+			DirectoryEntry:    model.DirectoryEntry{Id: candidate.CandidateID},
+			Score:             score,
+			MutualConnections: candidate.MutualConnections,
+			SharedGroups:      candidate.SharedGroups,
+			SharedForums:      candidate.SharedForums,
+			SameDegree:        sameDegree(user, candidate),
+		})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Id < ranked[j].Id
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+func counted(n int) float64 {
+	if n > maxCounted {
+		return maxCounted
+	}
+	return float64(n)
+}
+
+func sameDegree(user *model.User, signals model.RecommendationSignals) bool {
+	return strings.TrimSpace(user.Degree) != "" && strings.EqualFold(strings.TrimSpace(user.Degree), strings.TrimSpace(signals.Degree))
+}
+
+func yearGap(a string, b string) (int, bool) {
+	x, err := strconv.Atoi(a)
+	if err != nil {
+		return 0, false
+	}
+	y, err := strconv.Atoi(b)
+	if err != nil {
+		return 0, false
+	}
+	if x > y {
+		return x - y, true
+	}
+	return y - x, true
+}
+
+// Recommender computes, caches and serves recommendations.
+type Recommender struct {
+	logger      *zap.Logger
+	mysqlclient mysql.Database
+	maxAge      time.Duration
+}
+
+// NewRecommender serves cached recommendations for up to maxAge before
+// RefreshStale recomputes them.
+func NewRecommender(logger *zap.Logger, mysqlclient mysql.Database, maxAge time.Duration) *Recommender {
+	return &Recommender{
+		logger:      logger,
+		mysqlclient: mysqlclient,
+		maxAge:      maxAge,
+	}
+}
+
+// Recompute ranks the candidates for user and replaces the cached results.
+func (rec *Recommender) Recompute(ctx context.Context, user *model.User) error {
+	from, to := "0000", "9999"
+	if year, err := strconv.Atoi(user.GradYear); err == nil {
+		from, to = fmt.Sprintf("%04d", year-CohortYears), fmt.Sprintf("%04d", year+CohortYears)
+	}
+	candidates, err := rec.mysqlclient.GetRecommendationSignals(ctx, user.Id, strings.TrimSpace(user.Degree), from, to)
+	if err != nil {
+		return err
+	}
+	return rec.mysqlclient.SaveRecommendations(ctx, user.Id, Rank(user, candidates, MaxResults))
+}
+
+// Get returns up to limit recommendations for user, best first. They are
+// computed on the user's first request and served from the cache after.
+func (rec *Recommender) Get(ctx context.Context, user *model.User, limit int) ([]model.Recommendation, error) {
+	computedAt, err := rec.mysqlclient.GetRecommendationsAt(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if computedAt == nil {
+		if err := rec.Recompute(ctx, user); err != nil {
+			return nil, err
+		}
+	}
+	return rec.mysqlclient.GetRecommendations(ctx, user.Id, limit)
+}
+
+// RefreshStale recomputes the recommendations older than maxAge. A user that
+// fails is logged and skipped so one bad account doesn't hold up the rest,
+// their recommendations stay stale and are retried by the next run.
+func (rec *Recommender) RefreshStale(ctx context.Context) {
+	before := time.Now().Add(-rec.maxAge)
+	afterID := 0
+	for {
+		userIDs, err := rec.mysqlclient.GetStaleRecommendationUserIDs(ctx, before, afterID, refreshBatch)
+		if err != nil {
+			rec.logger.Error("err fetching stale recommendations", zap.Error(err))
+			return
+		}
+		for _, userID := range userIDs {
+			if ctx.Err() != nil {
+				return
+			}
+			user, err := rec.mysqlclient.GetUserByID(ctx, userID)
+			if err == nil {
+				err = rec.Recompute(ctx, user)
+			}
+			if err != nil {
+				rec.logger.Error("err recomputing recommendations, skipping user", zap.Int("user_id", userID), zap.Error(err))
+			}
+		}
+		if len(userIDs) < refreshBatch {
+			return
+		}
+		afterID = userIDs[len(userIDs)-1]
+	}
+}
+
+// Run calls RefreshStale every interval for the life of the process.
+func (rec *Recommender) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		rec.RefreshStale(context.Background())
+	}
+}
@@ -0,0 +1,141 @@
+// Package registry reads the graduate registry and matches registrants to
+// its records.
+package registry
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jim-nnamdi/jinx/pkg/model"
+)
+
+// Columns are the header names a registry CSV must have, in any order.
+var Columns = []string{"matric_number", "name", "faculty", "degree", "year"}
+
+// RowError reports a CSV row that could not be imported.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+// ParseCSV reads graduates from a CSV file with a header row. Rows that fail
+// validation are skipped and returned as *RowError, a malformed file or
+// header fails the whole read.
+func ParseCSV(r io.Reader) ([]model.Graduate, []error, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, column := range Columns {
+		if _, ok := index[column]; !ok {
+			return nil, nil, fmt.Errorf("missing column %s, expected %s", column, strings.Join(Columns, ","))
+		}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	var (
+		graduates []model.Graduate
+		rowErrs   []error
+		seen      = map[string]int{}
+	)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+				rowErrs = append(rowErrs, &RowError{Line: line, Err: parseErr.Err})
+				continue
+			}
+			return nil, nil, err
+		}
+		graduate := model.Graduate{
+			MatricNumber: NormalizeMatric(record[index["matric_number"]]),
+			FullName:     strings.Join(strings.Fields(record[index["name"]]), " "),
+			Faculty:      strings.TrimSpace(record[index["faculty"]]),
+			Degree:       strings.TrimSpace(record[index["degree"]]),
+			GradYear:     strings.TrimSpace(record[index["year"]]),
+		}
+		if err := validate(graduate); err != nil {
+			rowErrs = append(rowErrs, &RowError{Line: line, Err: err})
+			continue
+		}
+		if first, ok := seen[graduate.MatricNumber]; ok {
+			rowErrs = append(rowErrs, &RowError{Line: line, Err: fmt.Errorf("matric number %s repeats line %d", graduate.MatricNumber, first)})
+			continue
+		}
+		seen[graduate.MatricNumber] = line
+		graduates = append(graduates, graduate)
+	}
+	return graduates, rowErrs, nil
+}
+
+func validate(graduate model.Graduate) error {
+	switch {
+	case graduate.MatricNumber == "" || len(graduate.MatricNumber) > 32:
+		return errors.New("matric number must be between 1 and 32 characters")
+	case graduate.FullName == "":
+		return errors.New("name is empty")
+	case graduate.Faculty == "":
+		return errors.New("faculty is empty")
+	case graduate.Degree == "":
+		return errors.New("degree is empty")
+	}
+	if _, err := strconv.Atoi(graduate.GradYear); err != nil || len(graduate.GradYear) != 4 {
+		return fmt.Errorf("invalid graduation year %q", graduate.GradYear)
+	}
+	return nil
+}
+
+// NormalizeMatric uppercases a matric number and drops spaces, so numbers
+// typed by users compare equal to the registry's.
+func NormalizeMatric(matric string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(matric), ""))
+}
+
+// NameMatches reports whether a name given by a registrant matches the
+// registry name. Order, case and punctuation are ignored and middle names may
+// be left out, but at least two names (or the whole registry name, if it has
+// fewer) must be given and every one must appear in the registry name.
+func NameMatches(registryName, given string) bool {
+	want := nameParts(registryName)
+	got := nameParts(given)
+	if len(got) == 0 || len(got) < len(want) && len(got) < 2 {
+		return false
+	}
+	available := map[string]int{}
+	for _, part := range want {
+		available[part]++
+	}
+	for _, part := range got {
+		if available[part] == 0 {
+			return false
+		}
+		available[part]--
+	}
+	return true
+}
+
+func nameParts(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
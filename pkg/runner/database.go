@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	database "github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+)
+
+// DatabaseConfig holds the MySQL connection settings shared by the server and
+// the admin commands.
+type DatabaseConfig struct {
+	MySQLDatabaseHost     string
+	MySQLDatabasePort     string
+	MySQLDatabaseUser     string
+	MySQLDatabasePassword string
+	MySQLDatabaseName     string
+}
+
+// Open connects to MySQL, retrying a few times while the server comes up, and
+// prepares the database client.
+func (cfg *DatabaseConfig) Open() (database.Database, error) {
+	var (
+		mysqlDbInstance *sql.DB
+		err             error
+	)
+	databaseConfig := &mysql.Config{
+		User:                 cfg.MySQLDatabaseUser,
+		Passwd:               cfg.MySQLDatabasePassword,
+		Net:                  "tcp",
+		Addr:                 fmt.Sprintf("%s:%s", cfg.MySQLDatabaseHost, cfg.MySQLDatabasePort),
+		DBName:               cfg.MySQLDatabaseName,
+		AllowNativePasswords: true,
+		ParseTime:            true,
+	}
+
+	const maxRetries = 3
+	const retryDelay = 2 * time.Second
+
+	for i := 0; i < maxRetries; i++ {
+		if mysqlDbInstance, err = sql.Open("mysql", databaseConfig.FormatDSN()); err == nil {
+			if err = mysqlDbInstance.Ping(); err == nil {
+				// Successfully connected
+				break
+			}
+		}
+		log.Printf("Failed to connect to MySQL database, attempt %d: %v", i+1, err)
+		time.Sleep(retryDelay)
+	}
+
+	if err != nil {
+		utils.Logger.Info("err connecting to database after multiple tries")
+		return nil, fmt.Errorf("unable to open connection to MySQL Server: %s", err.Error())
+	}
+
+	mysqlDatabaseClient, err := database.NewMySQLDatabase(mysqlDbInstance)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create MySQL database client: %s", err.Error())
+	}
+	utils.Logger.Info("connected to database successfully")
+	return mysqlDatabaseClient, nil
+}
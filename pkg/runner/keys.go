@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/jim-nnamdi/jinx/pkg/jwtkeys"
+	"github.com/urfave/cli/v2"
+)
+
+// KeysRunner manages the jwt keyset file. Servers read it at start up, so a
+// rotation is: generate a key, restart every server so they can verify it,
+// rotate to it, restart again, and retire the old key once the tokens it
+// signed have expired.
+type KeysRunner struct {
+	File      string
+	Algorithm string
+	KeyID     string
+}
+
+// readKeySet returns the keyset in the file, or an empty one if the file does
+// not exist yet.
+func (runner *KeysRunner) readKeySet() (*jwtkeys.KeySet, error) {
+	ks, err := jwtkeys.ReadFile(runner.File)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &jwtkeys.KeySet{}, nil
+	}
+	return ks, err
+}
+
+func (runner *KeysRunner) List(c *cli.Context) error {
+	ks, err := runner.readKeySet()
+	if err != nil {
+		return err
+	}
+	for _, key := range ks.Keys {
+		status := "verify"
+		if key.ID == ks.SigningKeyID {
+			status = "sign"
+		}
+		fmt.Fprintf(c.App.Writer, "%s\t%s\t%s\t%s\n", key.ID, key.Algorithm, status, key.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// Generate adds a verification key. It only becomes the signing key if the
+// keyset had none.
+func (runner *KeysRunner) Generate(c *cli.Context) error {
+	ks, err := runner.readKeySet()
+	if err != nil {
+		return err
+	}
+	key, err := jwtkeys.Generate(runner.Algorithm)
+	if err != nil {
+		return err
+	}
+	ks.Add(key)
+	if ks.SigningKeyID == "" {
+		ks.SigningKeyID = key.ID
+	}
+	if err := ks.WriteFile(runner.File); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.App.Writer, "generated %s key %s\n", key.Algorithm, key.ID)
+	return nil
+}
+
+// Rotate makes --kid the signing key, or generates a new key and signs with
+// it straight away when no kid is given.
+func (runner *KeysRunner) Rotate(c *cli.Context) error {
+	ks, err := runner.readKeySet()
+	if err != nil {
+		return err
+	}
+	kid := runner.KeyID
+	if kid == "" {
+		key, err := jwtkeys.Generate(runner.Algorithm)
+		if err != nil {
+			return err
+		}
+		ks.Add(key)
+		kid = key.ID
+	}
+	previous := ks.SigningKeyID
+	if err := ks.SetSigningKey(kid); err != nil {
+		return err
+	}
+	if err := ks.WriteFile(runner.File); err != nil {
+		return err
+	}
+	if previous == "" || previous == kid {
+		fmt.Fprintf(c.App.Writer, "signing with key %s\n", kid)
+		return nil
+	}
+	fmt.Fprintf(c.App.Writer, "signing key rotated from %s to %s, %s still verifies existing tokens\n", previous, kid, previous)
+	return nil
+}
+
+func (runner *KeysRunner) Retire(c *cli.Context) error {
+	ks, err := runner.readKeySet()
+	if err != nil {
+		return err
+	}
+	if err := ks.Retire(runner.KeyID); err != nil {
+		return err
+	}
+	if err := ks.WriteFile(runner.File); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.App.Writer, "retired key %s\n", runner.KeyID)
+	return nil
+}
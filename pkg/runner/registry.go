@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jim-nnamdi/jinx/pkg/registry"
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// RegistryRunner maintains the graduate registry alumni are verified against.
+type RegistryRunner struct {
+	DatabaseConfig
+
+	File   string
+	DryRun bool
+}
+
+// Import loads graduates from a CSV export of the university's records.
+// Existing records are updated by matric number, invalid rows are reported
+// and skipped.
+func (runner *RegistryRunner) Import(c *cli.Context) error {
+	file, err := os.Open(runner.File)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", runner.File, err.Error())
+	}
+	defer file.Close()
+
+	graduates, rowErrs, err := registry.ParseCSV(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", runner.File, err.Error())
+	}
+	for _, rowErr := range rowErrs {
+		utils.Logger.Warn(fmt.Sprintf("skipping %s", rowErr.Error()))
+	}
+	if runner.DryRun {
+		utils.Logger.Info(fmt.Sprintf("%d graduates would be imported, %d rows skipped", len(graduates), len(rowErrs)))
+		return nil
+	}
+
+	mysqlDatabaseClient, err := runner.DatabaseConfig.Open()
+	if err != nil {
+		return err
+	}
+	defer mysqlDatabaseClient.Close()
+
+	changed, err := mysqlDatabaseClient.ImportGraduates(c.Context, graduates)
+	if err != nil {
+		return fmt.Errorf("unable to import graduates: %s", err.Error())
+	}
+	utils.Logger.Info(fmt.Sprintf("%d graduates read, %d added or updated, %d rows skipped", len(graduates), changed, len(rowErrs)))
+	return nil
+}
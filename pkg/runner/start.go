@@ -1,15 +1,23 @@
 package runner
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"log"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/avatar"
+	"github.com/jim-nnamdi/jinx/pkg/blobstore"
 	database "github.com/jim-nnamdi/jinx/pkg/database/mysql"
 	"github.com/jim-nnamdi/jinx/pkg/handlers"
+	"github.com/jim-nnamdi/jinx/pkg/jwtkeys"
+	"github.com/jim-nnamdi/jinx/pkg/mailer"
+	"github.com/jim-nnamdi/jinx/pkg/middleware"
+	"github.com/jim-nnamdi/jinx/pkg/ratelimit"
+	"github.com/jim-nnamdi/jinx/pkg/recommend"
 	"github.com/jim-nnamdi/jinx/pkg/server"
+	"github.com/jim-nnamdi/jinx/pkg/slug"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"github.com/urfave/cli/v2"
 	"go.uber.org/zap"
@@ -23,11 +31,24 @@ type StartRunner struct {
 	LogggingLevel          string
 	ErrorLoggingOutputPath string
 
-	MySQLDatabaseHost     string
-	MySQLDatabasePort     string
-	MySQLDatabaseUser     string
-	MySQLDatabasePassword string
-	MySQLDatabaseName     string
+	DatabaseConfig
+
+	JWTKeysFile    string        // keyset written by the keys command, empty to sign with the secret
+	JWTLegacyUntil cli.Timestamp // when a keyset is used, how long tokens signed with the secret still verify
+
+	RegistrationDomains cli.StringSlice
+	BlockedEmailDomains cli.StringSlice
+
+	MediaDir string // where uploads are stored
+	MediaURL string // base url uploads are served from
+
+	AppURL       string // base url used in links sent by email
+	MailFrom     string
+	MailOutbox   string // file the outbox mailer writes to, "-" for stdout
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
 }
 
 func (runner *StartRunner) Run(c *cli.Context) error {
@@ -35,8 +56,9 @@ func (runner *StartRunner) Run(c *cli.Context) error {
 		loggerConfig        = zap.NewDevelopmentConfig()
 		logger              *zap.Logger
 		err                 error
-		mysqlDbInstance     *sql.DB
 		mysqlDatabaseClient database.Database
+		mailClient          mailer.Mailer
+		mediaStore          blobstore.BlobStore
 	)
 	if runner.LoggingProduction {
 		loggerConfig = zap.NewProductionConfig()
@@ -53,55 +75,238 @@ func (runner *StartRunner) Run(c *cli.Context) error {
 	}
 
 	logger.Sync()
-	databaseConfig := &mysql.Config{
-		User:                 runner.MySQLDatabaseUser,
-		Passwd:               runner.MySQLDatabasePassword,
-		Net:                  "tcp",
-		Addr:                 fmt.Sprintf("%s:%s", runner.MySQLDatabaseHost, runner.MySQLDatabasePort),
-		DBName:               runner.MySQLDatabaseName,
-		AllowNativePasswords: true,
-		ParseTime:            true,
-	}
-
-	const maxRetries = 3
-	const retryDelay = 2 * time.Second
-
-	for i := 0; i < maxRetries; i++ {
-		if mysqlDbInstance, err = sql.Open("mysql", databaseConfig.FormatDSN()); err == nil {
-			if err = mysqlDbInstance.Ping(); err == nil {
-				// Successfully connected
-				break
-			}
+	switch utils.UnverifiedPolicy {
+	case utils.UnverifiedAllow, utils.UnverifiedRestrict, utils.UnverifiedReject:
+	default:
+		return fmt.Errorf("unknown unverified account policy %q", utils.UnverifiedPolicy)
+	}
+	utils.RegistrationDomains = normalizeDomains(runner.RegistrationDomains.Value())
+	utils.BlockedEmailDomains = normalizeDomains(runner.BlockedEmailDomains.Value())
+	switch utils.RegistrationPolicy {
+	case utils.RegistrationOpen, utils.RegistrationInvite:
+	case utils.RegistrationDomain:
+		if len(utils.RegistrationDomains) == 0 {
+			return fmt.Errorf("the domain registration policy needs --registration-domains")
 		}
-		log.Printf("Failed to connect to MySQL database, attempt %d: %v", i+1, err)
-		time.Sleep(retryDelay)
+	default:
+		return fmt.Errorf("unknown registration policy %q", utils.RegistrationPolicy)
 	}
-
-	if err != nil {
-		utils.Logger.Info("err connecting to database after multiple tries")
-		return fmt.Errorf("unable to open connection to MySQL Server: %s", err.Error())
+	// email links get their own secret so the access token keys can be
+	// rotated, and a leaked link secret can't sign access tokens
+	if runner.JWTKeysFile == "" && utils.MYSTIC == "" {
+		return fmt.Errorf("a secret is required, set --secret or AC_JWT_SECRET, or use a keyset with --jwt-keys")
 	}
-
-	if mysqlDatabaseClient, err = database.NewMySQLDatabase(mysqlDbInstance); err != nil {
-		return fmt.Errorf("unable to create MySQL database client: %s", err.Error())
+	if utils.LinkSecret == "" {
+		return fmt.Errorf("a link secret is required, set --link-secret or AC_LINK_SECRET")
+	}
+	if utils.LinkSecret == utils.MYSTIC {
+		return fmt.Errorf("the link secret must differ from the jwt secret")
+	}
+	var legacyUntil time.Time
+	if until := runner.JWTLegacyUntil.Value(); until != nil {
+		legacyUntil = *until
+	}
+	if utils.JWTKeys, err = jwtkeys.Load(runner.JWTKeysFile, utils.MYSTIC, legacyUntil); err != nil {
+		return fmt.Errorf("unable to load jwt keys: %s", err.Error())
+	}
+	if mysqlDatabaseClient, err = runner.DatabaseConfig.Open(); err != nil {
+		return err
+	}
+	if mailClient, err = runner.newMailer(); err != nil {
+		return fmt.Errorf("unable to create mailer: %s", err.Error())
 	}
-	utils.Logger.Info("connected to database successfully")
+	localStore, err := blobstore.NewLocalStore(runner.MediaDir, runner.MediaURL)
+	if err != nil {
+		return fmt.Errorf("unable to create media store: %s", err.Error())
+	}
+	mediaStore = localStore
+	go purgeExpiredTokens(logger, mysqlDatabaseClient, mediaStore)
+	slugs := slug.NewGenerator(mysqlDatabaseClient)
+	recommender := recommend.NewRecommender(logger, mysqlDatabaseClient, utils.RecommendationsMaxAge)
+	go recommender.Run(time.Hour)
+	loginLimiter := ratelimit.NewSlidingWindow(utils.LoginIPLimit, utils.LoginIPWindow)
 	server := &server.GracefulShutdownServer{
 		HTTPListenAddr:     runner.ListenAddr,
-		RegisterHandler:    handlers.NewRegisterHandler(logger, mysqlDatabaseClient),
-		LoginHandler:       handlers.NewLoginHandler(logger, mysqlDatabaseClient),
+		SessionMiddleware:  middleware.NewSessionMiddleware(logger, mysqlDatabaseClient),
+		RegisterHandler:    handlers.NewRegisterHandler(logger, mysqlDatabaseClient, mailClient, runner.AppURL),
+		LoginHandler:       handlers.NewLoginHandler(logger, mysqlDatabaseClient, loginLimiter),
 		ProfileHandler:     handlers.NewProfileHandler(logger, mysqlDatabaseClient),
 		HomeHandler:        handlers.NewHomeHandler(),
-		AddForumHandler:    handlers.NewForumStruct(logger, mysqlDatabaseClient),
+		MediaHandler:       localStore.Handler(),
+		AddForumHandler:    handlers.NewForumStruct(logger, mysqlDatabaseClient, slugs),
 		AllForumHandler:    handlers.NewAForumStruct(logger, mysqlDatabaseClient),
 		SingleForumHandler: handlers.NewSForumStruct(logger, mysqlDatabaseClient),
+		UpdateForumHandler: handlers.NewUpdateForumHandler(logger, mysqlDatabaseClient, slugs),
+		DeleteForumHandler: handlers.NewDeleteForumHandler(logger, mysqlDatabaseClient),
+		ForumRevisions:     handlers.NewForumRevisionsHandler(logger, mysqlDatabaseClient),
 		ChatHandler:        handlers.NewChat(logger, mysqlDatabaseClient),
 		CommentHandler:     handlers.NewCommentHandler(logger, mysqlDatabaseClient),
+		ReplyHandler:       handlers.NewReplyHandler(logger, mysqlDatabaseClient, mailClient, runner.AppURL),
+		RepliesHandler:     handlers.NewCommentRepliesHandler(logger, mysqlDatabaseClient),
 		CreateGroup:        handlers.NewCreateGroupHandler(logger, mysqlDatabaseClient),
 		AddUserToGroup:     handlers.NewAddGroupMemberHandler(logger, mysqlDatabaseClient),
 		SendGroupMessage:   handlers.NewSendGroupMessageHandler(logger, mysqlDatabaseClient),
 		GetChatHistory:     handlers.NewGetUserChatsHistoryHandler(logger, mysqlDatabaseClient),
+
+		ConnectHandler:            handlers.NewConnectHandler(logger, mysqlDatabaseClient),
+		AcceptConnection:          handlers.NewAcceptHandler(logger, mysqlDatabaseClient),
+		DeclineConnection:         handlers.NewDeclineHandler(logger, mysqlDatabaseClient),
+		WithdrawConnection:        handlers.NewWithdrawHandler(logger, mysqlDatabaseClient),
+		ConnectionRequestsHandler: handlers.NewConnectionRequestsHandler(logger, mysqlDatabaseClient),
+		ConnectionsHandler:        handlers.NewConnectionsHandler(logger, mysqlDatabaseClient),
+
+		SearchUsersHandler:     handlers.NewSearchUsersHandler(logger, mysqlDatabaseClient),
+		RecommendationsHandler: handlers.NewRecommendationsHandler(logger, mysqlDatabaseClient, recommender),
+		PublicProfileHandler:   handlers.NewPublicProfileHandler(logger, mysqlDatabaseClient),
+		UpdatePrivacyHandler:   handlers.NewUpdatePrivacyHandler(logger, mysqlDatabaseClient),
+
+		CreateExperienceHandler: handlers.NewCreateExperienceHandler(logger, mysqlDatabaseClient),
+		UpdateExperienceHandler: handlers.NewUpdateExperienceHandler(logger, mysqlDatabaseClient),
+		DeleteExperienceHandler: handlers.NewDeleteExperienceHandler(logger, mysqlDatabaseClient),
+		CreateEducationHandler:  handlers.NewCreateEducationHandler(logger, mysqlDatabaseClient),
+		UpdateEducationHandler:  handlers.NewUpdateEducationHandler(logger, mysqlDatabaseClient),
+		DeleteEducationHandler:  handlers.NewDeleteEducationHandler(logger, mysqlDatabaseClient),
+
+		SkillSuggestionsHandler:    handlers.NewSkillSuggestionsHandler(logger, mysqlDatabaseClient),
+		AddSkillHandler:            handlers.NewAddSkillHandler(logger, mysqlDatabaseClient),
+		RemoveSkillHandler:         handlers.NewRemoveSkillHandler(logger, mysqlDatabaseClient),
+		EndorseSkillHandler:        handlers.NewEndorseSkillHandler(logger, mysqlDatabaseClient),
+		WithdrawEndorsementHandler: handlers.NewWithdrawEndorsementHandler(logger, mysqlDatabaseClient),
+
+		BlockedUsersHandler: handlers.NewBlockedUsersHandler(logger, mysqlDatabaseClient),
+		BlockUserHandler:    handlers.NewBlockUserHandler(logger, mysqlDatabaseClient),
+		UnblockUserHandler:  handlers.NewUnblockUserHandler(logger, mysqlDatabaseClient),
+		MutedUsersHandler:   handlers.NewMutedUsersHandler(logger, mysqlDatabaseClient),
+		MuteUserHandler:     handlers.NewMuteUserHandler(logger, mysqlDatabaseClient),
+		UnmuteUserHandler:   handlers.NewUnmuteUserHandler(logger, mysqlDatabaseClient),
+
+		FollowHandler:          handlers.NewFollowHandler(logger, mysqlDatabaseClient),
+		UnfollowHandler:        handlers.NewUnfollowHandler(logger, mysqlDatabaseClient),
+		FollowersHandler:       handlers.NewFollowersHandler(logger, mysqlDatabaseClient),
+		FollowingHandler:       handlers.NewFollowingHandler(logger, mysqlDatabaseClient),
+		FollowRequestsHandler:  handlers.NewFollowRequestsHandler(logger, mysqlDatabaseClient),
+		ApproveFollowerHandler: handlers.NewApproveFollowerHandler(logger, mysqlDatabaseClient),
+		RemoveFollowerHandler:  handlers.NewRemoveFollowerHandler(logger, mysqlDatabaseClient),
+		FollowSettingsHandler:  handlers.NewFollowSettingsHandler(logger, mysqlDatabaseClient),
+
+		RefreshHandler: handlers.NewRefreshHandler(logger, mysqlDatabaseClient),
+		LogoutHandler:  handlers.NewLogoutHandler(logger, mysqlDatabaseClient),
+
+		SessionsHandler:            handlers.NewSessionsHandler(logger, mysqlDatabaseClient),
+		RevokeSessionHandler:       handlers.NewRevokeSessionHandler(logger, mysqlDatabaseClient),
+		RevokeOtherSessionsHandler: handlers.NewRevokeOtherSessionsHandler(logger, mysqlDatabaseClient),
+
+		ForgotPasswordHandler: handlers.NewForgotPasswordHandler(logger, mysqlDatabaseClient, mailClient, runner.AppURL),
+		ResetPasswordHandler:  handlers.NewResetPasswordHandler(logger, mysqlDatabaseClient),
+
+		VerifyEmailHandler:        handlers.NewVerifyEmailHandler(logger, mysqlDatabaseClient),
+		ResendVerificationHandler: handlers.NewResendVerificationHandler(logger, mysqlDatabaseClient, mailClient, runner.AppURL),
+
+		TwoFactorEnrollHandler:  handlers.NewTwoFactorEnrollHandler(logger, mysqlDatabaseClient),
+		TwoFactorConfirmHandler: handlers.NewTwoFactorConfirmHandler(logger, mysqlDatabaseClient),
+		TwoFactorDisableHandler: handlers.NewTwoFactorDisableHandler(logger, mysqlDatabaseClient),
+		RecoveryCodesHandler:    handlers.NewRecoveryCodesHandler(logger, mysqlDatabaseClient),
+		TwoFactorVerifyHandler:  handlers.NewTwoFactorVerifyHandler(logger, mysqlDatabaseClient, loginLimiter),
+
+		RolesHandler:       handlers.NewRolesHandler(logger, mysqlDatabaseClient),
+		SetUserRoleHandler: handlers.NewSetUserRoleHandler(logger, mysqlDatabaseClient),
+
+		InvitesHandler:      handlers.NewInvitesHandler(logger, mysqlDatabaseClient),
+		CreateInviteHandler: handlers.NewCreateInviteHandler(logger, mysqlDatabaseClient),
+		RevokeInviteHandler: handlers.NewRevokeInviteHandler(logger, mysqlDatabaseClient),
+
+		AccessTokensHandler:      handlers.NewAccessTokensHandler(logger, mysqlDatabaseClient),
+		CreateAccessTokenHandler: handlers.NewCreateAccessTokenHandler(logger, mysqlDatabaseClient),
+		RevokeAccessTokenHandler: handlers.NewRevokeAccessTokenHandler(logger, mysqlDatabaseClient),
+
+		ClaimAlumniHandler: handlers.NewClaimAlumniHandler(logger, mysqlDatabaseClient),
+
+		UpdateProfileHandler:  handlers.NewUpdateProfileHandler(logger, mysqlDatabaseClient),
+		ChangeEmailHandler:    handlers.NewChangeEmailHandler(logger, mysqlDatabaseClient, mailClient, runner.AppURL),
+		ChangePasswordHandler: handlers.NewChangePasswordHandler(logger, mysqlDatabaseClient),
+
+		UploadPictureHandler: handlers.NewUploadPictureHandler(logger, mysqlDatabaseClient, mediaStore),
+		RemovePictureHandler: handlers.NewRemovePictureHandler(logger, mysqlDatabaseClient, mediaStore),
+
+		ExportHandler:         handlers.NewExportHandler(logger, mysqlDatabaseClient),
+		DeleteAccountHandler:  handlers.NewDeleteAccountHandler(logger, mysqlDatabaseClient),
+		CancelDeletionHandler: handlers.NewCancelDeletionHandler(logger, mysqlDatabaseClient),
 	}
 	server.Start()
 	return nil
 }
+
+// newMailer delivers through SMTP when a host is configured, otherwise mail
+// is written to the outbox file (or stdout) for local development.
+func (runner *StartRunner) newMailer() (mailer.Mailer, error) {
+	if runner.SMTPHost != "" {
+		return mailer.NewSMTPMailer(runner.SMTPHost, runner.SMTPPort, runner.SMTPUser, runner.SMTPPassword, runner.MailFrom), nil
+	}
+	if runner.MailOutbox == "" || runner.MailOutbox == "-" {
+		return mailer.NewOutboxMailer(os.Stdout, runner.MailFrom), nil
+	}
+	outbox, err := os.OpenFile(runner.MailOutbox, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return mailer.NewOutboxMailer(outbox, runner.MailFrom), nil
+}
+
+// purgeExpiredTokens periodically drops expired refresh tokens and denylist
+// entries so the revocation lookups stay small, and forgets old failed sign
+// ins.
+func purgeExpiredTokens(logger *zap.Logger, mysqlDatabaseClient database.Database, mediaStore blobstore.BlobStore) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := mysqlDatabaseClient.PurgeExpiredTokens(context.Background()); err != nil {
+			logger.Error("err purging expired tokens", zap.Error(err))
+		}
+		if err := mysqlDatabaseClient.PurgeLoginAttempts(context.Background(), time.Now().Add(-24*time.Hour)); err != nil {
+			logger.Error("err purging login attempts", zap.Error(err))
+		}
+		purgeDeletedAccounts(logger, mysqlDatabaseClient, mediaStore)
+	}
+}
+
+// purgeDeletedAccounts anonymizes the accounts whose deletion grace period
+// has ended and deletes their uploaded pictures.
+func purgeDeletedAccounts(logger *zap.Logger, mysqlDatabaseClient database.Database, mediaStore blobstore.BlobStore) {
+	userIDs, err := mysqlDatabaseClient.GetAccountsDueForDeletion(context.Background())
+	if err != nil {
+		logger.Error("err fetching accounts due for deletion", zap.Error(err))
+		return
+	}
+	for _, userID := range userIDs {
+		user, err := mysqlDatabaseClient.GetUserByID(context.Background(), userID)
+		if err != nil {
+			logger.Error("err fetching account due for deletion", zap.Int("user_id", userID), zap.Error(err))
+			continue
+		}
+		if err := mysqlDatabaseClient.AnonymizeUser(context.Background(), userID); err != nil {
+			logger.Error("err anonymizing deleted account", zap.Int("user_id", userID), zap.Error(err))
+			continue
+		}
+		if version, ok := avatar.Version(user.ProfilePicture, mediaStore.URL(avatar.Dir(userID))); ok {
+			for _, key := range avatar.Keys(userID, version) {
+				if err := mediaStore.Delete(context.Background(), key); err != nil {
+					logger.Error("err deleting avatar", zap.String("key", key), zap.Error(err))
+				}
+			}
+		}
+		logger.Info("deleted account anonymized", zap.Int("user_id", userID))
+	}
+}
+
+// normalizeDomains lowercases domains and drops empty entries and leading
+// "@" or ".", so "@LASU.edu.ng" and ".lasu.edu.ng" both become lasu.edu.ng.
+func normalizeDomains(domains []string) []string {
+	normalized := []string{}
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "@.")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
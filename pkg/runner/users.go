@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/jim-nnamdi/jinx/pkg/utils"
+	"github.com/urfave/cli/v2"
+)
+
+// UsersRunner runs account administration tasks from the command line.
+type UsersRunner struct {
+	DatabaseConfig
+
+	Email   string
+	Disable bool
+	Role    string
+}
+
+// RequireTwoFactor makes an account set up two factor authentication at its
+// next sign in, or lifts that requirement with --disable.
+func (runner *UsersRunner) RequireTwoFactor(c *cli.Context) error {
+	mysqlDatabaseClient, err := runner.DatabaseConfig.Open()
+	if err != nil {
+		return err
+	}
+	defer mysqlDatabaseClient.Close()
+
+	user, err := mysqlDatabaseClient.GetUserByEmail(c.Context, runner.Email)
+	if err != nil {
+		return fmt.Errorf("unable to find user %s: %s", runner.Email, err.Error())
+	}
+	if err := mysqlDatabaseClient.SetMFARequired(c.Context, user.Id, !runner.Disable); err != nil {
+		return fmt.Errorf("unable to update user %s: %s", runner.Email, err.Error())
+	}
+	// signed in devices must sign in again, and enroll if required
+	if err := mysqlDatabaseClient.RevokeUserSessions(c.Context, user.Id, ""); err != nil {
+		return fmt.Errorf("unable to sign out user %s: %s", runner.Email, err.Error())
+	}
+	utils.Logger.Info(fmt.Sprintf("two factor authentication required for %s: %t", user.Email, !runner.Disable))
+	return nil
+}
+
+// SetRole assigns a role to an account, e.g. to promote the first admin.
+func (runner *UsersRunner) SetRole(c *cli.Context) error {
+	mysqlDatabaseClient, err := runner.DatabaseConfig.Open()
+	if err != nil {
+		return err
+	}
+	defer mysqlDatabaseClient.Close()
+
+	role, err := mysqlDatabaseClient.GetRole(c.Context, runner.Role)
+	if err != nil {
+		return fmt.Errorf("unable to find role %s: %s", runner.Role, err.Error())
+	}
+	user, err := mysqlDatabaseClient.GetUserByEmail(c.Context, runner.Email)
+	if err != nil {
+		return fmt.Errorf("unable to find user %s: %s", runner.Email, err.Error())
+	}
+	if err := mysqlDatabaseClient.SetUserRole(c.Context, user.Id, role.Name); err != nil {
+		return fmt.Errorf("unable to update user %s: %s", runner.Email, err.Error())
+	}
+	// signed in devices carry the old role's permissions
+	if err := mysqlDatabaseClient.RevokeUserSessions(c.Context, user.Id, ""); err != nil {
+		return fmt.Errorf("unable to sign out user %s: %s", runner.Email, err.Error())
+	}
+	utils.Logger.Info(fmt.Sprintf("role of %s changed from %s to %s", user.Email, user.Role, role.Name))
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/jim-nnamdi/jinx/pkg/middleware"
+	"github.com/jim-nnamdi/jinx/pkg/model"
 	"github.com/jim-nnamdi/jinx/pkg/utils"
 	"github.com/justinas/alice"
 	"github.com/rs/cors"
@@ -14,15 +15,29 @@ import (
 )
 
 type GracefulShutdownServer struct {
-	HTTPListenAddr  string
+	HTTPListenAddr string
+
+	SessionMiddleware *middleware.SessionMiddleware // jwt auth for authed routes
+
 	RegisterHandler http.Handler // register
 	LoginHandler    http.Handler // login
 	ProfileHandler  http.Handler // profile
 	HomeHandler     http.Handler
 
+	UpdateProfileHandler  http.Handler // edit profile fields
+	ChangeEmailHandler    http.Handler // start an email change
+	ChangePasswordHandler http.Handler // change password with the current one
+
+	UploadPictureHandler http.Handler // upload a profile picture
+	RemovePictureHandler http.Handler // remove the profile picture
+	MediaHandler         http.Handler // serve uploaded files
+
 	AddForumHandler    http.Handler // add forum post
 	AllForumHandler    http.Handler // get all posts
 	SingleForumHandler http.Handler // get one post
+	UpdateForumHandler http.Handler // edit a post, author or moderator
+	DeleteForumHandler http.Handler
+	ForumRevisions     http.Handler // a post's edit history, moderators only
 	ChatHandler        http.Handler // chat a user
 	CreateGroup        http.Handler
 	AddUserToGroup     http.Handler
@@ -30,6 +45,85 @@ type GracefulShutdownServer struct {
 	GetChatHistory     http.Handler
 
 	CommentHandler http.Handler //make comments
+	ReplyHandler   http.Handler // reply to a comment
+	RepliesHandler http.Handler // load more replies to a comment
+
+	ConnectHandler            http.Handler // send connection request
+	AcceptConnection          http.Handler
+	DeclineConnection         http.Handler
+	WithdrawConnection        http.Handler
+	ConnectionRequestsHandler http.Handler // pending incoming/outgoing requests
+	ConnectionsHandler        http.Handler // my connections
+
+	SearchUsersHandler     http.Handler // alumni directory search
+	RecommendationsHandler http.Handler // people you may know
+	PublicProfileHandler   http.Handler // a user's profile as others see it
+	UpdatePrivacyHandler   http.Handler // choose who sees profile fields
+
+	CreateExperienceHandler http.Handler // add a job to the work history
+	UpdateExperienceHandler http.Handler
+	DeleteExperienceHandler http.Handler
+	CreateEducationHandler  http.Handler // add an education
+	UpdateEducationHandler  http.Handler
+	DeleteEducationHandler  http.Handler
+
+	SkillSuggestionsHandler    http.Handler // autocomplete skill names
+	AddSkillHandler            http.Handler // list a skill on the profile
+	RemoveSkillHandler         http.Handler
+	EndorseSkillHandler        http.Handler // endorse a connection's skill
+	WithdrawEndorsementHandler http.Handler
+
+	BlockedUsersHandler http.Handler // my block list
+	BlockUserHandler    http.Handler
+	UnblockUserHandler  http.Handler
+	MutedUsersHandler   http.Handler // my mute list
+	MuteUserHandler     http.Handler
+	UnmuteUserHandler   http.Handler
+
+	FollowHandler          http.Handler // follow, or ask to follow, a user
+	UnfollowHandler        http.Handler
+	FollowersHandler       http.Handler // a user's followers
+	FollowingHandler       http.Handler // who a user follows
+	FollowRequestsHandler  http.Handler // follows waiting for my approval
+	ApproveFollowerHandler http.Handler
+	RemoveFollowerHandler  http.Handler // decline a request or drop a follower
+	FollowSettingsHandler  http.Handler // choose whether followers need approval
+
+	RefreshHandler http.Handler // rotate refresh token
+	LogoutHandler  http.Handler // revoke session
+
+	SessionsHandler            http.Handler // list signed in devices
+	RevokeSessionHandler       http.Handler // sign out one device
+	RevokeOtherSessionsHandler http.Handler // sign out all other devices
+
+	ForgotPasswordHandler http.Handler // email a password reset link
+	ResetPasswordHandler  http.Handler // set a new password with a reset token
+
+	VerifyEmailHandler        http.Handler // confirm email ownership
+	ResendVerificationHandler http.Handler // resend the verification link
+
+	TwoFactorEnrollHandler  http.Handler // start totp enrollment
+	TwoFactorConfirmHandler http.Handler // finish enrollment with a code
+	TwoFactorDisableHandler http.Handler // turn two factor off
+	RecoveryCodesHandler    http.Handler // regenerate recovery codes
+	TwoFactorVerifyHandler  http.Handler // second login step
+
+	RolesHandler       http.Handler // list roles and permissions
+	SetUserRoleHandler http.Handler // assign a role to a user
+
+	InvitesHandler      http.Handler // list invite codes
+	CreateInviteHandler http.Handler // create an invite code
+	RevokeInviteHandler http.Handler // revoke an invite code
+
+	AccessTokensHandler      http.Handler // list personal access tokens
+	CreateAccessTokenHandler http.Handler // create a personal access token
+	RevokeAccessTokenHandler http.Handler // revoke a personal access token
+
+	ClaimAlumniHandler http.Handler // match the user to a graduate registry record
+
+	ExportHandler         http.Handler // download personal data
+	DeleteAccountHandler  http.Handler // schedule account deletion
+	CancelDeletionHandler http.Handler // keep an account scheduled for deletion
 
 	httpServer     *http.Server
 	WriteTimeout   time.Duration
@@ -49,22 +143,102 @@ func (server *GracefulShutdownServer) getRouter() *mux.Router {
 		AllowCredentials: true,
 	})
 	middleWareChain := alice.New(utils.RequestLogger, cors.Handler)
-	authRoute := alice.New(middleware.AuthRoute)
+	authRoute := alice.New(server.SessionMiddleware.AuthRoute)
+	manageRoles := authRoute.Append(server.SessionMiddleware.RequirePermission(model.PermissionManageRoles))
+	manageUsers := authRoute.Append(server.SessionMiddleware.RequirePermission(model.PermissionManageUsers))
+	moderateForums := authRoute.Append(server.SessionMiddleware.RequirePermission(model.PermissionModerateForums))
 	//authed routes
-	router.Handle("/users/profile", authRoute.ThenFunc(server.ProfileHandler.ServeHTTP)).Methods(http.MethodGet)
-	router.Handle("/users/chat", authRoute.ThenFunc(server.ChatHandler.ServeHTTP)).Methods(http.MethodPost)
-	router.Handle("/users/chat-history", authRoute.ThenFunc(server.GetChatHistory.ServeHTTP)).Methods(http.MethodGet)
-	router.Handle("/forums/create/post", authRoute.ThenFunc(server.AddForumHandler.ServeHTTP)).Methods(http.MethodPost)
-	router.Handle("/forums/comment", authRoute.ThenFunc(server.CommentHandler.ServeHTTP)).Methods(http.MethodPost)
-	router.Handle("/groups/create", authRoute.ThenFunc(server.CreateGroup.ServeHTTP)).Methods(http.MethodPost)
-	router.Handle("/groups/add-member", authRoute.ThenFunc(server.AddUserToGroup.ServeHTTP)).Methods(http.MethodPost)
-	router.Handle("/groups/send-message", authRoute.ThenFunc(server.SendGroupMessage.ServeHTTP)).Methods(http.MethodPost)
+	//routes personal access tokens may use with the matching scope
+	scoped := func(scope string) alice.Chain { return alice.New(server.SessionMiddleware.RequireScope(scope)) }
+	router.Handle("/users/profile", scoped(model.ScopeReadProfile).ThenFunc(server.ProfileHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/chat", scoped(model.ScopeWriteChats).ThenFunc(server.ChatHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/chat-history", scoped(model.ScopeReadChats).ThenFunc(server.GetChatHistory.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/forums/create/post", scoped(model.ScopeWriteForums).ThenFunc(server.AddForumHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/forums/post/{slug}", scoped(model.ScopeWriteForums).ThenFunc(server.UpdateForumHandler.ServeHTTP)).Methods(http.MethodPut)
+	router.Handle("/forums/post/{slug}", scoped(model.ScopeWriteForums).ThenFunc(server.DeleteForumHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/forums/comment", scoped(model.ScopeWriteForums).ThenFunc(server.CommentHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/forums/comments/{id:[0-9]+}/replies", scoped(model.ScopeWriteForums).ThenFunc(server.ReplyHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/groups/create", scoped(model.ScopeWriteGroups).ThenFunc(server.CreateGroup.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/groups/add-member", scoped(model.ScopeWriteGroups).ThenFunc(server.AddUserToGroup.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/groups/send-message", scoped(model.ScopeWriteGroups).ThenFunc(server.SendGroupMessage.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/connections", scoped(model.ScopeReadConnections).ThenFunc(server.ConnectionsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/connections/requests", scoped(model.ScopeReadConnections).ThenFunc(server.ConnectionRequestsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/search", scoped(model.ScopeReadDirectory).ThenFunc(server.SearchUsersHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/{username}/followers", scoped(model.ScopeReadDirectory).ThenFunc(server.FollowersHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/{username}/following", scoped(model.ScopeReadDirectory).ThenFunc(server.FollowingHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/recommendations", scoped(model.ScopeReadDirectory).ThenFunc(server.RecommendationsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/skills", scoped(model.ScopeReadDirectory).ThenFunc(server.SkillSuggestionsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/profile", authRoute.ThenFunc(server.UpdateProfileHandler.ServeHTTP)).Methods(http.MethodPatch)
+	router.Handle("/users/profile/email", authRoute.ThenFunc(server.ChangeEmailHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/password", authRoute.ThenFunc(server.ChangePasswordHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/privacy", authRoute.ThenFunc(server.UpdatePrivacyHandler.ServeHTTP)).Methods(http.MethodPatch)
+	router.Handle("/users/profile/experiences", authRoute.ThenFunc(server.CreateExperienceHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/experiences/{id:[0-9]+}", authRoute.ThenFunc(server.UpdateExperienceHandler.ServeHTTP)).Methods(http.MethodPatch)
+	router.Handle("/users/profile/experiences/{id:[0-9]+}", authRoute.ThenFunc(server.DeleteExperienceHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/profile/educations", authRoute.ThenFunc(server.CreateEducationHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/educations/{id:[0-9]+}", authRoute.ThenFunc(server.UpdateEducationHandler.ServeHTTP)).Methods(http.MethodPatch)
+	router.Handle("/users/profile/educations/{id:[0-9]+}", authRoute.ThenFunc(server.DeleteEducationHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/profile/skills", authRoute.ThenFunc(server.AddSkillHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/skills/{id:[0-9]+}", authRoute.ThenFunc(server.RemoveSkillHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/skills/endorse", authRoute.ThenFunc(server.EndorseSkillHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/skills/endorse/withdraw", authRoute.ThenFunc(server.WithdrawEndorsementHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/picture", authRoute.ThenFunc(server.UploadPictureHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/profile/picture", authRoute.ThenFunc(server.RemovePictureHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/blocks", authRoute.ThenFunc(server.BlockedUsersHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/blocks", authRoute.ThenFunc(server.BlockUserHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/blocks/{id:[0-9]+}", authRoute.ThenFunc(server.UnblockUserHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/mutes", authRoute.ThenFunc(server.MutedUsersHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/mutes", authRoute.ThenFunc(server.MuteUserHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/mutes/{id:[0-9]+}", authRoute.ThenFunc(server.UnmuteUserHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/follows", authRoute.ThenFunc(server.FollowHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/follows/requests", authRoute.ThenFunc(server.FollowRequestsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/follows/{id:[0-9]+}", authRoute.ThenFunc(server.UnfollowHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/followers/{id:[0-9]+}/approve", authRoute.ThenFunc(server.ApproveFollowerHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/followers/{id:[0-9]+}", authRoute.ThenFunc(server.RemoveFollowerHandler.ServeHTTP)).Methods(http.MethodDelete)
+	router.Handle("/users/profile/follows", authRoute.ThenFunc(server.FollowSettingsHandler.ServeHTTP)).Methods(http.MethodPatch)
+	router.Handle("/users/me/export", authRoute.ThenFunc(server.ExportHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/users/me/delete", authRoute.ThenFunc(server.DeleteAccountHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/me/delete/cancel", authRoute.ThenFunc(server.CancelDeletionHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/users/alumni/verify", authRoute.ThenFunc(server.ClaimAlumniHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/connections/request", authRoute.ThenFunc(server.ConnectHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/connections/accept", authRoute.ThenFunc(server.AcceptConnection.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/connections/decline", authRoute.ThenFunc(server.DeclineConnection.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/connections/withdraw", authRoute.ThenFunc(server.WithdrawConnection.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/logout", authRoute.ThenFunc(server.LogoutHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/sessions", authRoute.ThenFunc(server.SessionsHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/auth/sessions/revoke", authRoute.ThenFunc(server.RevokeSessionHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/sessions/revoke-others", authRoute.ThenFunc(server.RevokeOtherSessionsHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/tokens", authRoute.ThenFunc(server.AccessTokensHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/auth/tokens", authRoute.ThenFunc(server.CreateAccessTokenHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/tokens/revoke", authRoute.ThenFunc(server.RevokeAccessTokenHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/2fa/enroll", authRoute.ThenFunc(server.TwoFactorEnrollHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/2fa/confirm", authRoute.ThenFunc(server.TwoFactorConfirmHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/2fa/disable", authRoute.ThenFunc(server.TwoFactorDisableHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/auth/2fa/recovery-codes", authRoute.ThenFunc(server.RecoveryCodesHandler.ServeHTTP)).Methods(http.MethodPost)
+
+	//admin routes
+	router.Handle("/forums/post/{slug}/revisions", moderateForums.ThenFunc(server.ForumRevisions.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/admin/roles", manageRoles.ThenFunc(server.RolesHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/admin/users/role", manageRoles.ThenFunc(server.SetUserRoleHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/admin/invites", manageUsers.ThenFunc(server.InvitesHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/admin/invites", manageUsers.ThenFunc(server.CreateInviteHandler.ServeHTTP)).Methods(http.MethodPost)
+	router.Handle("/admin/invites/revoke", manageUsers.ThenFunc(server.RevokeInviteHandler.ServeHTTP)).Methods(http.MethodPost)
 
 	//no auth routes
 	router.Handle("/forums", server.AllForumHandler).Methods(http.MethodGet)
-	router.Handle("/forums/post/{slug}", server.SingleForumHandler).Methods(http.MethodGet)
+	router.Handle("/forums/post/{slug}", alice.New(server.SessionMiddleware.OptionalScope(model.ScopeReadForums)).ThenFunc(server.SingleForumHandler.ServeHTTP)).Methods(http.MethodGet)
+	router.Handle("/forums/comments/{id:[0-9]+}/replies", alice.New(server.SessionMiddleware.OptionalScope(model.ScopeReadForums)).ThenFunc(server.RepliesHandler.ServeHTTP)).Methods(http.MethodGet)
+	//signing in may reveal more, routes above take precedence over the username
+	router.Handle("/users/{username}", alice.New(server.SessionMiddleware.OptionalScope(model.ScopeReadDirectory)).ThenFunc(server.PublicProfileHandler.ServeHTTP)).Methods(http.MethodGet)
 	router.Handle("/register", server.RegisterHandler).Methods(http.MethodPost)
 	router.Handle("/login", server.LoginHandler).Methods(http.MethodPost)
+	router.Handle("/auth/refresh", server.RefreshHandler).Methods(http.MethodPost)
+	router.Handle("/auth/2fa/verify", server.TwoFactorVerifyHandler).Methods(http.MethodPost)
+	router.Handle("/auth/password/forgot", server.ForgotPasswordHandler).Methods(http.MethodPost)
+	router.Handle("/auth/password/reset", server.ResetPasswordHandler).Methods(http.MethodPost)
+	router.Handle("/auth/verify-email", server.VerifyEmailHandler).Methods(http.MethodGet, http.MethodPost)
+	router.Handle("/auth/verify-email/resend", server.ResendVerificationHandler).Methods(http.MethodPost)
+	router.PathPrefix("/media/").Handler(http.StripPrefix("/media", server.MediaHandler)).Methods(http.MethodGet, http.MethodHead)
 	router.Handle("/", server.HomeHandler)
 	router.Use(middleWareChain.Then) //request logging will be handled here
 	mux.CORSMethodMiddleware(router)
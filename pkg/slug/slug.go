@@ -0,0 +1,110 @@
+// Package slug turns titles into the readable, unique URL segments used for
+// forum posts.
+package slug
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the longest slug Make returns. Collision suffixes go on top.
+const MaxLength = 80
+
+// fallback is the slug of titles with nothing to keep, e.g. only emoji.
+const fallback = "post"
+
+// transliterations spell out the latin letters that have no ASCII base
+// letter to decompose to, after lowercasing. Accented letters such as é or
+// the Yoruba ẹ, ọ and ṣ decompose and lose their marks in Make.
+var transliterations = map[rune]string{
+	'æ': "ae", 'ð': "d", 'đ': "d", 'ħ': "h", 'ı': "i", 'ł': "l", 'ŀ': "l",
+	'ø': "o", 'œ': "oe", 'ß': "ss", 'þ': "th", 'ŧ': "t",
+}
+
+// Make lowercases title, spells out accented letters in ASCII and joins the
+// words with hyphens, dropping everything else. "Café & Networking!" becomes
+// "cafe-and-networking".
+func Make(title string) string {
+	var b strings.Builder
+	separate := false
+	write := func(s string) {
+		if separate && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		separate = false
+		b.WriteString(s)
+	}
+	// decomposing splits accented letters into their base letter and
+	// combining marks, which are then dropped
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			write(string(r))
+		case r == '&':
+			separate = true
+			write("and")
+			separate = true
+		case transliterations[r] != "":
+			write(transliterations[r])
+		case r == '\'' || r == '’' || unicode.Is(unicode.Mn, r):
+			// apostrophes and combining accents don't split words
+		default:
+			separate = true
+		}
+	}
+	slug := b.String()
+	if len(slug) > MaxLength {
+		slug = slug[:MaxLength]
+		if cut := strings.LastIndexByte(slug, '-'); cut > 0 {
+			slug = slug[:cut]
+		}
+	}
+	if slug == "" {
+		return fallback
+	}
+	return slug
+}
+
+// Unique returns base, or base with the lowest numeric suffix from -2 up,
+// whichever is not in taken.
+func Unique(base string, taken []string) string {
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+	slug := base
+	for n := 2; used[slug]; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// Generator picks forum post slugs that no other post uses or used before,
+// so old links keep pointing at the post they were made for.
+type Generator struct {
+	mysqlclient mysql.Database
+}
+
+func NewGenerator(mysqlclient mysql.Database) *Generator {
+	return &Generator{
+		mysqlclient: mysqlclient,
+	}
+}
+
+// ForumSlug returns a slug for title. Pass the id of the post being renamed,
+// whose own current and old slugs are free for it, or 0 for a new post.
+// Posts created at the same time may still race for a slug, see
+// mysql.ErrSlugTaken.
+func (gen *Generator) ForumSlug(ctx context.Context, title string, forumID int) (string, error) {
+	base := Make(title)
+	taken, err := gen.mysqlclient.GetTakenForumSlugs(ctx, base, forumID)
+	if err != nil {
+		return "", err
+	}
+	return Unique(base, taken), nil
+}
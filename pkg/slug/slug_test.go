@@ -0,0 +1,62 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	long := strings.Repeat("alumni ", 20)
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Café & Networking!", "cafe-and-networking"},
+		{"Ọmọ Ọlọ́ọ̀dúà", "omo-oloodua"},
+		{"Ẹ ṣé o", "e-se-o"},
+		{"Straße Œuvre Łódź", "strasse-oeuvre-lodz"},
+		{"Ærø Þing", "aero-thing"},
+		{"Jim's   Alumni -- Meetup 2024", "jims-alumni-meetup-2024"},
+		{"  Hello, World  ", "hello-world"},
+		{"a&b", "a-and-b"},
+		{"İstanbul trip", "istanbul-trip"},
+		{"🎉🎉", "post"},
+		{"", "post"},
+		// cut at the last word that fits
+		{long, strings.TrimSuffix(strings.Repeat("alumni-", 11), "-")},
+		// a single word too long is cut mid word
+		{strings.Repeat("a", 100), strings.Repeat("a", MaxLength)},
+	}
+	for _, test := range tests {
+		if got := Make(test.title); got != test.want {
+			t.Errorf("Make(%q) = %q, want %q", test.title, got, test.want)
+		}
+		if got := Make(test.title); len(got) > MaxLength {
+			t.Errorf("Make(%q) is %d bytes long, want at most %d", test.title, len(got), MaxLength)
+		}
+	}
+}
+
+func TestUnique(t *testing.T) {
+	tests := []struct {
+		base  string
+		taken []string
+		want  string
+	}{
+		{"post", nil, "post"},
+		{"post", []string{"post-2"}, "post"},
+		{"post", []string{"post"}, "post-2"},
+		{"post", []string{"post", "post-2", "post-3"}, "post-4"},
+		// gaps are reused
+		{"post", []string{"post", "post-3"}, "post-2"},
+		// unrelated slugs with the base as prefix don't count
+		{"post", []string{"post", "post-2024", "posts"}, "post-2"},
+		// suffixes go on top of the longest slug Make returns
+		{strings.Repeat("a", MaxLength), []string{strings.Repeat("a", MaxLength)}, strings.Repeat("a", MaxLength) + "-2"},
+	}
+	for _, test := range tests {
+		if got := Unique(test.base, test.taken); got != test.want {
+			t.Errorf("Unique(%q, %q) = %q, want %q", test.base, test.taken, got, test.want)
+		}
+	}
+}
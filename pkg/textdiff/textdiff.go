@@ -0,0 +1,68 @@
+// Package textdiff describes changes between short texts for revision
+// histories.
+package textdiff
+
+import "strings"
+
+// Words returns b with the words changed since a marked like git's word
+// diff: [-removed-] and {+added+}. It returns "" when the words are the
+// same. The cost grows with the product of the word counts, so it is meant
+// for titles and posts rather than documents.
+func Words(a string, b string) string {
+	from, to := strings.Fields(a), strings.Fields(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of from[i:]
+	// and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			switch {
+			case from[i] == to[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	if lcs[0][0] == len(from) && len(from) == len(to) {
+		return ""
+	}
+
+	var (
+		out            []string
+		removed, added []string
+		flush          = func() {
+			if len(removed) > 0 {
+				out = append(out, "[-"+strings.Join(removed, " ")+"-]")
+				removed = nil
+			}
+			if len(added) > 0 {
+				out = append(out, "{+"+strings.Join(added, " ")+"+}")
+				added = nil
+			}
+		}
+	)
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			flush()
+			out = append(out, from[i])
+			i++
+			j++
+		case j == len(to) || (i < len(from) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, from[i])
+			i++
+		default:
+			added = append(added, to[j])
+			j++
+		}
+	}
+	flush()
+	return strings.Join(out, " ")
+}
@@ -0,0 +1,26 @@
+package textdiff
+
+import "testing"
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"same words", "same words", ""},
+		{"same  words", " same words ", ""},
+		{"", "", ""},
+		{"fix teh typo here", "fix the typo here", "fix [-teh-] {+the+} typo here"},
+		{"one two three", "zero one three four", "{+zero+} one [-two-] three {+four+}"},
+		{"", "new words", "{+new words+}"},
+		{"old words", "", "[-old words-]"},
+		{"a b c", "a x y c", "a [-b-] {+x y+} c"},
+		{"reunion on friday", "reunion on saturday evening", "reunion on [-friday-] {+saturday evening+}"},
+		{"a a b", "a b", "a [-a-] b"},
+	}
+	for _, test := range tests {
+		if got := Words(test.a, test.b); got != test.want {
+			t.Errorf("Words(%q, %q) = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
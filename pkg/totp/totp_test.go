@@ -0,0 +1,128 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// base32 of the RFC 6238 SHA1 seed "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC 6238 appendix B vectors are 8 digits, the 6 digit codes are their
+// last 6 digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, test := range rfcVectors {
+		got, err := Code(rfcSecret, Step(time.Unix(test.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code at %d: %v", test.unix, err)
+		}
+		if got != test.code {
+			t.Errorf("Code at %d = %s, want %s", test.unix, got, test.code)
+		}
+	}
+}
+
+func TestValidateRFC6238(t *testing.T) {
+	for _, test := range rfcVectors {
+		now := time.Unix(test.unix, 0)
+		step, ok := Validate(rfcSecret, test.code, now)
+		if !ok {
+			t.Errorf("Validate(%s) at %d rejected a valid code", test.code, test.unix)
+			continue
+		}
+		if step != Step(now) {
+			t.Errorf("Validate(%s) at %d matched step %d, want %d", test.code, test.unix, step, Step(now))
+		}
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	tests := []struct {
+		offset int64
+		ok     bool
+	}{
+		{-2, false},
+		{-1, true},
+		{0, true},
+		{1, true},
+		{2, false},
+	}
+	for _, test := range tests {
+		code, err := Code(rfcSecret, current+test.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		step, ok := Validate(rfcSecret, code, now)
+		if ok != test.ok {
+			t.Errorf("code for step %+d: ok = %v, want %v", test.offset, ok, test.ok)
+			continue
+		}
+		if ok && step != current+test.offset {
+			t.Errorf("code for step %+d matched step %d, want %d", test.offset, step, current+test.offset)
+		}
+	}
+}
+
+// Callers reject codes whose step is not after the last one used. A code
+// presented again, even after the clock moved on a step, must report the
+// same step so the replay is caught.
+func TestValidateReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, ok := Validate(rfcSecret, code, now)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	again, ok := Validate(rfcSecret, code, now.Add(Period))
+	if !ok {
+		t.Fatal("code within the skew window rejected")
+	}
+	if again != first {
+		t.Errorf("replayed code matched step %d, want the used step %d", again, first)
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "abcdef", "94287082"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("Validate(%q) accepted a malformed code", code)
+		}
+	}
+	if _, ok := Validate(rfcSecret, " 287 082 ", now); !ok {
+		t.Error("Validate rejected a code with spaces")
+	}
+	if _, ok := Validate("not base32!", "287082", now); ok {
+		t.Error("Validate accepted a code for an invalid secret")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret is %d characters long, want 32", len(secret))
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+}
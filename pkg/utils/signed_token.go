@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignedToken = errors.New("link is invalid or has expired")
+
+// NewSignedToken returns a tamper proof token carrying subject for the given
+// purpose, e.g. an email verification link. The token is
+// base64(purpose|expiry|subject) followed by an hmac of it.
+func NewSignedToken(purpose string, subject string, expiry time.Duration, secret string) string {
+	payload := fmt.Sprintf("%s|%d|%s", purpose, time.Now().Add(expiry).Unix(), subject)
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + signPayload(encoded, secret)
+}
+
+// VerifySignedToken checks the signature, purpose and expiry of a token made
+// by NewSignedToken and returns its subject.
+func VerifySignedToken(token string, purpose string, secret string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidSignedToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(signPayload(encoded, secret))) {
+		return "", ErrInvalidSignedToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidSignedToken
+	}
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 || parts[0] != purpose {
+		return "", ErrInvalidSignedToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", ErrInvalidSignedToken
+	}
+	return parts[2], nil
+}
+
+func signPayload(payload string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
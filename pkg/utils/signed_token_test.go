@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLinkSecret = "link-secret"
+
+func TestSignedTokenRoundTrip(t *testing.T) {
+	for _, subject := range []string{"42", "ada@example.com", "a|b|c", ""} {
+		token := NewSignedToken("verify_email", subject, time.Hour, testLinkSecret)
+		got, err := VerifySignedToken(token, "verify_email", testLinkSecret)
+		if err != nil {
+			t.Errorf("VerifySignedToken for subject %q: %v", subject, err)
+			continue
+		}
+		if got != subject {
+			t.Errorf("VerifySignedToken = %q, want %q", got, subject)
+		}
+	}
+}
+
+func TestSignedTokenTampered(t *testing.T) {
+	token := NewSignedToken("verify_email", "42", time.Hour, testLinkSecret)
+	encoded, signature, _ := strings.Cut(token, ".")
+
+	// same signature over a payload naming another user
+	forged := base64.RawURLEncoding.EncodeToString([]byte("verify_email|" + payloadExpiry(t, encoded) + "|1"))
+
+	// flip the last character of the signature
+	last := signature[len(signature)-1:]
+	flipped := "A"
+	if last == "A" {
+		flipped = "B"
+	}
+
+	tests := map[string]string{
+		"payload":      forged + "." + signature,
+		"signature":    encoded + "." + signature[:len(signature)-1] + flipped,
+		"no signature": encoded,
+		"empty":        "",
+		"garbage":      "not.a-token",
+	}
+	for name, tampered := range tests {
+		if _, err := VerifySignedToken(tampered, "verify_email", testLinkSecret); !errors.Is(err, ErrInvalidSignedToken) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignedToken", name, err)
+		}
+	}
+}
+
+func TestSignedTokenWrongSecret(t *testing.T) {
+	token := NewSignedToken("verify_email", "42", time.Hour, testLinkSecret)
+	if _, err := VerifySignedToken(token, "verify_email", "other-secret"); !errors.Is(err, ErrInvalidSignedToken) {
+		t.Errorf("err = %v, want ErrInvalidSignedToken", err)
+	}
+}
+
+func TestSignedTokenWrongPurpose(t *testing.T) {
+	token := NewSignedToken("verify_email", "42", time.Hour, testLinkSecret)
+	if _, err := VerifySignedToken(token, "reset_password", testLinkSecret); !errors.Is(err, ErrInvalidSignedToken) {
+		t.Errorf("err = %v, want ErrInvalidSignedToken", err)
+	}
+}
+
+func TestSignedTokenExpired(t *testing.T) {
+	token := NewSignedToken("verify_email", "42", -2*time.Second, testLinkSecret)
+	if _, err := VerifySignedToken(token, "verify_email", testLinkSecret); !errors.Is(err, ErrInvalidSignedToken) {
+		t.Errorf("err = %v, want ErrInvalidSignedToken", err)
+	}
+}
+
+// payloadExpiry returns the expiry field of an encoded token payload.
+func payloadExpiry(t *testing.T, encoded string) string {
+	t.Helper()
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(string(payload), "|", 3)
+	if len(parts) != 3 {
+		t.Fatalf("payload %q has %d parts, want 3", payload, len(parts))
+	}
+	return parts[1]
+}
@@ -2,14 +2,22 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/jim-nnamdi/jinx/pkg/database/mysql"
+	"github.com/jim-nnamdi/jinx/pkg/jwtkeys"
 	"github.com/jim-nnamdi/jinx/pkg/model"
 	"go.uber.org/zap"
 )
@@ -25,26 +33,114 @@ func RequestLogger(next http.Handler) http.Handler {
 
 type mapKey string
 
+// policies for accounts whose email has not been verified yet
 const (
-	UserIDKey mapKey = "user_id"
+	UnverifiedAllow    = "allow"    // treat them like verified accounts
+	UnverifiedRestrict = "restrict" // read only, see AuthenticateVerifiedUser
+	UnverifiedReject   = "reject"   // no access until verified
+)
+
+var ErrEmailNotVerified = errors.New("please verify your email address to continue")
+
+// registration policies, see RegistrationPolicy
+const (
+	RegistrationOpen   = "open"   // anyone may register
+	RegistrationInvite = "invite" // an invite code created by an admin is required
+	RegistrationDomain = "domain" // the email must belong to one of RegistrationDomains
+)
+
+// DisposableEmailDomains is the default BlockedEmailDomains list.
+var DisposableEmailDomains = []string{
+	"10minutemail.com",
+	"guerrillamail.com",
+	"mailinator.com",
+	"maildrop.cc",
+	"sharklasers.com",
+	"temp-mail.org",
+	"tempmail.com",
+	"throwawaymail.com",
+	"trashmail.com",
+	"yopmail.com",
+}
+
+// EnrollmentClaim marks tokens that may only be used to set up two factor
+// authentication, see GenerateEnrollmentToken.
+const EnrollmentClaim = "mfa_enroll"
+
+const (
+	UserIDKey      mapKey = "user_id"      // id of the signed in user
+	SessionIDKey   mapKey = "session_id"   // sid claim of the access token
+	TokenIDKey     mapKey = "token_id"     // jti of the access token used for the request
+	TokenExpiryKey mapKey = "token_expiry" // expiry of the access token used for the request
+	EnrollmentKey  mapKey = "mfa_enroll"   // set when the request used an enrollment token
+	RoleKey        mapKey = "role"         // role claim of the access token
+	PermissionsKey mapKey = "permissions"  // permission claims of the access token
+	ScopesKey      mapKey = "scopes"       // scopes of the personal access token, unset for access tokens
 )
 
 func AuthenticateUser(ctx context.Context, logger *zap.Logger, mysqlclient mysql.Database) (*model.User, error) {
-	sessionKey, ok := ctx.Value(UserIDKey).(string)
-	if !ok || sessionKey == "" {
-		logger.Error("session key is missing")
+	userID, ok := ctx.Value(UserIDKey).(int)
+	if !ok || userID <= 0 {
+		logger.Error("user id is missing")
 		return nil, errors.New("please sign in to access this page")
 	}
 
-	user, err := mysqlclient.GetBySessionKey(ctx, sessionKey)
-	if err != nil {
+	user, err := mysqlclient.GetUserByID(ctx, userID)
+	if err != nil || user.DeletedAt != nil {
 		logger.Error("user is not authorized", zap.Error(err))
 		return nil, errors.New("please sign in to access this page")
 	}
 
+	if user.EmailVerifiedAt == nil && UnverifiedPolicy == UnverifiedReject {
+		logger.Debug("unverified account rejected", zap.Int("user_id", user.Id))
+		return nil, ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+// AuthenticateVerifiedUser is AuthenticateUser for actions that reach other
+// users (posting, messaging, connecting). Unverified accounts are refused
+// unless the policy allows them everything.
+func AuthenticateVerifiedUser(ctx context.Context, logger *zap.Logger, mysqlclient mysql.Database) (*model.User, error) {
+	user, err := AuthenticateUser(ctx, logger, mysqlclient)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt == nil && UnverifiedPolicy != UnverifiedAllow {
+		logger.Debug("unverified account restricted", zap.Int("user_id", user.Id))
+		return nil, ErrEmailNotVerified
+	}
 	return user, nil
 }
 
+// HasPermission reports whether the access token used for the request grants
+// permission.
+func HasPermission(ctx context.Context, permission string) bool {
+	permissions, _ := ctx.Value(PermissionsKey).([]string)
+	for _, granted := range permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is only used
+// when TrustProxyHeaders is set, since clients can send anything in it.
+func ClientIP(r *http.Request) string {
+	if TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func RecoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -65,25 +161,100 @@ func ServerError(w http.ResponseWriter, errMsg string, err error) {
 	http.Error(w, errMsg, http.StatusInternalServerError)
 }
 
-func GenerateToken(user *model.User, expiry time.Duration, issuer, secret string) (string, error) {
+// GenerateToken issues an access token for a session of user.
+func GenerateToken(user *model.User, sessionID string, expiry time.Duration, issuer string, keys *jwtkeys.KeySet) (string, error) {
+	return generateToken(user, expiry, issuer, keys, jwt.MapClaims{"sid": sessionID})
+}
+
+// GenerateEnrollmentToken issues a token for a user who must set up two
+// factor authentication before doing anything else. The auth middleware only
+// lets it through to the /auth/2fa/ endpoints, and only while its session
+// has not been revoked.
+func GenerateEnrollmentToken(user *model.User, sessionID string, expiry time.Duration, issuer string, keys *jwtkeys.KeySet) (string, error) {
+	return generateToken(user, expiry, issuer, keys, jwt.MapClaims{"sid": sessionID, EnrollmentClaim: true})
+}
+
+func generateToken(user *model.User, expiry time.Duration, issuer string, keys *jwtkeys.KeySet, extra jwt.MapClaims) (string, error) {
 	//set token expiry time
 	bestBefore := time.Now().Add(expiry)
+	//unique token id so the token can be revoked before it expires
+	jti, err := NewTokenID()
+	if err != nil {
+		return "", err
+	}
 	//set token with claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	claims := jwt.MapClaims{
 		"email":  user.Email,
-		"user":   user.SessionKey,
+		"sub":    strconv.Itoa(user.Id),
 		"exp":    bestBefore.Unix(),
 		"issuer": issuer,
-	})
-	//generate jwt token str and sign with secret key
-	JWToken, err := token.SignedString([]byte(secret))
+		"jti":    jti,
+		"role":   user.Role,
+		"perms":  user.Permissions,
+	}
+	for name, value := range extra {
+		claims[name] = value
+	}
+	//sign with the current signing key of the keyset
+	JWToken, err := keys.Sign(claims)
 	if err != nil {
 		return "", err
 	}
 	return JWToken, nil
 }
 
+// NewTokenID returns a random 32 character hex id, used for jti claims and
+// refresh token families.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewRandomToken returns an opaque url safe token with 32 bytes of entropy.
+func NewRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded sha256 of an opaque token. Only the hash
+// is stored so a database leak does not hand out usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 var (
-	MYSTIC    string
-	JWTISSUER string
+	MYSTIC     string // secret for access tokens when no keyset is configured
+	LinkSecret string // secret for hmac signed email links and mfa tokens, never used for access tokens
+	JWTISSUER  string
+
+	JWTKeys *jwtkeys.KeySet // keys access tokens are signed and verified with
+
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	UnverifiedPolicy = UnverifiedRestrict
+
+	RegistrationPolicy  = RegistrationOpen
+	RegistrationDomains []string // email domains allowed to register, subdomains included
+	BlockedEmailDomains []string // email domains never allowed to register, subdomains included
+
+	AccountDeletionGrace = 14 * 24 * time.Hour // how long a deletion request can be cancelled
+
+	MaxUploadBytes int64 = 5 << 20 // largest accepted profile picture upload
+
+	RecommendationsMaxAge = 24 * time.Hour // how long "people you may know" is served before being recomputed
+
+	TrustProxyHeaders bool
+
+	MaxLoginFailures = 10               // failed sign ins before an email is locked
+	LoginLockout     = 15 * time.Minute // how long a lockout lasts
+	LoginIPLimit     = 30               // sign in attempts per ip within LoginIPWindow
+	LoginIPWindow    = 5 * time.Minute
 )